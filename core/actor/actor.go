@@ -2,7 +2,11 @@ package actor
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
+	"github.com/shooyaaa/core"
 	"github.com/shooyaaa/core/uuid"
 	"github.com/shooyaaa/log"
 )
@@ -15,6 +19,11 @@ type Actor[T Mail[any], D any] interface {
 	ActorStartImpl[T]
 	ActorStopImpl
 	ActorMailboxImpl[T]
+	ActorSupervisionImpl
+	ActorWatchImpl
+	ActorTimerImpl
+	ActorPubSubImpl
+	ActorBehaviorImpl[T]
 	Data() D
 	ID() uuid.UUID
 }
@@ -24,27 +33,105 @@ type ActorStartImpl[T Mail[any]] interface {
 }
 type ActorStopImpl interface {
 	Stop()
+	SetStopPolicy(policy StopPolicy)
+	Terminated() <-chan struct{}
 }
 
 type ActorMailboxImpl[T Mail[any]] interface {
 	Mailbox() Mailbox[T]
 }
 
-func NewActor[T Mail[any], D any](mailboxType MailboxType, id uuid.UUID, data D) Actor[T, D] {
+type ActorSupervisionImpl interface {
+	Supervisor
+	Parent() Supervisor
+	Children() []SupervisedActor
+	Supervise(child SupervisedActor) *core.CoreError
+	SetSupervisorStrategy(strategy *SupervisorStrategy)
+}
+
+// ActorWatchImpl needs the actor to be added to a postman, the watcher
+// receives a Terminated mail once target is gone.
+type ActorWatchImpl interface {
+	Watch(target Address) *core.CoreError
+	Unwatch(target Address) *core.CoreError
+}
+
+// NewActor panics when the mailbox can't be created, see NewMailboxE.
+func NewActor[T Mail[any], D any](mailboxType MailboxType, id uuid.UUID, data D, opts ...MailboxOption) Actor[T, D] {
+	return NewActorWithMailbox[T, D](NewMailbox(mailboxType, id, opts...).(Mailbox[T]), id, data)
+}
+
+// NewActorWithMailbox creates an actor reading from a mailbox built by the caller.
+func NewActorWithMailbox[T Mail[any], D any](mailbox Mailbox[T], id uuid.UUID, data D) Actor[T, D] {
 	return &actorImpl[T, D]{
-		id:      id,
-		mailbox: NewMailbox(mailboxType).(Mailbox[T]),
-		data:    data,
+		id:         id,
+		mailbox:    mailbox,
+		data:       data,
+		restarts:   make(map[uuid.UUID][]time.Time),
+		timers:     make(map[string]*actorTimer),
+		stopPolicy: StopPolicy_DISCARD,
+		terminated: make(chan struct{}),
 	}
 }
+
+// Stop stops the children, closes the mailbox and ends the receive loop
+// according to the stop policy. It does not wait, so it can be called from
+// inside the process function, wait on Terminated instead.
 func (a *actorImpl[T, D]) Stop() {
+	a.mu.Lock()
+	if a.stopped {
+		a.mu.Unlock()
+		return
+	}
+	a.stopped = true
+	cancel := a.cancel
+	parent := a.parent
+	drain := a.stopPolicy == StopPolicy_DRAIN
+	children := append([]SupervisedActor(nil), a.children...)
+	a.mu.Unlock()
+
+	for _, child := range children {
+		child.Stop()
+	}
+	a.cancelTimers()
 	a.mailbox.Close(context.Background())
+	if cancel == nil {
+		a.terminate()
+	} else if !drain {
+		cancel()
+	}
+	if parent != nil {
+		parent.removeChild(a.id)
+	}
 }
 
 type actorImpl[T Mail[any], D any] struct {
 	id      uuid.UUID
 	mailbox Mailbox[T]
 	data    D
+
+	mu       sync.Mutex
+	process  ActorProcessFn[T]
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopped  bool
+	parent   Supervisor
+	children []SupervisedActor
+	strategy *SupervisorStrategy
+	restarts map[uuid.UUID][]time.Time
+	// behaviors stacks the process functions passed to Become
+	behaviors []ActorProcessFn[T]
+	stash     []T
+
+	postman         Postman
+	timers          map[string]*actorTimer
+	timerGeneration int64
+	restartHook     func(cause *core.CoreError)
+	lastFailure     *core.CoreError
+	started         bool
+	stopPolicy      StopPolicy
+	terminated      chan struct{}
+	terminateOnce   sync.Once
 }
 
 func (a *actorImpl[T, D]) Mailbox() Mailbox[T] {
@@ -56,16 +143,138 @@ func (a *actorImpl[T, D]) Data() D {
 }
 
 func (a *actorImpl[T, D]) Start(process ActorProcessFn[T]) {
+	a.mu.Lock()
+	a.process = process
+	a.behaviors = nil
+	first := !a.started
+	a.started = true
+	a.mu.Unlock()
+	if hook, ok := any(a.data).(PreStarter); ok && first {
+		a.hook("PreStart", hook.PreStart)
+	}
+	a.launch()
+}
+
+// launch runs the receive loop in a new goroutine unless one is already running.
+// A failure ends the loop and is reported to the supervisor, which decides
+// whether the loop is launched again.
+func (a *actorImpl[T, D]) launch() {
+	a.mu.Lock()
+	if a.stopped || a.process == nil || a.cancel != nil {
+		a.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	a.cancel, a.done = cancel, done
+	a.lastFailure = nil
+	process := a.process
+	a.mu.Unlock()
+
 	go func() {
-		for {
-			msg, err := a.mailbox.Receive(context.Background())
-			if err != nil {
-				log.ErrorF("error while receive message: %v", err)
+		cause := a.loop(ctx, process)
+		a.mu.Lock()
+		if a.done == done {
+			a.cancel, a.done = nil, nil
+		}
+		stopped := a.stopped
+		if cause != nil {
+			a.lastFailure = cause
+		}
+		a.mu.Unlock()
+		cancel()
+		close(done)
+		if stopped {
+			a.terminate()
+		} else if cause != nil {
+			a.fail(cause)
+		}
+	}()
+}
+
+// halt cancels the receive loop and waits until it has returned.
+func (a *actorImpl[T, D]) halt() {
+	a.mu.Lock()
+	cancel, done := a.cancel, a.done
+	a.cancel, a.done = nil, nil
+	a.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (a *actorImpl[T, D]) loop(ctx context.Context, process ActorProcessFn[T]) *core.CoreError {
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		msg, err := a.mailbox.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil || a.isStopped() {
+				return nil
+			}
+			log.ErrorF("error while receive message: %v", err)
+			continue
+		}
+		if msg.Kind() == MailKind_TIMER {
+			message, ok := AsMessage[TimerMessage](msg.Message())
+			if !ok {
+				continue
+			}
+			if msg, ok = a.timerMail(msg, message); !ok {
 				continue
 			}
-			process(msg)
+		}
+		if message, ok := msg.Message().(SystemMessage); ok {
+			if stop, cause := a.handleSystem(message); stop {
+				return cause
+			}
+			continue
+		}
+		metrics, postman := a.metrics()
+		msg, finish := a.traceProcess(msg)
+		start := time.Now()
+		cause := a.invoke(a.behavior(process), msg)
+		if metrics != nil {
+			metrics.processed(postman, a.id, time.Since(start))
+		}
+		finish(cause)
+		if cause != nil {
+			return cause
+		}
+		if acker, ok := any(a.mailbox).(MailboxAcker); ok {
+			if err := acker.Ack(ctx); err != nil {
+				log.ErrorF("error while ack message: %v\n", err)
+			}
+		}
+	}
+}
+
+// metrics returns the Metrics of the postman the actor joined, if any.
+func (a *actorImpl[T, D]) metrics() (Metrics, *postmanImpl) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if m, ok := a.postman.(*postmanImpl); ok && m.metrics != nil {
+		return m.metrics, m
+	}
+	return nil, nil
+}
+
+func (a *actorImpl[T, D]) isStopped() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stopped
+}
+
+func (a *actorImpl[T, D]) invoke(process ActorProcessFn[T], msg T) (cause *core.CoreError) {
+	defer func() {
+		if r := recover(); r != nil {
+			cause = core.NewCoreError(core.ERROR_CODE_ACTOR_PANIC, fmt.Sprintf("actor %s panic: %v", (&a.id).String(), r))
 		}
 	}()
+	process(msg)
+	return nil
 }
 
 func (a *actorImpl[T, D]) ID() uuid.UUID {
@@ -1,24 +1,56 @@
-package actor
+package actor_test
 
 import (
 	"context"
 	"testing"
 
+	"github.com/shooyaaa/core/actor"
 	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/testkit"
 	"github.com/shooyaaa/core/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
+const UUIDTypeActor uuid.UUIDType = "actor"
+
 func TestActor(t *testing.T) {
-	const UUIDTypeActor uuid.UUIDType = "actor"
-	const UUIDTypeMail uuid.UUIDType = "mail"
-	actor := NewActor[Mail[any], string](MailboxType_MEMORY, uuid.NewSimpleUUIDGenerator(UUIDTypeActor).Next(), "test")
-	actor.Start(func(msg Mail[any]) {
-		assert.Equal(t, any("test"), msg.Message())
-		assert.Equal(t, 1, actor.Data())
-		actor.Stop()
+	testkit.VerifyNoLeaks(t)
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeActor)
+	probe := testkit.NewTestProbe(t, idGen.Next())
+	a := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, idGen.Next(), "test")
+	a.Start(func(mail actor.Mail[any]) {
+		// 把收到的消息和 actor 的数据都转给 probe
+		forward(a, probe, mail.Message())
+		forward(a, probe, a.Data())
 	})
-	err := actor.Mailbox().Send(context.Background(), NewMail[any](uuid.NewSimpleUUIDGenerator(UUIDTypeActor).Next(), uuid.NewSimpleUUIDGenerator(UUIDTypeMail).Next(), any("test"), codec.JSON_CODEC))
+	err := a.Mailbox().Send(context.Background(), actor.NewMail[any](idGen.Next(), a.ID(), "hello", codec.JSON_CODEC))
 	assert.NoError(t, err)
-	actor.Stop()
+	probe.ExpectMsg("hello")
+	probe.ExpectMsg("test")
+	assert.Equal(t, a.ID(), probe.LastSender())
+	a.Stop()
+	testkit.ExpectTerminated(t, a)
+}
+
+func TestPostoffice_Actor(t *testing.T) {
+	testkit.VerifyNoLeaks(t)
+	ctx := context.Background()
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeActor)
+	po := actor.NewPostoffice(library.NewConsistentHash[actor.Address](150, nil, nil), idGen.Next())
+	// the probe sits on the postman behind the postoffice
+	pm2, probe := newProbePostman(t, idGen.Next())
+	assert.Nil(t, po.Add(ctx, actor.NewLocalPostManAddress(pm2)))
+
+	a1 := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, idGen.Next(), nil)
+	defer a1.Stop()
+	pm1 := actor.NewPostman()
+	pm1.Add(ctx, a1)
+	pm1.Register(ctx, actor.NewLocalPostOfficeAddress(po))
+
+	// mail a1 sends to an actor pm1 does not hold goes through the postoffice
+	assert.NoError(t, a1.Mailbox().Send(ctx, actor.NewMail[any](a1.ID(), probe.ID(), "test", codec.JSON_CODEC)))
+	mail := probe.ExpectMsg("test")
+	assert.Equal(t, probe.ID(), mail.Receiver())
+	assert.Equal(t, a1.ID(), mail.Sender())
 }
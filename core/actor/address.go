@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/shooyaaa/core"
-	"github.com/shooyaaa/core/codec"
 	"github.com/shooyaaa/core/uuid"
 )
 
@@ -57,6 +56,12 @@ func (a *LocalPostOfficeAddress) ID() uuid.UUID {
 func (a *LocalPostOfficeAddress) Transfer(ctx context.Context, mail Mail[any]) *core.CoreError {
 	return a.postoffice.Dispatch(ctx, mail)
 }
+
+// Lookup lets a postman resolve names through a postoffice in this process.
+func (a *LocalPostOfficeAddress) Lookup(name string) (Address, bool) {
+	return a.postoffice.Lookup(name)
+}
+
 func NewLocalPostOfficeAddress(postoffice Postoffice) Address {
 	return &LocalPostOfficeAddress{postoffice: postoffice}
 }
@@ -76,14 +81,35 @@ func (a *RemoteAddress) ID() uuid.UUID {
 
 func (a *RemoteAddress) Transfer(ctx context.Context, mail Mail[any]) *core.CoreError {
 	channel := GetChannelByAddress(a.address.String())
-	codecInstance := codec.NewCodec[Mail[any]](mail.CodeC())
-	buff, err := codecInstance.Encode(mail)
+	buff, err := EncodeMail(mail)
 	if err != nil {
 		return core.NewCoreError(core.ERROR_CODE_CODEC_ENCODE_ERROR, fmt.Sprintf("error while encode mail: %v", err))
 	}
 	return channel.Send(ctx, buff)
 }
 
+// TransferBatch sends mails in a single request when the channel supports it.
+func (a *RemoteAddress) TransferBatch(ctx context.Context, mails []Mail[any]) *core.CoreError {
+	channel := GetChannelByAddress(a.address.String())
+	batch := make([][]byte, 0, len(mails))
+	for _, mail := range mails {
+		buff, err := EncodeMail(mail)
+		if err != nil {
+			return core.NewCoreError(core.ERROR_CODE_CODEC_ENCODE_ERROR, fmt.Sprintf("error while encode mail: %v", err))
+		}
+		batch = append(batch, buff)
+	}
+	if batcher, ok := channel.(BatchRpcChannel); ok {
+		return batcher.SendBatch(ctx, batch)
+	}
+	for _, buff := range batch {
+		if err := channel.Send(ctx, buff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func NewRemoteAddress(address RpcAddress, id uuid.UUID) Address {
 	return &RemoteAddress{address: address, id: id}
 }
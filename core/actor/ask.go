@@ -0,0 +1,140 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+)
+
+// UUIDType_ASK marks the temporary reply addresses created by Ask. The type
+// of a reply address also names the asking postman, see askReplyTo.
+const UUIDType_ASK uuid.UUIDType = "ask"
+
+var askSequence int64
+
+type askFuture struct {
+	target        uuid.UUID
+	correlationID int64
+	reply         chan Mail[any]
+	failure       chan *core.CoreError
+}
+
+func newAskFuture(target uuid.UUID, correlationID int64) *askFuture {
+	return &askFuture{
+		target:        target,
+		correlationID: correlationID,
+		reply:         make(chan Mail[any], 1),
+		failure:       make(chan *core.CoreError, 1),
+	}
+}
+
+func (f *askFuture) complete(mail Mail[any]) *core.CoreError {
+	if mail.CorrelationID() != f.correlationID {
+		return core.NewCoreError(core.ERROR_CODE_ASK_CORRELATION_MISMATCH, fmt.Sprintf("reply correlation id mismatch: %d != %d", mail.CorrelationID(), f.correlationID))
+	}
+	select {
+	case f.reply <- mail:
+	default:
+	}
+	return nil
+}
+
+func (f *askFuture) fail(err *core.CoreError) {
+	select {
+	case f.failure <- err:
+	default:
+	}
+}
+
+// Ask delivers message to receiver from a temporary reply address and waits
+// until the receiver answers with NewReply or ctx is done.
+func (m *postmanImpl) Ask(ctx context.Context, receiver uuid.UUID, message any) (Mail[any], *core.CoreError) {
+	return m.ask(ctx, receiver, message, m.Deliver)
+}
+
+// AskAddress is Ask through an address, like one returned by Lookup or a
+// remote address, the question is transferred by the address.
+func (m *postmanImpl) AskAddress(ctx context.Context, address Address, message any) (Mail[any], *core.CoreError) {
+	return m.ask(ctx, address.ID(), message, address.Transfer)
+}
+
+func (m *postmanImpl) ask(ctx context.Context, receiver uuid.UUID, message any, send func(ctx context.Context, mail Mail[any]) *core.CoreError) (Mail[any], *core.CoreError) {
+	correlationID := atomic.AddInt64(&askSequence, 1)
+	replyTo := askReplyTo(m.id, correlationID)
+	future := newAskFuture(receiver, correlationID)
+	m.futures.Store(replyTo, future)
+	defer m.futures.Delete(replyTo)
+
+	err := send(ctx, NewMail[any](replyTo, receiver, message, codec.JSON_CODEC, WithCorrelationID(correlationID)))
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case reply := <-future.reply:
+		return reply, nil
+	case err := <-future.failure:
+		return nil, err
+	case <-ctx.Done():
+		return nil, core.NewCoreError(core.ERROR_CODE_ASK_TIMEOUT, fmt.Sprintf("ask %s: %v", (&receiver).String(), ctx.Err()))
+	}
+}
+
+// askReplyTo is the reply address of an ask of postman. The postman is put
+// into the type, "ask@<postman>", so replies find their way back to it
+// without a hash and ids of different postmen never collide.
+func askReplyTo(postman uuid.UUID, correlationID int64) uuid.UUID {
+	return uuid.UUID{Type: UUIDType_ASK + "@" + uuid.UUIDType((&postman).String()), ID: correlationID}
+}
+
+// askPostman returns the postman waiting for the replies to id, ok is false
+// for ids that are no reply address or do not name a postman.
+func askPostman(id uuid.UUID) (postman uuid.UUID, ok bool) {
+	rest, ok := strings.CutPrefix(string(id.Type), string(UUIDType_ASK)+"@")
+	if !ok {
+		return postman, false
+	}
+	i := strings.LastIndex(rest, ":")
+	if i < 0 {
+		return postman, false
+	}
+	n, err := strconv.ParseInt(rest[i+1:], 10, 64)
+	if err != nil {
+		return postman, false
+	}
+	return uuid.UUID{Type: uuid.UUIDType(rest[:i]), ID: n}, true
+}
+
+// resolve completes the pending ask the mail replies to. Replies to the
+// asks of another postman are left for Dispatch.
+func (m *postmanImpl) resolve(mail Mail[any]) (bool, *core.CoreError) {
+	receiver := mail.Receiver()
+	if postman, ok := askPostman(receiver); ok {
+		if postman != m.id {
+			return false, nil
+		}
+	} else if receiver.Type != UUIDType_ASK {
+		return false, nil
+	}
+	f, ok := m.futures.Load(receiver)
+	if !ok {
+		return true, core.NewCoreError(core.ERROR_CODE_ACTOR_NOT_FOUND, fmt.Sprintf("ask already finished: %s", (&receiver).String()))
+	}
+	return true, f.(*askFuture).complete(mail)
+}
+
+// abandon fails every pending ask waiting on the removed actor.
+func (m *postmanImpl) abandon(target uuid.UUID) {
+	m.futures.Range(func(key, value any) bool {
+		f := value.(*askFuture)
+		if f.target == target {
+			f.fail(core.NewCoreError(core.ERROR_CODE_ACTOR_NOT_FOUND, fmt.Sprintf("ask target removed: %s", (&target).String())))
+		}
+		return true
+	})
+}
@@ -0,0 +1,174 @@
+package actor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeAskTest uuid.UUIDType = "ask_test"
+
+func newEchoActor(id uuid.UUID) Actor[Mail[any], any] {
+	a := NewActor[Mail[any], any](MailboxType_MEMORY, id, nil)
+	a.Start(func(mail Mail[any]) {
+		a.Mailbox().Send(context.Background(), NewReply[any](mail, mail.Message()))
+	})
+	return a
+}
+
+func TestPostman_Ask(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeAskTest)
+	ctx := context.Background()
+	pm := NewPostman()
+	echo := newEchoActor(idGen.Next())
+	pm.Add(ctx, echo)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	reply, err := pm.Ask(ctx, echo.ID(), "ping")
+	assert.Nil(t, err)
+	assert.Equal(t, "ping", reply.Message())
+	assert.Equal(t, echo.ID(), reply.Sender())
+	echo.Stop()
+}
+
+func TestPostman_AskTimeout(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeAskTest)
+	ctx := context.Background()
+	pm := NewPostman()
+	silent := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	silent.Start(func(mail Mail[any]) {})
+	pm.Add(ctx, silent)
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	reply, err := pm.Ask(ctx, silent.ID(), "ping")
+	assert.Nil(t, reply)
+	assert.NotNil(t, err)
+	assert.Equal(t, core.ERROR_CODE_ASK_TIMEOUT, err.Code())
+	silent.Stop()
+}
+
+func TestPostman_AskTargetRemoved(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeAskTest)
+	ctx := context.Background()
+	pm := NewPostman()
+	received := make(chan struct{}, 1)
+	silent := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	silent.Start(func(mail Mail[any]) {
+		received <- struct{}{}
+	})
+	pm.Add(ctx, silent)
+
+	go func() {
+		<-received
+		pm.Remove(ctx, silent.ID())
+	}()
+	reply, err := pm.Ask(ctx, silent.ID(), "ping")
+	assert.Nil(t, reply)
+	assert.NotNil(t, err)
+	assert.Equal(t, core.ERROR_CODE_ACTOR_NOT_FOUND, err.Code())
+	silent.Stop()
+}
+
+func TestPostman_AskLateReply(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeAskTest)
+	pm := NewPostman()
+	late := NewMail[any](idGen.Next(), uuid.UUID{Type: UUIDType_ASK, ID: -1}, "late", codec.JSON_CODEC, WithCorrelationID(-1))
+	err := pm.Deliver(context.Background(), late)
+	assert.NotNil(t, err)
+	assert.Equal(t, core.ERROR_CODE_ACTOR_NOT_FOUND, err.Code())
+}
+
+func TestPostman_AskAcrossPostmen(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeAskTest)
+	ctx := context.Background()
+	ring := library.NewConsistentHash[Address](150, nil, nil)
+	po := NewPostoffice(ring, idGen.Next())
+	asker := NewPostman(WithPostmanID(idGen.Next()))
+	other := NewPostman(WithPostmanID(idGen.Next()))
+	for _, pm := range []Postman{asker, other} {
+		po.Add(ctx, NewLocalPostManAddress(pm))
+		pm.Register(ctx, NewLocalPostOfficeAddress(po))
+	}
+	echo := newEchoActor(idOn(ring, idGen, other))
+	defer echo.Stop()
+	other.Add(ctx, echo)
+
+	// 回复地址带着发起 ask 的 postman，回复直接送回它
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		reply, err := asker.Ask(ctx, echo.ID(), i)
+		assert.Nil(t, err)
+		if assert.NotNil(t, reply) {
+			assert.Equal(t, i, reply.Message())
+			postman, ok := askPostman(reply.Receiver())
+			assert.True(t, ok)
+			assert.Equal(t, asker.ID(), postman)
+		}
+	}
+	assert.NotEqual(t, askReplyTo(asker.ID(), 1), askReplyTo(other.ID(), 1))
+}
+
+func TestPostman_AskAddress(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeAskTest)
+	ctx := context.Background()
+	ring := library.NewConsistentHash[Address](150, nil, nil)
+	po := NewPostoffice(ring, idGen.Next())
+	asker := NewPostman(WithPostmanID(idGen.Next()))
+	other := NewPostman(WithPostmanID(idGen.Next()))
+	for _, pm := range []Postman{asker, other} {
+		po.Add(ctx, NewLocalPostManAddress(pm))
+		pm.Register(ctx, NewLocalPostOfficeAddress(po))
+	}
+	echo := newEchoActor(idOn(ring, idGen, other))
+	defer echo.Stop()
+	other.Add(ctx, echo)
+	assert.Nil(t, other.Bind(ctx, "/user/echo", echo.ID()))
+
+	// 通过名字查到的地址发起 ask
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	address, err := asker.Lookup(ctx, "/user/echo")
+	assert.Nil(t, err)
+	reply, err := asker.AskAddress(ctx, address, "ping")
+	assert.Nil(t, err)
+	if assert.NotNil(t, reply) {
+		assert.Equal(t, "ping", reply.Message())
+		assert.Equal(t, echo.ID(), reply.Sender())
+	}
+}
+
+func TestPostman_AskCorrelationMismatch(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeAskTest)
+	ctx := context.Background()
+	pm := NewPostman(WithPostmanID(idGen.Next()))
+	target := idGen.Next()
+	var replyTo uuid.UUID
+	forger := NewActor[Mail[any], any](MailboxType_MEMORY, target, nil)
+	errs := make(chan *core.CoreError, 1)
+	forger.Start(func(mail Mail[any]) {
+		replyTo = mail.Sender()
+		errs <- pm.Deliver(ctx, NewMail[any](target, replyTo, "forged", codec.JSON_CODEC, WithCorrelationID(mail.CorrelationID()+1)))
+	})
+	defer forger.Stop()
+	pm.Add(ctx, forger)
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err := pm.Ask(ctx, target, "ping")
+	assert.Equal(t, core.ERROR_CODE_ASK_TIMEOUT, err.Code())
+	select {
+	case err := <-errs:
+		assert.Equal(t, core.ERROR_CODE_ASK_CORRELATION_MISMATCH, err.Code())
+	case <-time.After(time.Second):
+		t.Fatal("the forged reply should be rejected")
+	}
+}
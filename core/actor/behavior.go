@@ -0,0 +1,98 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/log"
+)
+
+// ActorBehaviorImpl is meant to be called from inside the process function.
+// A restart returns to the process function given to Start and unstashes,
+// stopping drops the stash.
+type ActorBehaviorImpl[T Mail[any]] interface {
+	// Become processes the mail from the next one on with process, the
+	// current behavior is kept for Unbecome.
+	Become(process ActorProcessFn[T])
+	// Unbecome returns to the previous behavior, the one given to Start is
+	// never dropped.
+	Unbecome()
+	// Stash sets mail aside until UnstashAll, it needs a mailbox that
+	// implements MailboxPrepender.
+	Stash(mail T) *core.CoreError
+	// UnstashAll puts the stashed mail back at the head of the mailbox, in
+	// the order it was stashed.
+	UnstashAll() *core.CoreError
+	Stashed() int
+}
+
+func (a *actorImpl[T, D]) Become(process ActorProcessFn[T]) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.behaviors = append(a.behaviors, process)
+}
+
+func (a *actorImpl[T, D]) Unbecome() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.behaviors) > 0 {
+		a.behaviors = a.behaviors[:len(a.behaviors)-1]
+	}
+}
+
+// behavior is the process function for the next mail, initial when the
+// actor has not become anything else.
+func (a *actorImpl[T, D]) behavior(initial ActorProcessFn[T]) ActorProcessFn[T] {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.behaviors) == 0 {
+		return initial
+	}
+	return a.behaviors[len(a.behaviors)-1]
+}
+
+func (a *actorImpl[T, D]) Stash(mail T) *core.CoreError {
+	if _, ok := any(a.mailbox).(MailboxPrepender[T]); !ok {
+		return core.NewCoreError(core.ERROR_CODE_STASH_ERROR, fmt.Sprintf("mailbox of %s can not unstash", (&a.id).String()))
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stash = append(a.stash, mail)
+	return nil
+}
+
+func (a *actorImpl[T, D]) UnstashAll() *core.CoreError {
+	a.mu.Lock()
+	stash := a.stash
+	a.stash = nil
+	a.mu.Unlock()
+	if len(stash) == 0 {
+		return nil
+	}
+	prepender, ok := any(a.mailbox).(MailboxPrepender[T])
+	if !ok {
+		return core.NewCoreError(core.ERROR_CODE_STASH_ERROR, fmt.Sprintf("mailbox of %s can not unstash", (&a.id).String()))
+	}
+	if err := prepender.Prepend(context.Background(), stash...); err != nil {
+		return core.NewCoreError(core.ERROR_CODE_STASH_ERROR, fmt.Sprintf("unstash %d mails of %s: %v", len(stash), (&a.id).String(), err))
+	}
+	return nil
+}
+
+func (a *actorImpl[T, D]) Stashed() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.stash)
+}
+
+// resetBehavior returns to the initial behavior and unstashes, a restarted
+// actor starts over.
+func (a *actorImpl[T, D]) resetBehavior() {
+	a.mu.Lock()
+	a.behaviors = nil
+	a.mu.Unlock()
+	if err := a.UnstashAll(); err != nil {
+		log.ErrorF("%s\n", err.String())
+	}
+}
@@ -0,0 +1,103 @@
+package actor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeBehavior uuid.UUIDType = "behavior"
+
+// newRoomActor stashes moves until the game starts and stops playing on end.
+func newRoomActor(id uuid.UUID) (Actor[Mail[any], any], chan any) {
+	played := make(chan any, 10)
+	a := NewActor[Mail[any], any](MailboxType_MEMORY, id, nil)
+	var playing ActorProcessFn[Mail[any]]
+	playing = func(mail Mail[any]) {
+		if mail.Message() == "end" {
+			a.Unbecome()
+			return
+		}
+		played <- mail.Message()
+	}
+	a.Start(func(mail Mail[any]) {
+		if mail.Message() != "start" {
+			a.Stash(mail)
+			return
+		}
+		a.Become(playing)
+		a.UnstashAll()
+	})
+	return a, played
+}
+
+func TestActor_StashUntilBecome(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeBehavior)
+	room, played := newRoomActor(idGen.Next())
+	defer room.Stop()
+
+	sendTo(t, room, "move1")
+	sendTo(t, room, "move2")
+	assert.Eventually(t, func() bool {
+		return room.Stashed() == 2
+	}, time.Second, 5*time.Millisecond)
+	expectNothing(t, played)
+	sendTo(t, room, "start")
+	sendTo(t, room, "move3")
+	expectMessage(t, played, "move1")
+	expectMessage(t, played, "move2")
+	expectMessage(t, played, "move3")
+	assert.Equal(t, 0, room.Stashed())
+
+	// 游戏结束后回到等待状态
+	sendTo(t, room, "end")
+	sendTo(t, room, "move4")
+	assert.Eventually(t, func() bool {
+		return room.Stashed() == 1
+	}, time.Second, 5*time.Millisecond)
+	expectNothing(t, played)
+}
+
+func TestActor_RestartResetsBehavior(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeBehavior)
+	received := make(chan any, 10)
+	a := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	defer a.Stop()
+	a.Start(func(mail Mail[any]) {
+		if mail.Message() == "become" {
+			a.Become(func(mail Mail[any]) {
+				a.Stash(mail)
+			})
+			return
+		}
+		received <- mail.Message()
+	})
+	sendTo(t, a, "become")
+	sendTo(t, a, "later")
+	assert.Eventually(t, func() bool {
+		return a.Stashed() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	a.(SupervisedActor).Restart(nil)
+	expectMessage(t, received, "later")
+	sendTo(t, a, "after restart")
+	expectMessage(t, received, "after restart")
+}
+
+func TestActor_StashNeedsPrepender(t *testing.T) {
+	config := DefaultFileMailboxConfig
+	DefaultFileMailboxConfig.Dir = t.TempDir()
+	t.Cleanup(func() { DefaultFileMailboxConfig = config })
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeBehavior)
+	a := NewActor[Mail[any], any](MailboxType_FILE, idGen.Next(), nil)
+	defer a.Stop()
+	err := a.Stash(NewMail[any](uuid.UUID{}, a.ID(), "durable", codec.JSON_CODEC))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, core.ERROR_CODE_STASH_ERROR, err.Code())
+	}
+	assert.Equal(t, 0, a.Stashed())
+}
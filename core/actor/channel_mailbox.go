@@ -0,0 +1,137 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/uuid"
+)
+
+// MailboxSocketDir is the directory holding the sockets and pipes of unix,
+// ipc and pipe mailboxes.
+var MailboxSocketDir = os.TempDir()
+
+// MailboxNode tells the mailboxes of this process apart from the ones of
+// other processes sharing MailboxSocketDir, actor ids restart in every process.
+var MailboxNode = strconv.Itoa(os.Getpid())
+
+// MailboxAddress returns the channel address of the mailbox owned by id, a
+// sibling process can pass it to NewRpcAddress to write into that mailbox.
+func MailboxAddress(mailboxType MailboxType, id uuid.UUID) string {
+	return NodeMailboxAddress(MailboxNode, mailboxType, id)
+}
+
+// NodeMailboxAddress is MailboxAddress in the process whose MailboxNode is node.
+func NodeMailboxAddress(node string, mailboxType MailboxType, id uuid.UUID) string {
+	name := fmt.Sprintf("actor-%s-%s-%d", node, id.Type, id.ID)
+	switch mailboxType {
+	case MailboxType_PIPE:
+		return "pipe://" + filepath.Join(MailboxSocketDir, name+".pipe")
+	default:
+		return "unix://" + filepath.Join(MailboxSocketDir, name+".sock")
+	}
+}
+
+type channelServer interface {
+	Listen(addr string) *core.CoreError
+	Close() error
+}
+
+// channelMailbox keeps its inbox behind a unix socket or a named pipe, so
+// actors in other processes on the same host can send to it without tcp.
+type channelMailbox struct {
+	mailboxTrace
+	id      uuid.UUID
+	addr    string
+	server  channelServer
+	channel RpcChannel
+	recv    *mailQueue[Mail[any]]
+	send    *mailQueue[Mail[any]]
+}
+
+func NewChannelMailbox(mailboxType MailboxType, id uuid.UUID) (Mailbox[Mail[any]], *core.CoreError) {
+	mb := &channelMailbox{
+		id:   id,
+		addr: MailboxAddress(mailboxType, id),
+		recv: newMailQueue[Mail[any]](DefaultMailboxCapacity, OverflowPolicy_BLOCK, nil),
+		send: newMailQueue[Mail[any]](DefaultMailboxCapacity, OverflowPolicy_BLOCK, nil),
+	}
+	inbox := MailReceiverFunc(func(ctx context.Context, mail Mail[any]) *core.CoreError {
+		if err := mb.recv.push(ctx, mail); err != nil {
+			return core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, err.Error())
+		}
+		return nil
+	})
+	if mailboxType == MailboxType_PIPE {
+		mb.server = NewPipeChannelServer(inbox)
+		mb.channel = NewPipeChannel(mb.addr)
+	} else {
+		mb.server = NewTcpChannelServer(inbox)
+		mb.channel = NewTcpChannel(mb.addr)
+	}
+	if err := mb.server.Listen(mb.addr); err != nil {
+		return nil, err
+	}
+	return mb, nil
+}
+
+// Send writes mail for the owner through the socket, so it queues up behind
+// mail written by other processes. Mail for other actors leaves through Gather.
+func (mb *channelMailbox) Send(ctx context.Context, data Mail[any]) error {
+	if data.Receiver() != mb.id {
+		return mb.send.push(ctx, mb.stamp(data))
+	}
+	buff, err := EncodeMail(data)
+	if err != nil {
+		return err
+	}
+	if cerr := mb.channel.Send(ctx, buff); cerr != nil {
+		return fmt.Errorf("%s", cerr.String())
+	}
+	return nil
+}
+
+func (mb *channelMailbox) Receive(ctx context.Context) (Mail[any], error) {
+	return mb.recv.pop(ctx)
+}
+
+func (mb *channelMailbox) Prepend(ctx context.Context, mails ...Mail[any]) error {
+	return mb.recv.pushFront(mails...)
+}
+
+func (mb *channelMailbox) Gather(fn func(Mail[any])) {
+	go func() {
+		for {
+			data, err := mb.send.pop(context.Background())
+			if err != nil {
+				break
+			}
+			fn(data)
+		}
+	}()
+}
+
+func (mb *channelMailbox) Close(ctx context.Context) error {
+	if !mb.recv.close() {
+		return nil
+	}
+	mb.send.close()
+	if closer, ok := mb.channel.(io.Closer); ok {
+		closer.Close()
+	}
+	return mb.server.Close()
+}
+
+func (mb *channelMailbox) ID() uuid.UUID {
+	return mb.id
+}
+
+// Addr is the channel address other processes write to.
+func (mb *channelMailbox) Addr() string {
+	return mb.addr
+}
@@ -0,0 +1,137 @@
+package actor
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeChannelTest uuid.UUIDType = "channel_test"
+
+func startReceiver(t *testing.T, id uuid.UUID) (Postman, chan Mail[any]) {
+	received := make(chan Mail[any], 10)
+	a := NewActor[Mail[any], any](MailboxType_MEMORY, id, nil)
+	a.Start(func(mail Mail[any]) {
+		received <- mail
+	})
+	pm := NewPostman()
+	pm.Add(context.Background(), a)
+	t.Cleanup(a.Stop)
+	return pm, received
+}
+
+func testChannelMailbox(t *testing.T, mailboxType MailboxType) {
+	MailboxSocketDir = t.TempDir()
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeChannelTest)
+	owner := idGen.Next()
+	mb := NewMailbox(mailboxType, owner)
+	defer mb.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, mb.Send(ctx, NewMail[any](idGen.Next(), owner, "local", codec.JSON_CODEC)))
+	mail, err := mb.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "local", mail.Message())
+
+	// 模拟同一台机器上的另一个进程通过地址写入
+	remote := NewRemoteAddress(NewRpcAddress(MailboxAddress(mailboxType, owner)), owner)
+	defer CloseChannel(MailboxAddress(mailboxType, owner))
+	assert.Nil(t, remote.Transfer(ctx, NewMail[any](idGen.Next(), owner, "sibling", codec.JSON_CODEC)))
+	mail, err = mb.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "sibling", mail.Message())
+
+	gathered := make(chan Mail[any], 1)
+	mb.Gather(func(mail Mail[any]) {
+		gathered <- mail
+	})
+	assert.NoError(t, mb.Send(ctx, NewMail[any](owner, idGen.Next(), "outgoing", codec.JSON_CODEC)))
+	select {
+	case mail := <-gathered:
+		assert.Equal(t, "outgoing", mail.Message())
+	case <-ctx.Done():
+		t.Fatal("outgoing mail should be gathered")
+	}
+}
+
+func TestUnixMailbox(t *testing.T) {
+	testChannelMailbox(t, MailboxType_UNIX)
+}
+
+func TestPipeMailbox(t *testing.T) {
+	testChannelMailbox(t, MailboxType_PIPE)
+}
+
+func TestUnixMailbox_Actor(t *testing.T) {
+	MailboxSocketDir = t.TempDir()
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeChannelTest)
+	received := make(chan any, 1)
+	a := NewActor[Mail[any], any](MailboxType_IPC, idGen.Next(), nil)
+	a.Start(func(mail Mail[any]) {
+		received <- mail.Message()
+	})
+	defer a.Stop()
+	sendTo(t, a, "over socket")
+	expectMessage(t, received, "over socket")
+}
+
+func TestUnixMailbox_AddressInUse(t *testing.T) {
+	MailboxSocketDir = t.TempDir()
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeChannelTest)
+	owner := idGen.Next()
+	addr := MailboxAddress(MailboxType_UNIX, owner)
+	assert.Contains(t, addr, "actor-"+MailboxNode+"-")
+	assert.NotEqual(t, addr, NodeMailboxAddress("other", MailboxType_UNIX, owner))
+
+	first, _ := startReceiver(t, owner)
+	server := NewTcpChannelServer(first)
+	assert.Nil(t, server.Listen(addr))
+	defer server.Close()
+	// 同一路径上的第二个 server 不能删掉活着的 socket
+	second := NewTcpChannelServer(first)
+	err := second.Listen(addr)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, core.ERROR_CODE_CHANNEL_LISTEN_ERROR, err.Code())
+	}
+
+	// 没有进程监听的旧 socket 文件可以被替换
+	stale := NodeMailboxAddress("stale", MailboxType_UNIX, owner)
+	_, path := channelTarget(stale)
+	listener, lerr := net.Listen("unix", path)
+	assert.NoError(t, lerr)
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	replacement := NewTcpChannelServer(first)
+	assert.Nil(t, replacement.Listen(stale))
+	replacement.Close()
+}
+
+func TestChannelMailbox_CreateError(t *testing.T) {
+	MailboxSocketDir = filepath.Join(t.TempDir(), "missing")
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeChannelTest)
+	for _, mailboxType := range []MailboxType{MailboxType_UNIX, MailboxType_PIPE} {
+		mb, err := NewMailboxE(mailboxType, idGen.Next())
+		assert.Nil(t, mb)
+		if assert.NotNil(t, err, "%v mailbox in a missing directory", mailboxType) {
+			assert.Equal(t, core.ERROR_CODE_CHANNEL_LISTEN_ERROR, err.Code())
+		}
+	}
+	assert.Panics(t, func() { NewMailbox(MailboxType_UNIX, idGen.Next()) })
+	assert.Panics(t, func() { NewMailboxE("unknown", idGen.Next()) })
+}
+
+func TestPipeChannel_ReceiveNotSupported(t *testing.T) {
+	data, err := NewPipeChannel("pipe:///tmp/actor.pipe").Receive(context.Background())
+	assert.Nil(t, data)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, core.ERROR_CODE_ADDRESS_NOT_SUPPORTED, err.Code())
+	}
+}
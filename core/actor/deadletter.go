@@ -0,0 +1,181 @@
+package actor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+const UUIDType_DEAD_LETTER uuid.UUIDType = "dead_letter"
+
+const DefaultDeadLetterCapacity = 1024
+
+// DeadLetter is a mail that could not be delivered together with the reason.
+type DeadLetter struct {
+	Mail  Mail[any]
+	Cause *core.CoreError
+	Time  time.Time
+}
+
+// DeadLetterOffice collects undeliverable mail. Captured mail is processed by
+// its own actor, so capturing never blocks the caller.
+type DeadLetterOffice interface {
+	Capture(ctx context.Context, mail Mail[any], cause *core.CoreError)
+	Letters() []DeadLetter
+	// Redeliver hands the letters addressed to receiver to deliver and forgets
+	// the ones delivered, it returns how many were delivered.
+	Redeliver(ctx context.Context, receiver uuid.UUID, deliver func(context.Context, Mail[any]) *core.CoreError) int
+	// RedeliverEnabled reports whether the postman should call Redeliver when
+	// an actor is added.
+	RedeliverEnabled() bool
+	ServeHTTP(w http.ResponseWriter, r *http.Request)
+	ID() uuid.UUID
+	Stop()
+}
+
+type deadLetterOfficeImpl struct {
+	actor     Actor[Mail[any], any]
+	capacity  int
+	redeliver bool
+
+	mu      sync.Mutex
+	letters []DeadLetter
+	next    int
+}
+
+// NewDeadLetterOffice keeps the latest capacity letters, redeliver enables
+// redelivery once the receiver is added to a postman.
+func NewDeadLetterOffice(id uuid.UUID, capacity int, redeliver bool) DeadLetterOffice {
+	if capacity <= 0 {
+		capacity = DefaultDeadLetterCapacity
+	}
+	d := &deadLetterOfficeImpl{capacity: capacity, redeliver: redeliver}
+	d.actor = NewActor[Mail[any], any](MailboxType_MEMORY, id, nil,
+		WithMailboxCapacity(capacity), WithOverflowPolicy(OverflowPolicy_DROP_OLDEST))
+	d.actor.Start(func(mail Mail[any]) {
+		if letter, ok := mail.Message().(DeadLetter); ok {
+			d.record(letter)
+		}
+	})
+	return d
+}
+
+func (d *deadLetterOfficeImpl) ID() uuid.UUID {
+	return d.actor.ID()
+}
+
+func (d *deadLetterOfficeImpl) Stop() {
+	d.actor.Stop()
+}
+
+func (d *deadLetterOfficeImpl) RedeliverEnabled() bool {
+	return d.redeliver
+}
+
+func (d *deadLetterOfficeImpl) Capture(ctx context.Context, mail Mail[any], cause *core.CoreError) {
+	letter := DeadLetter{Mail: mail, Cause: cause, Time: time.Now()}
+	id := d.ID()
+	err := d.actor.Mailbox().Send(ctx, NewMail[any](mail.Sender(), id, letter, codec.JSON_CODEC))
+	if err != nil {
+		log.ErrorF("error while capture dead letter: %v\n", err)
+	}
+}
+
+func (d *deadLetterOfficeImpl) record(letter DeadLetter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.letters) < d.capacity {
+		d.letters = append(d.letters, letter)
+		return
+	}
+	d.letters[d.next] = letter
+	d.next = (d.next + 1) % d.capacity
+}
+
+// Letters returns the captured letters, oldest first.
+func (d *deadLetterOfficeImpl) Letters() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ordered()
+}
+
+func (d *deadLetterOfficeImpl) ordered() []DeadLetter {
+	letters := make([]DeadLetter, 0, len(d.letters))
+	letters = append(letters, d.letters[d.next:]...)
+	return append(letters, d.letters[:d.next]...)
+}
+
+func (d *deadLetterOfficeImpl) Redeliver(ctx context.Context, receiver uuid.UUID, deliver func(context.Context, Mail[any]) *core.CoreError) int {
+	var pending []DeadLetter
+	d.mu.Lock()
+	kept := make([]DeadLetter, 0, len(d.letters))
+	for _, letter := range d.ordered() {
+		if letter.Mail.Receiver() == receiver {
+			pending = append(pending, letter)
+		} else {
+			kept = append(kept, letter)
+		}
+	}
+	d.letters, d.next = kept, 0
+	d.mu.Unlock()
+
+	delivered := 0
+	for _, letter := range pending {
+		if err := deliver(ctx, letter.Mail); err != nil {
+			d.record(DeadLetter{Mail: letter.Mail, Cause: err, Time: time.Now()})
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+type deadLetterView struct {
+	Sender        string `json:"sender"`
+	Receiver      string `json:"receiver"`
+	Message       any    `json:"message"`
+	CorrelationID int64  `json:"correlation_id,omitempty"`
+	Code          int    `json:"code"`
+	Reason        string `json:"reason"`
+	Time          string `json:"time"`
+}
+
+// ServeHTTP lists the captured letters as json, the receiver query parameter
+// filters by the "type:id" form of the receiver.
+func (d *deadLetterOfficeImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	filter := r.URL.Query().Get("receiver")
+	views := make([]deadLetterView, 0)
+	for _, letter := range d.Letters() {
+		sender, receiver := letter.Mail.Sender(), letter.Mail.Receiver()
+		view := deadLetterView{
+			Sender:        (&sender).String(),
+			Receiver:      (&receiver).String(),
+			Message:       letter.Mail.Message(),
+			CorrelationID: letter.Mail.CorrelationID(),
+			Time:          letter.Time.Format(time.RFC3339Nano),
+		}
+		if filter != "" && view.Receiver != filter {
+			continue
+		}
+		if letter.Cause != nil {
+			view.Code = int(letter.Cause.Code())
+			view.Reason = letter.Cause.Msg()
+		}
+		views = append(views, view)
+	}
+	w.Header().Set("Content-Type", "text/json")
+	if err := json.NewEncoder(w).Encode(views); err != nil {
+		log.ErrorF("error while encode dead letters: %v\n", err)
+	}
+}
@@ -0,0 +1,107 @@
+package actor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeDeadLetterTest uuid.UUIDType = "dead_letter_test"
+
+func expectLetters(t *testing.T, office DeadLetterOffice, count int) []DeadLetter {
+	assert.Eventually(t, func() bool {
+		return len(office.Letters()) == count
+	}, time.Second, 5*time.Millisecond)
+	return office.Letters()
+}
+
+func TestDeadLetterOffice_RingBuffer(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeDeadLetterTest)
+	office := NewDeadLetterOffice(idGen.Next(), 3, false)
+	defer office.Stop()
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		office.Capture(ctx, NewMail[any](idGen.Next(), idGen.Next(), i, codec.JSON_CODEC), nil)
+	}
+	assert.Eventually(t, func() bool {
+		letters := office.Letters()
+		return len(letters) == 3 && letters[2].Mail.Message() == 4
+	}, time.Second, 5*time.Millisecond)
+	letters := office.Letters()
+	assert.Equal(t, 2, letters[0].Mail.Message(), "oldest letters should be overwritten")
+}
+
+func TestPostman_DeadLetterRedelivery(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeDeadLetterTest)
+	office := NewDeadLetterOffice(idGen.Next(), 10, true)
+	defer office.Stop()
+	ctx := context.Background()
+	pm := NewPostman(WithPostmanDeadLetters(office))
+
+	target := idGen.Next()
+	err := pm.Receive(ctx, NewMail[any](idGen.Next(), target, "early", codec.JSON_CODEC))
+	assert.Equal(t, core.ERROR_CODE_ACTOR_NOT_FOUND, err.Code())
+	letters := expectLetters(t, office, 1)
+	assert.Equal(t, core.ERROR_CODE_ACTOR_NOT_FOUND, letters[0].Cause.Code())
+	assert.False(t, letters[0].Time.IsZero())
+
+	received := make(chan any, 1)
+	a := NewActor[Mail[any], any](MailboxType_MEMORY, target, nil)
+	a.Start(func(mail Mail[any]) {
+		received <- mail.Message()
+	})
+	defer a.Stop()
+	assert.Nil(t, pm.Add(ctx, a))
+	expectMessage(t, received, "early")
+	assert.Empty(t, office.Letters())
+}
+
+func TestPostoffice_DeadLetters(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeDeadLetterTest)
+	office := NewDeadLetterOffice(idGen.Next(), 10, false)
+	defer office.Stop()
+	po := NewPostoffice(library.NewConsistentHash[Address](150, nil, nil), idGen.Next(), WithPostofficeDeadLetters(office))
+
+	err := po.Dispatch(context.Background(), NewMail[any](idGen.Next(), idGen.Next(), "lost", codec.JSON_CODEC))
+	assert.Equal(t, core.ERROR_CODE_POSTMAN_NOT_FOUND, err.Code())
+	letters := expectLetters(t, office, 1)
+	assert.Equal(t, "lost", letters[0].Mail.Message())
+}
+
+func TestDeadLetterOffice_ServeHTTP(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeDeadLetterTest)
+	office := NewDeadLetterOffice(idGen.Next(), 10, false)
+	defer office.Stop()
+	ctx := context.Background()
+	receiver := idGen.Next()
+	office.Capture(ctx, NewMail[any](idGen.Next(), receiver, "first", codec.JSON_CODEC),
+		core.NewCoreError(core.ERROR_CODE_ACTOR_NOT_FOUND, "not found"))
+	office.Capture(ctx, NewMail[any](idGen.Next(), idGen.Next(), "second", codec.JSON_CODEC), nil)
+	expectLetters(t, office, 2)
+
+	server := httptest.NewServer(office)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "?receiver=" + (&receiver).String())
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	var views []deadLetterView
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&views))
+	assert.Len(t, views, 1)
+	assert.Equal(t, "first", views[0].Message)
+	assert.Equal(t, int(core.ERROR_CODE_ACTOR_NOT_FOUND), views[0].Code)
+	assert.Equal(t, "not found", views[0].Reason)
+
+	resp, err = http.Post(server.URL, "text/json", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,330 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+type TerminatedReason string
+
+// STOPPED and CRASHED are reported when the actor stops normally or after a
+// failure, REMOVED when it leaves its postman, UNREACHABLE when its postman
+// left the ring and NOT_FOUND when there was no such actor to watch.
+const TerminatedReason_STOPPED TerminatedReason = "stopped"
+const TerminatedReason_CRASHED TerminatedReason = "crashed"
+const TerminatedReason_REMOVED TerminatedReason = "removed"
+const TerminatedReason_UNREACHABLE TerminatedReason = "unreachable"
+const TerminatedReason_NOT_FOUND TerminatedReason = "not_found"
+
+// Terminated is the mail a watcher receives once the watched actor is gone.
+type Terminated struct {
+	Actor  uuid.UUID
+	Reason TerminatedReason
+	Cause  string
+}
+
+// AsTerminated reads a Terminated mail, also after it crossed the wire.
+func AsTerminated(message any) (Terminated, bool) {
+	return AsMessage[Terminated](message, "Actor", "Reason")
+}
+
+// WatchRequest asks the postman holding Target to report its termination.
+type WatchRequest struct {
+	Watcher uuid.UUID
+	Target  uuid.UUID
+	Unwatch bool
+}
+
+// WatchAck tells the watcher which postman holds the watched actor.
+type WatchAck struct {
+	Target uuid.UUID
+	Host   uuid.UUID
+}
+
+// PostmanUnreachable is sent by the postoffice when a postman left the ring.
+type PostmanUnreachable struct {
+	Unreachable uuid.UUID
+}
+
+// deathWatch keeps the watchers of the actors held by the postman, and the
+// watches local actors placed on actors held elsewhere.
+type deathWatch struct {
+	mu       sync.Mutex
+	watchers map[uuid.UUID]map[uuid.UUID]bool
+	remote   map[uuid.UUID]*remoteWatch
+}
+
+type remoteWatch struct {
+	target   Address
+	host     uuid.UUID
+	watchers map[uuid.UUID]bool
+}
+
+func newDeathWatch() *deathWatch {
+	return &deathWatch{watchers: make(map[uuid.UUID]map[uuid.UUID]bool), remote: make(map[uuid.UUID]*remoteWatch)}
+}
+
+func (w *deathWatch) add(target, watcher uuid.UUID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watchers[target] == nil {
+		w.watchers[target] = make(map[uuid.UUID]bool)
+	}
+	w.watchers[target][watcher] = true
+}
+
+func (w *deathWatch) remove(target, watcher uuid.UUID) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.watchers[target][watcher] {
+		return false
+	}
+	delete(w.watchers[target], watcher)
+	if len(w.watchers[target]) == 0 {
+		delete(w.watchers, target)
+	}
+	return true
+}
+
+// take removes and returns the watchers of target.
+func (w *deathWatch) take(target uuid.UUID) []uuid.UUID {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	watchers := make([]uuid.UUID, 0, len(w.watchers[target]))
+	for watcher := range w.watchers[target] {
+		watchers = append(watchers, watcher)
+	}
+	delete(w.watchers, target)
+	return watchers
+}
+
+func (w *deathWatch) addRemote(target Address, watcher uuid.UUID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rw := w.remote[target.ID()]
+	if rw == nil {
+		rw = &remoteWatch{target: target, watchers: make(map[uuid.UUID]bool)}
+		w.remote[target.ID()] = rw
+	}
+	rw.watchers[watcher] = true
+}
+
+func (w *deathWatch) removeRemote(target, watcher uuid.UUID) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rw := w.remote[target]
+	if rw == nil || !rw.watchers[watcher] {
+		return false
+	}
+	delete(rw.watchers, watcher)
+	if len(rw.watchers) == 0 {
+		delete(w.remote, target)
+	}
+	return true
+}
+
+func (w *deathWatch) setHost(target, host uuid.UUID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if rw := w.remote[target]; rw != nil {
+		rw.host = host
+	}
+}
+
+// takeHostedBy removes and returns the remote watches on actors held by host.
+func (w *deathWatch) takeHostedBy(host uuid.UUID) map[uuid.UUID][]uuid.UUID {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	lost := make(map[uuid.UUID][]uuid.UUID)
+	for target, rw := range w.remote {
+		if rw.host != host {
+			continue
+		}
+		for watcher := range rw.watchers {
+			lost[target] = append(lost[target], watcher)
+		}
+		delete(w.remote, target)
+	}
+	return lost
+}
+
+// forget drops every watch placed by watcher and returns the remote targets
+// that should be told.
+func (w *deathWatch) forget(watcher uuid.UUID) []Address {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for target, watchers := range w.watchers {
+		delete(watchers, watcher)
+		if len(watchers) == 0 {
+			delete(w.watchers, target)
+		}
+	}
+	var targets []Address
+	for target, rw := range w.remote {
+		if !rw.watchers[watcher] {
+			continue
+		}
+		targets = append(targets, rw.target)
+		delete(rw.watchers, watcher)
+		if len(rw.watchers) == 0 {
+			delete(w.remote, target)
+		}
+	}
+	return targets
+}
+
+// WatchActor makes watcher receive a Terminated mail once target is gone.
+// Targets held by another postman are watched through a WatchRequest.
+func (m *postmanImpl) WatchActor(ctx context.Context, watcher uuid.UUID, target Address) *core.CoreError {
+	if _, ok := m.actors.Load(watcher); !ok {
+		return core.NewCoreError(core.ERROR_CODE_ACTOR_NOT_FOUND, fmt.Sprintf("watcher not found: %s", (&watcher).String()))
+	}
+	id := target.ID()
+	if _, ok := m.actors.Load(id); ok {
+		m.watch.add(id, watcher)
+		// the target may have terminated in between
+		if _, ok := m.actors.Load(id); !ok && m.watch.remove(id, watcher) {
+			m.notifyWatcher(ctx, watcher, Terminated{Actor: id, Reason: TerminatedReason_NOT_FOUND})
+		}
+		return nil
+	}
+	m.watch.addRemote(target, watcher)
+	err := target.Transfer(ctx, NewMail[any](watcher, id, WatchRequest{Watcher: watcher, Target: id}, codec.JSON_CODEC, WithKind(MailKind_WATCH_REQUEST)))
+	if err != nil && m.watch.removeRemote(id, watcher) {
+		m.notifyWatcher(ctx, watcher, Terminated{Actor: id, Reason: TerminatedReason_UNREACHABLE, Cause: err.String()})
+	}
+	return nil
+}
+
+func (m *postmanImpl) UnwatchActor(ctx context.Context, watcher uuid.UUID, target Address) *core.CoreError {
+	id := target.ID()
+	if m.watch.remove(id, watcher) {
+		return nil
+	}
+	if m.watch.removeRemote(id, watcher) {
+		return target.Transfer(ctx, NewMail[any](watcher, id, WatchRequest{Watcher: watcher, Target: id, Unwatch: true}, codec.JSON_CODEC, WithKind(MailKind_WATCH_REQUEST)))
+	}
+	return nil
+}
+
+// Unreachable reports every actor held by the postman host as terminated to
+// the local watchers.
+func (m *postmanImpl) Unreachable(ctx context.Context, host uuid.UUID) {
+	for target, watchers := range m.watch.takeHostedBy(host) {
+		for _, watcher := range watchers {
+			m.notifyWatcher(ctx, watcher, Terminated{Actor: target, Reason: TerminatedReason_UNREACHABLE, Cause: fmt.Sprintf("postman %s unreachable", (&host).String())})
+		}
+	}
+}
+
+// control handles the death watch mail exchanged between postmen, it returns
+// false for mail that still has to be delivered.
+func (m *postmanImpl) control(ctx context.Context, mail Mail[any]) bool {
+	message := mail.Message()
+	switch mail.Kind() {
+	case MailKind_WATCH_REQUEST:
+		request, ok := AsMessage[WatchRequest](message)
+		if !ok {
+			log.ErrorF("malformed watch request: %v\n", message)
+		} else if request.Unwatch {
+			m.watch.remove(request.Target, request.Watcher)
+		} else if _, ok := m.actors.Load(request.Target); ok {
+			m.watch.add(request.Target, request.Watcher)
+			m.notifyWatcher(ctx, request.Watcher, WatchAck{Target: request.Target, Host: m.id})
+		} else {
+			m.notifyWatcher(ctx, request.Watcher, Terminated{Actor: request.Target, Reason: TerminatedReason_NOT_FOUND})
+		}
+		return true
+	case MailKind_WATCH_ACK:
+		if ack, ok := AsMessage[WatchAck](message); ok {
+			m.watch.setHost(ack.Target, ack.Host)
+		}
+		return true
+	case MailKind_POSTMAN_UNREACHABLE:
+		if unreachable, ok := AsMessage[PostmanUnreachable](message); ok {
+			m.Unreachable(ctx, unreachable.Unreachable)
+		}
+		return true
+	case MailKind_TERMINATED:
+		if terminated, ok := AsTerminated(message); ok {
+			m.watch.removeRemote(terminated.Actor, mail.Receiver())
+		}
+	}
+	return false
+}
+
+// terminated runs once an actor added to the postman terminated.
+func (m *postmanImpl) terminated(a Actor[Mail[any], any]) {
+	ctx := context.Background()
+	if !m.actors.CompareAndDelete(a.ID(), a) {
+		return
+	}
+	m.detach(ctx, a.ID())
+	notice := Terminated{Actor: a.ID(), Reason: TerminatedReason_STOPPED}
+	if reporter, ok := a.(interface{ failure() *core.CoreError }); ok {
+		if cause := reporter.failure(); cause != nil {
+			notice.Reason, notice.Cause = TerminatedReason_CRASHED, cause.String()
+		}
+	}
+	m.notifyWatchers(ctx, notice)
+}
+
+func (m *postmanImpl) notifyWatchers(ctx context.Context, notice Terminated) {
+	for _, watcher := range m.watch.take(notice.Actor) {
+		m.notifyWatcher(ctx, watcher, notice)
+	}
+}
+
+func (m *postmanImpl) notifyWatcher(ctx context.Context, watcher uuid.UUID, message any) {
+	sender, kind := m.id, MailKind_WATCH_ACK
+	if notice, ok := message.(Terminated); ok {
+		sender, kind = notice.Actor, MailKind_TERMINATED
+	}
+	if err := m.Deliver(ctx, NewMail[any](sender, watcher, message, codec.JSON_CODEC, WithKind(kind))); err != nil {
+		log.ErrorF("error while notify watcher %s: %s\n", (&watcher).String(), err.String())
+	}
+}
+
+func (a *actorImpl[T, D]) setPostman(postman Postman) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.postman = postman
+}
+
+// failure is the failure the actor last stopped its receive loop with.
+func (a *actorImpl[T, D]) failure() *core.CoreError {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lastFailure
+}
+
+func (a *actorImpl[T, D]) Watch(target Address) *core.CoreError {
+	postman, err := a.joinedPostman()
+	if err != nil {
+		return err
+	}
+	return postman.WatchActor(context.Background(), a.id, target)
+}
+
+func (a *actorImpl[T, D]) Unwatch(target Address) *core.CoreError {
+	postman, err := a.joinedPostman()
+	if err != nil {
+		return err
+	}
+	return postman.UnwatchActor(context.Background(), a.id, target)
+}
+
+func (a *actorImpl[T, D]) joinedPostman() (Postman, *core.CoreError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.postman == nil {
+		return nil, core.NewCoreError(core.ERROR_CODE_POSTMAN_NOT_FOUND, fmt.Sprintf("actor %s is not added to a postman", (&a.id).String()))
+	}
+	return a.postman, nil
+}
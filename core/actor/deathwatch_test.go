@@ -0,0 +1,154 @@
+package actor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeDeathWatch uuid.UUIDType = "death_watch"
+
+func expectTerminatedMail(t *testing.T, received chan any, target uuid.UUID, reason TerminatedReason) Terminated {
+	select {
+	case message := <-received:
+		notice, ok := AsTerminated(message)
+		assert.True(t, ok, "expected Terminated, got %v", message)
+		assert.Equal(t, target, notice.Actor)
+		assert.Equal(t, reason, notice.Reason)
+		return notice
+	case <-time.After(time.Second):
+		t.Fatalf("expected Terminated with reason %s", reason)
+	}
+	return Terminated{}
+}
+
+// idOn draws ids until the ring routes one to the postman.
+func idOn(ring library.ConsistentHash[Address], idGen uuid.SimpleUUIDGenerator, postman Postman) uuid.UUID {
+	for {
+		id := idGen.Next()
+		if a, ok := ring.Get((&id).String()); ok && a.ID() == postman.ID() {
+			return id
+		}
+	}
+}
+
+func TestDeathWatch_Local(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeDeathWatch)
+	ctx := context.Background()
+	pm := NewPostman()
+	watcher, received := newReceivingActor(idGen.Next())
+	defer watcher.Stop()
+	pm.Add(ctx, watcher)
+
+	stopped, _ := newReceivingActor(idGen.Next())
+	removed, _ := newReceivingActor(idGen.Next())
+	defer removed.Stop()
+	pm.Add(ctx, stopped)
+	pm.Add(ctx, removed)
+	assert.Nil(t, watcher.Watch(NewActorAddress(stopped.ID(), NewLocalPostManAddress(pm))))
+	assert.Nil(t, watcher.Watch(NewActorAddress(removed.ID(), NewLocalPostManAddress(pm))))
+
+	stopped.Stop()
+	expectTerminatedMail(t, received, stopped.ID(), TerminatedReason_STOPPED)
+	assert.Nil(t, pm.Remove(ctx, removed.ID()))
+	expectTerminatedMail(t, received, removed.ID(), TerminatedReason_REMOVED)
+
+	unknown := idGen.Next()
+	assert.Nil(t, watcher.Watch(NewActorAddress(unknown, NewLocalPostManAddress(pm))))
+	expectTerminatedMail(t, received, unknown, TerminatedReason_NOT_FOUND)
+}
+
+func TestDeathWatch_Crash(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeDeathWatch)
+	ctx := context.Background()
+	pm := NewPostman()
+	watcher, received := newReceivingActor(idGen.Next())
+	defer watcher.Stop()
+	pm.Add(ctx, watcher)
+
+	parent := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	defer parent.Stop()
+	parent.SetSupervisorStrategy(NewSupervisorStrategy(SupervisorStrategyType_ONE_FOR_ONE, -1, time.Minute,
+		func(cause *core.CoreError) SupervisorDirective { return SupervisorDirective_STOP }))
+	session := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	parent.Supervise(session)
+	session.Start(func(mail Mail[any]) {
+		panic("session lost")
+	})
+	pm.Add(ctx, session)
+	assert.Nil(t, watcher.Watch(NewActorAddress(session.ID(), NewLocalPostManAddress(pm))))
+
+	sendTo(t, session, "boom")
+	notice := expectTerminatedMail(t, received, session.ID(), TerminatedReason_CRASHED)
+	assert.Contains(t, notice.Cause, "session lost")
+}
+
+func TestDeathWatch_AcrossRing(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeDeathWatch)
+	ctx := context.Background()
+	ring := library.NewConsistentHash[Address](150, nil, nil)
+	po := NewPostoffice(ring, idGen.Next())
+	rooms := NewPostman(WithPostmanID(idGen.Next()))
+	sessions := NewPostman(WithPostmanID(idGen.Next()))
+	roomsAddr, sessionsAddr := NewLocalPostManAddress(rooms), NewLocalPostManAddress(sessions)
+	po.Add(ctx, roomsAddr)
+	po.Add(ctx, sessionsAddr)
+	rooms.Register(ctx, NewLocalPostOfficeAddress(po))
+	sessions.Register(ctx, NewLocalPostOfficeAddress(po))
+
+	room, received := newReceivingActor(idOn(ring, idGen, rooms))
+	defer room.Stop()
+	rooms.Add(ctx, room)
+	first, _ := newReceivingActor(idOn(ring, idGen, sessions))
+	second, _ := newReceivingActor(idOn(ring, idGen, sessions))
+	defer second.Stop()
+	sessions.Add(ctx, first)
+	sessions.Add(ctx, second)
+
+	via := NewLocalPostOfficeAddress(po)
+	assert.Nil(t, room.Watch(NewActorAddress(first.ID(), via)))
+	assert.Nil(t, room.Watch(NewActorAddress(second.ID(), via)))
+	first.Stop()
+	expectTerminatedMail(t, received, first.ID(), TerminatedReason_STOPPED)
+
+	missing := idOn(ring, idGen, sessions)
+	assert.Nil(t, room.Watch(NewActorAddress(missing, via)))
+	expectTerminatedMail(t, received, missing, TerminatedReason_NOT_FOUND)
+
+	assert.Nil(t, po.Remove(ctx, sessionsAddr))
+	expectTerminatedMail(t, received, second.ID(), TerminatedReason_UNREACHABLE)
+}
+
+func TestDeathWatch_RequiresPostman(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeDeathWatch)
+	a, _ := newReceivingActor(idGen.Next())
+	defer a.Stop()
+	err := a.Watch(NewActorAddress(idGen.Next(), NewLocalPostManAddress(NewPostman())))
+	assert.Equal(t, core.ERROR_CODE_POSTMAN_NOT_FOUND, err.Code())
+}
+
+func TestDeathWatch_UserMailLookingLikeControl(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeDeathWatch)
+	ctx := context.Background()
+	pm := NewPostman()
+	a, received := newReceivingActor(idGen.Next())
+	defer a.Stop()
+	pm.Add(ctx, a)
+
+	// 字段与控制邮件相同的用户消息必须原样投递，而不是被 postman 吞掉
+	messages := []any{
+		map[string]any{"Watcher": "w", "Target": "t"},
+		map[string]any{"Target": "t", "Host": "h"},
+		map[string]any{"Unreachable": "u"},
+	}
+	for _, message := range messages {
+		assert.Nil(t, pm.Receive(ctx, NewMail[any](idGen.Next(), a.ID(), message, codec.JSON_CODEC)))
+		expectMessage(t, received, message)
+	}
+}
@@ -0,0 +1,135 @@
+package actor
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+)
+
+// MaxFrameSize limits a single frame read from a channel.
+const MaxFrameSize = 16 << 20
+
+// MailEnvelope is the wire form of a Mail.
+type MailEnvelope struct {
+	Sender        uuid.UUID
+	Receiver      uuid.UUID
+	Message       any
+	CorrelationID int64
+	Seq           int64         `json:",omitempty"`
+	Ack           int64         `json:",omitempty"`
+	Epoch         int64         `json:",omitempty"`
+	Trace         *TraceContext `json:",omitempty"`
+	Kind          MailKind      `json:",omitempty"`
+}
+
+// EncodeMail serializes mail with its own codec. The first byte carries the
+// codec type so the receiving side knows how to decode the rest.
+func EncodeMail(mail Mail[any]) ([]byte, error) {
+	envelope := MailEnvelope{
+		Sender:        mail.Sender(),
+		Receiver:      mail.Receiver(),
+		Message:       mail.Message(),
+		CorrelationID: mail.CorrelationID(),
+		Seq:           mail.Seq(),
+		Ack:           mail.Ack(),
+		Epoch:         mail.Epoch(),
+		Kind:          mail.Kind(),
+	}
+	if trace := mail.Trace(); trace.Valid() {
+		envelope.Trace = &trace
+	}
+	body, err := newEnvelopeCodec(mail.CodeC())
+	if err != nil {
+		return nil, err
+	}
+	data, err := body.Encode(envelope)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{byte(mail.CodeC())}, data...), nil
+}
+
+func DecodeMail(data []byte) (Mail[any], error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty mail")
+	}
+	codecType := codec.CODEC_TYPE(data[0])
+	body, err := newEnvelopeCodec(codecType)
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := body.Decode(data[1:])
+	if err != nil {
+		return nil, err
+	}
+	opts := []MailOption{WithKind(envelope.Kind), WithCorrelationID(envelope.CorrelationID), WithSeq(envelope.Seq), WithAck(envelope.Ack), WithEpoch(envelope.Epoch)}
+	if envelope.Trace != nil {
+		opts = append(opts, WithTrace(*envelope.Trace))
+	}
+	return NewMail(envelope.Sender, envelope.Receiver, envelope.Message, codecType, opts...), nil
+}
+
+func newEnvelopeCodec(codecType codec.CODEC_TYPE) (c codec.Codec[MailEnvelope], err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return codec.NewCodec[MailEnvelope](codecType), nil
+}
+
+// writeFrame writes payload prefixed by its length as a big endian uint32.
+func writeFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("frame size %d exceeds limit %d", size, MaxFrameSize)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// AsMessage returns message as T. It also accepts the generic map a struct
+// message turns into after crossing the wire, as long as it has all keys.
+func AsMessage[T any](message any, keys ...string) (T, bool) {
+	var zero T
+	switch m := message.(type) {
+	case T:
+		return m, true
+	case map[string]any:
+		for _, key := range keys {
+			if _, ok := m[key]; !ok {
+				return zero, false
+			}
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			return zero, false
+		}
+		var value T
+		if json.Unmarshal(data, &value) != nil {
+			return zero, false
+		}
+		return value, true
+	}
+	return zero, false
+}
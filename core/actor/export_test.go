@@ -0,0 +1,20 @@
+package actor
+
+// Hooks for the tests in package actor_test, they use core/testkit which
+// imports this package.
+
+var (
+	WriteFrame = writeFrame
+	ReadFrame  = readFrame
+)
+
+// MailboxLen is the number of mail queued for an actor on a memory mailbox.
+func MailboxLen(a Actor[Mail[any], any]) int {
+	return a.Mailbox().(*memoryMailbox[Mail[any]]).Len()
+}
+
+// AddWorker makes worker a routee of router, mailed directly like the
+// workers of a pool.
+func AddWorker(router Router, worker Actor[Mail[any], any]) {
+	router.(*routerImpl).addRoutee(&routee{address: NewActorAddress(worker.ID(), nil), worker: worker})
+}
@@ -0,0 +1,496 @@
+package actor
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+type FsyncPolicy string
+
+const FsyncPolicy_ALWAYS FsyncPolicy = "always"
+const FsyncPolicy_INTERVAL FsyncPolicy = "interval"
+const FsyncPolicy_NEVER FsyncPolicy = "never"
+
+const segmentSuffix = ".log"
+const consumerOffsetFile = "consumer.offset"
+const recordHeaderSize = 8
+
+type FileMailboxConfig struct {
+	Dir           string
+	SegmentSize   int64
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration
+}
+
+var DefaultFileMailboxConfig = FileMailboxConfig{
+	Dir:           filepath.Join(os.TempDir(), "actor-mailbox"),
+	SegmentSize:   64 << 20,
+	Fsync:         FsyncPolicy_INTERVAL,
+	FsyncInterval: time.Second,
+}
+
+// fileMailbox is an append only log split into segments named after the
+// offset of their first record. Every record is [length][crc32][mail], the
+// mail encoded by EncodeMail with the codec of the mail itself. Received mail
+// is only committed on Ack, so after a crash the actor resumes from the last
+// acknowledged mail. Segments fully below the committed offset are removed.
+// Like the memory mailbox, Receive still returns the mail appended before
+// Close, so an actor stopped with StopPolicy_DRAIN processes it.
+type fileMailbox struct {
+	id     uuid.UUID
+	dir    string
+	config FileMailboxConfig
+
+	mu         sync.Mutex
+	segments   []int64
+	writer     *os.File
+	writeSize  int64
+	nextOffset int64
+	dirty      bool
+
+	readFile   *os.File
+	reader     *bufio.Reader
+	readBase   int64
+	readOffset int64
+	committed  int64
+
+	deadLetters DeadLetterOffice
+
+	notify chan struct{}
+	closed chan struct{}
+	send   *mailQueue[Mail[any]]
+	wg     sync.WaitGroup
+}
+
+func NewFileMailbox(id uuid.UUID, config FileMailboxConfig) (Mailbox[Mail[any]], *core.CoreError) {
+	mb := &fileMailbox{
+		id:     id,
+		dir:    filepath.Join(config.Dir, fmt.Sprintf("actor-%s-%d", id.Type, id.ID)),
+		config: config,
+		notify: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+		send:   newMailQueue[Mail[any]](DefaultMailboxCapacity, OverflowPolicy_BLOCK, nil),
+	}
+	if err := mb.open(); err != nil {
+		return nil, core.NewCoreError(core.ERROR_CODE_MAILBOX_RECEIVE_ERROR, fmt.Sprintf("error while open file mailbox %s: %v", mb.dir, err))
+	}
+	if config.Fsync == FsyncPolicy_INTERVAL && config.FsyncInterval > 0 {
+		mb.wg.Add(1)
+		go mb.syncLoop()
+	}
+	return mb, nil
+}
+
+func (mb *fileMailbox) open() error {
+	if err := os.MkdirAll(mb.dir, 0755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(mb.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		base, err := strconv.ParseInt(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err == nil {
+			mb.segments = append(mb.segments, base)
+		}
+	}
+	sort.Slice(mb.segments, func(i, j int) bool { return mb.segments[i] < mb.segments[j] })
+	if len(mb.segments) == 0 {
+		mb.segments = []int64{0}
+	}
+	if err := mb.recover(); err != nil {
+		return err
+	}
+	if data, err := os.ReadFile(filepath.Join(mb.dir, consumerOffsetFile)); err == nil {
+		mb.committed, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+	if mb.committed < mb.segments[0] {
+		mb.committed = mb.segments[0]
+	}
+	if mb.committed > mb.nextOffset {
+		mb.committed = mb.nextOffset
+	}
+	mb.readOffset = mb.committed
+	return nil
+}
+
+// recover counts the valid records of the last segment and cuts off a
+// record torn by a crash in the middle of a write.
+func (mb *fileMailbox) recover() error {
+	base := mb.segments[len(mb.segments)-1]
+	file, err := os.OpenFile(mb.segmentPath(base), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	var size, count int64
+	for {
+		payload, err := readRecord(reader)
+		if err != nil {
+			break
+		}
+		size += int64(recordHeaderSize + len(payload))
+		count++
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	mb.writer, mb.writeSize, mb.nextOffset = file, size, base+count
+	return nil
+}
+
+func (mb *fileMailbox) segmentPath(base int64) string {
+	return filepath.Join(mb.dir, fmt.Sprintf("%020d%s", base, segmentSuffix))
+}
+
+func (mb *fileMailbox) Send(ctx context.Context, data Mail[any]) error {
+	if mb.id != (uuid.UUID{}) && data.Receiver() != mb.id {
+		return mb.send.push(ctx, data)
+	}
+	payload, err := EncodeMail(data)
+	if err != nil {
+		return err
+	}
+	return mb.append(payload)
+}
+
+func (mb *fileMailbox) append(payload []byte) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if mb.isClosed() {
+		return ErrMailboxClosed
+	}
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	copy(record[recordHeaderSize:], payload)
+	if _, err := mb.writer.Write(record); err != nil {
+		return err
+	}
+	mb.writeSize += int64(len(record))
+	mb.nextOffset++
+	if mb.config.Fsync == FsyncPolicy_ALWAYS {
+		if err := mb.writer.Sync(); err != nil {
+			return err
+		}
+	} else {
+		mb.dirty = true
+	}
+	if mb.config.SegmentSize > 0 && mb.writeSize >= mb.config.SegmentSize {
+		if err := mb.rotate(); err != nil {
+			return err
+		}
+	}
+	select {
+	case mb.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (mb *fileMailbox) rotate() error {
+	if err := mb.writer.Sync(); err != nil {
+		return err
+	}
+	mb.writer.Close()
+	file, err := os.OpenFile(mb.segmentPath(mb.nextOffset), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	mb.segments = append(mb.segments, mb.nextOffset)
+	mb.writer, mb.writeSize, mb.dirty = file, 0, false
+	return nil
+}
+
+func (mb *fileMailbox) Receive(ctx context.Context) (Mail[any], error) {
+	for {
+		mb.mu.Lock()
+		payload, err := mb.next()
+		mb.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		if payload != nil {
+			return DecodeMail(payload)
+		}
+		select {
+		case <-mb.notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-mb.closed:
+			// next fails once the records appended before Close are drained
+		}
+	}
+}
+
+// next reads the record at readOffset, it returns nil when there is none yet.
+// Corrupt records are skipped and reported, see skipCorrupt.
+func (mb *fileMailbox) next() ([]byte, error) {
+	for {
+		if mb.readOffset >= mb.nextOffset {
+			if !mb.isClosed() {
+				return nil, nil
+			}
+			if mb.readFile != nil {
+				mb.readFile.Close()
+				mb.readFile, mb.reader = nil, nil
+			}
+			return nil, fmt.Errorf("channel closed")
+		}
+		var payload []byte
+		var err error
+		if mb.readFile == nil {
+			err = mb.seek(mb.readOffset)
+		}
+		if err == nil {
+			payload, err = readRecord(mb.reader)
+			if errors.Is(err, io.EOF) {
+				// the current segment is exhausted, continue with the following one
+				if err = mb.seek(mb.readOffset); err == nil {
+					payload, err = readRecord(mb.reader)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			// records below nextOffset are missing from their segment
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			mb.readOffset++
+			return payload, nil
+		}
+		if !errors.Is(err, errCorruptRecord) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		if err := mb.skipCorrupt(payload, err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// CorruptRecord is the message of the mail reported to the dead letter office
+// for a file mailbox record that could not be read.
+type CorruptRecord struct {
+	Offset int64
+	Data   []byte
+}
+
+// skipCorrupt moves readOffset past a corrupt record. With a checksum
+// mismatch the length is intact, so only that record is skipped. Otherwise
+// the record boundaries are lost and the rest of the segment is skipped, in
+// the last segment the writer moves on to a new one.
+func (mb *fileMailbox) skipCorrupt(data []byte, cause error) error {
+	offset := mb.readOffset
+	if errors.Is(cause, errChecksumMismatch) {
+		mb.readOffset++
+	} else {
+		if mb.readFile != nil {
+			mb.readFile.Close()
+			mb.readFile, mb.reader = nil, nil
+		}
+		idx := sort.Search(len(mb.segments), func(i int) bool { return mb.segments[i] > offset })
+		if idx < len(mb.segments) {
+			mb.readOffset = mb.segments[idx]
+		} else {
+			mb.readOffset = mb.nextOffset
+			if mb.writeSize > 0 && !mb.isClosed() {
+				if err := mb.rotate(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	err := core.NewCoreError(core.ERROR_CODE_MAILBOX_RECEIVE_ERROR, fmt.Sprintf("file mailbox %s skipped records [%d, %d): %v", mb.dir, offset, mb.readOffset, cause))
+	if mb.deadLetters == nil {
+		log.ErrorF("%s\n", err.String())
+		return nil
+	}
+	mb.deadLetters.Capture(context.Background(), NewMail[any](mb.id, mb.id, CorruptRecord{Offset: offset, Data: data}, codec.JSON_CODEC), err)
+	return nil
+}
+
+func (mb *fileMailbox) setDeadLetters(deadLetters DeadLetterOffice) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.deadLetters = deadLetters
+}
+
+// seek opens the segment holding offset and skips to that record.
+func (mb *fileMailbox) seek(offset int64) error {
+	if mb.readFile != nil {
+		mb.readFile.Close()
+		mb.readFile, mb.reader = nil, nil
+	}
+	idx := sort.Search(len(mb.segments), func(i int) bool { return mb.segments[i] > offset }) - 1
+	if idx < 0 {
+		return fmt.Errorf("offset %d was compacted", offset)
+	}
+	file, err := os.Open(mb.segmentPath(mb.segments[idx]))
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	mb.readFile, mb.reader, mb.readBase = file, reader, mb.segments[idx]
+	for i := mb.segments[idx]; i < offset; i++ {
+		// records before offset were handed out already, their checksum does not matter
+		if _, err := readRecord(reader); err != nil && !errors.Is(err, errChecksumMismatch) {
+			return err
+		}
+	}
+	return nil
+}
+
+var errCorruptRecord = errors.New("corrupt record")
+var errChecksumMismatch = fmt.Errorf("%w: checksum mismatch", errCorruptRecord)
+
+func readRecord(reader io.Reader) ([]byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("%w: size %d exceeds limit %d", errCorruptRecord, size, MaxFrameSize)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return payload, errChecksumMismatch
+	}
+	return payload, nil
+}
+
+// Ack commits every mail received so far and drops the segments that only
+// hold committed mail.
+func (mb *fileMailbox) Ack(ctx context.Context) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if mb.committed == mb.readOffset {
+		return nil
+	}
+	mb.committed = mb.readOffset
+	path := filepath.Join(mb.dir, consumerOffsetFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(mb.committed, 10)), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return mb.compact()
+}
+
+func (mb *fileMailbox) compact() error {
+	removed := 0
+	for removed < len(mb.segments)-1 && mb.segments[removed+1] <= mb.committed {
+		if mb.segments[removed] == mb.readBase && mb.readFile != nil {
+			mb.readFile.Close()
+			mb.readFile, mb.reader = nil, nil
+		}
+		if err := os.Remove(mb.segmentPath(mb.segments[removed])); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+	}
+	mb.segments = mb.segments[removed:]
+	return nil
+}
+
+func (mb *fileMailbox) syncLoop() {
+	defer mb.wg.Done()
+	ticker := time.NewTicker(mb.config.FsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mb.mu.Lock()
+			if mb.dirty {
+				if err := mb.writer.Sync(); err != nil {
+					log.ErrorF("error while sync file mailbox %s: %v\n", mb.dir, err)
+				}
+				mb.dirty = false
+			}
+			mb.mu.Unlock()
+		case <-mb.closed:
+			return
+		}
+	}
+}
+
+func (mb *fileMailbox) isClosed() bool {
+	select {
+	case <-mb.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (mb *fileMailbox) Gather(fn func(Mail[any])) {
+	go func() {
+		for {
+			data, err := mb.send.pop(context.Background())
+			if err != nil {
+				break
+			}
+			fn(data)
+		}
+	}()
+}
+
+func (mb *fileMailbox) Close(ctx context.Context) error {
+	mb.mu.Lock()
+	select {
+	case <-mb.closed:
+		mb.mu.Unlock()
+		return nil
+	default:
+	}
+	close(mb.closed)
+	var err error
+	if mb.config.Fsync != FsyncPolicy_NEVER {
+		err = mb.writer.Sync()
+	}
+	mb.writer.Close()
+	if mb.readFile != nil {
+		mb.readFile.Close()
+		mb.readFile, mb.reader = nil, nil
+	}
+	mb.mu.Unlock()
+	mb.wg.Wait()
+	mb.send.close()
+	return err
+}
+
+func (mb *fileMailbox) ID() uuid.UUID {
+	return mb.id
+}
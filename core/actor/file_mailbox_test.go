@@ -0,0 +1,211 @@
+package actor
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeFileMailbox uuid.UUIDType = "file_mailbox"
+
+func newTestFileMailbox(t *testing.T, config FileMailboxConfig, id uuid.UUID) Mailbox[Mail[any]] {
+	mb, err := NewFileMailbox(id, config)
+	assert.Nil(t, err)
+	return mb
+}
+
+func receiveMessage(t *testing.T, mb Mailbox[Mail[any]]) any {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	mail, err := mb.Receive(ctx)
+	assert.NoError(t, err)
+	if mail == nil {
+		return nil
+	}
+	return mail.Message()
+}
+
+func TestFileMailbox_ResumeFromAck(t *testing.T) {
+	config := FileMailboxConfig{Dir: t.TempDir(), SegmentSize: 1 << 20, Fsync: FsyncPolicy_ALWAYS}
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeFileMailbox)
+	owner := idGen.Next()
+	ctx := context.Background()
+
+	mb := newTestFileMailbox(t, config, owner)
+	for _, message := range []string{"first", "second", "third"} {
+		assert.NoError(t, mb.Send(ctx, NewMail[any](idGen.Next(), owner, message, codec.JSON_CODEC)))
+	}
+	assert.Equal(t, "first", receiveMessage(t, mb))
+	assert.NoError(t, mb.(MailboxAcker).Ack(ctx))
+	// second 已读取但未确认，重启后应重新投递
+	assert.Equal(t, "second", receiveMessage(t, mb))
+	assert.NoError(t, mb.Close(ctx))
+
+	mb = newTestFileMailbox(t, config, owner)
+	defer mb.Close(ctx)
+	assert.Equal(t, "second", receiveMessage(t, mb))
+	assert.Equal(t, "third", receiveMessage(t, mb))
+}
+
+func TestFileMailbox_RotationAndCompaction(t *testing.T) {
+	config := FileMailboxConfig{Dir: t.TempDir(), SegmentSize: 64, Fsync: FsyncPolicy_NEVER}
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeFileMailbox)
+	owner := idGen.Next()
+	ctx := context.Background()
+	mb := newTestFileMailbox(t, config, owner)
+	defer mb.Close(ctx)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, mb.Send(ctx, NewMail[any](idGen.Next(), owner, float64(i), codec.JSON_CODEC)))
+	}
+	dir := mb.(*fileMailbox).dir
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Greater(t, len(segments), 2, "small segment size should rotate")
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, float64(i), receiveMessage(t, mb))
+		assert.NoError(t, mb.(MailboxAcker).Ack(ctx))
+	}
+	segments, _ = filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Len(t, segments, 1, "acknowledged segments should be compacted")
+}
+
+func TestFileMailbox_TornWrite(t *testing.T) {
+	config := FileMailboxConfig{Dir: t.TempDir(), SegmentSize: 1 << 20, Fsync: FsyncPolicy_ALWAYS}
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeFileMailbox)
+	owner := idGen.Next()
+	ctx := context.Background()
+
+	mb := newTestFileMailbox(t, config, owner)
+	assert.NoError(t, mb.Send(ctx, NewMail[any](idGen.Next(), owner, "intact", codec.JSON_CODEC)))
+	path := mb.(*fileMailbox).segmentPath(0)
+	mb.Close(ctx)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	file.Write([]byte{0, 0, 0, 9, 1, 2})
+	file.Close()
+
+	mb = newTestFileMailbox(t, config, owner)
+	defer mb.Close(ctx)
+	assert.NoError(t, mb.Send(ctx, NewMail[any](idGen.Next(), owner, "after crash", codec.JSON_CODEC)))
+	assert.Equal(t, "intact", receiveMessage(t, mb))
+	assert.Equal(t, "after crash", receiveMessage(t, mb))
+}
+
+func TestFileMailbox_Actor(t *testing.T) {
+	config := DefaultFileMailboxConfig
+	DefaultFileMailboxConfig.Dir = t.TempDir()
+	t.Cleanup(func() { DefaultFileMailboxConfig = config })
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeFileMailbox)
+	received := make(chan any, 1)
+	a := NewActor[Mail[any], any](MailboxType_FILE, idGen.Next(), nil)
+	a.Start(func(mail Mail[any]) {
+		received <- mail.Message()
+	})
+	defer a.Stop()
+	sendTo(t, a, "durable")
+	expectMessage(t, received, "durable")
+
+	mb := a.Mailbox().(*fileMailbox)
+	assert.Eventually(t, func() bool {
+		mb.mu.Lock()
+		defer mb.mu.Unlock()
+		return mb.committed == 1
+	}, time.Second, 5*time.Millisecond, "processed mail should be acknowledged")
+}
+
+func TestFileMailbox_CreateError(t *testing.T) {
+	config := DefaultFileMailboxConfig
+	// 目录所在位置是一个普通文件，mailbox 建不起来
+	blocker := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(blocker, nil, 0644))
+	DefaultFileMailboxConfig.Dir = blocker
+	t.Cleanup(func() { DefaultFileMailboxConfig = config })
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeFileMailbox)
+	mb, err := NewMailboxE(MailboxType_FILE, idGen.Next())
+	assert.Nil(t, mb)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, core.ERROR_CODE_MAILBOX_RECEIVE_ERROR, err.Code())
+	}
+	assert.Panics(t, func() { NewMailbox(MailboxType_FILE, idGen.Next()) })
+}
+
+func TestFileMailbox_CorruptRecord(t *testing.T) {
+	config := FileMailboxConfig{Dir: t.TempDir(), SegmentSize: 1 << 20, Fsync: FsyncPolicy_ALWAYS}
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeFileMailbox)
+	owner := idGen.Next()
+	ctx := context.Background()
+
+	mb := newTestFileMailbox(t, config, owner)
+	defer mb.Close(ctx)
+	deadLetters := NewDeadLetterOffice(idGen.Next(), 0, false)
+	defer deadLetters.Stop()
+	mb.(*fileMailbox).setDeadLetters(deadLetters)
+	for _, message := range []string{"first", "second", "third"} {
+		assert.NoError(t, mb.Send(ctx, NewMail[any](idGen.Next(), owner, message, codec.JSON_CODEC)))
+	}
+
+	// 破坏第二条记录的最后一个字节，校验和不再匹配
+	file, err := os.OpenFile(mb.(*fileMailbox).segmentPath(0), os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	info, _ := file.Stat()
+	first := make([]byte, recordHeaderSize)
+	file.ReadAt(first, 0)
+	end := int64(recordHeaderSize) + int64(binary.BigEndian.Uint32(first))
+	second := make([]byte, recordHeaderSize)
+	file.ReadAt(second, end)
+	last := end + recordHeaderSize + int64(binary.BigEndian.Uint32(second)) - 1
+	assert.Less(t, last, info.Size())
+	file.WriteAt([]byte{'#'}, last)
+	file.Close()
+
+	assert.Equal(t, "first", receiveMessage(t, mb))
+	assert.Equal(t, "third", receiveMessage(t, mb))
+	assert.Eventually(t, func() bool { return len(deadLetters.Letters()) == 1 }, time.Second, 5*time.Millisecond)
+	letter := deadLetters.Letters()[0]
+	assert.Equal(t, core.ERROR_CODE_MAILBOX_RECEIVE_ERROR, letter.Cause.Code())
+	assert.Equal(t, int64(1), letter.Mail.Message().(CorruptRecord).Offset)
+
+	// 记录边界损坏时跳过该分段的剩余部分，之后写入的邮件仍可读取
+	fmb := mb.(*fileMailbox)
+	fmb.mu.Lock()
+	n, _ := fmb.writer.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	fmb.writeSize += int64(n)
+	fmb.nextOffset++
+	fmb.mu.Unlock()
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = mb.Receive(timeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoError(t, mb.Send(ctx, NewMail[any](idGen.Next(), owner, "fourth", codec.JSON_CODEC)))
+	assert.Equal(t, "fourth", receiveMessage(t, mb))
+	assert.Eventually(t, func() bool { return len(deadLetters.Letters()) == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestFileMailbox_ReceiveAfterClose(t *testing.T) {
+	config := FileMailboxConfig{Dir: t.TempDir(), SegmentSize: 1 << 20, Fsync: FsyncPolicy_NEVER}
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeFileMailbox)
+	owner := idGen.Next()
+	ctx := context.Background()
+
+	mb := newTestFileMailbox(t, config, owner)
+	for _, message := range []string{"first", "second"} {
+		assert.NoError(t, mb.Send(ctx, NewMail[any](idGen.Next(), owner, message, codec.JSON_CODEC)))
+	}
+	assert.NoError(t, mb.Close(ctx))
+	assert.ErrorIs(t, mb.Send(ctx, NewMail[any](idGen.Next(), owner, "late", codec.JSON_CODEC)), ErrMailboxClosed)
+	// 与内存邮箱一致，关闭前写入的邮件仍可读出
+	assert.Equal(t, "first", receiveMessage(t, mb))
+	assert.Equal(t, "second", receiveMessage(t, mb))
+	_, err := mb.Receive(ctx)
+	assert.Error(t, err)
+}
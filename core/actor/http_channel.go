@@ -0,0 +1,193 @@
+package actor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/shooyaaa/core"
+)
+
+// HttpChannelPath is where HttpChannelHandler is expected to be registered,
+// it is used when an http address carries no path.
+const HttpChannelPath = "/actor/mail"
+
+const httpChannelContentType = "application/x-actor-mail"
+
+// HttpChannelMaxBody bounds the body of a batch HttpChannelHandler accepts.
+var HttpChannelMaxBody int64 = 64 << 20
+
+// HttpChannelRetries is how many times HttpChannel resends the mails of a
+// batch the handler failed to deliver.
+var HttpChannelRetries = 2
+
+// HttpChannelResult is what HttpChannelHandler answers a batch with.
+type HttpChannelResult struct {
+	Received int `json:"received"`
+	Failed   int `json:"failed"`
+	// FailedIndices are the positions of the failed mails in the batch.
+	FailedIndices []int `json:"failed_indices,omitempty"`
+}
+
+var httpChannelClient = &http.Client{}
+
+type HttpChannelClient interface {
+	Send(ctx context.Context, data []byte) error
+	Receive(ctx context.Context) ([]byte, error)
+}
+
+// HttpChannel posts batches of length prefixed mails to a remote HttpChannelHandler.
+type HttpChannel struct {
+	addr   string
+	client HttpChannelClient
+}
+
+func (c *HttpChannel) Send(ctx context.Context, data []byte) *core.CoreError {
+	return c.SendBatch(ctx, [][]byte{data})
+}
+
+func (c *HttpChannel) SendBatch(ctx context.Context, batch [][]byte) *core.CoreError {
+	if c.client != nil {
+		body := &bytes.Buffer{}
+		for _, data := range batch {
+			writeFrame(body, data)
+		}
+		if err := c.client.Send(ctx, body.Bytes()); err != nil {
+			return core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, err.Error())
+		}
+		return nil
+	}
+	// only the mails the handler failed to deliver are sent again, the
+	// delivered ones must not be duplicated
+	pending := batch
+	for attempt := 0; ; attempt++ {
+		result, cerr := c.post(ctx, pending)
+		if cerr != nil {
+			return cerr
+		}
+		if result.Failed == 0 {
+			return nil
+		}
+		failed := make([][]byte, 0, len(result.FailedIndices))
+		for _, index := range result.FailedIndices {
+			if index >= 0 && index < len(pending) {
+				failed = append(failed, pending[index])
+			}
+		}
+		if attempt >= HttpChannelRetries || len(failed) == 0 {
+			return core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, fmt.Sprintf("%d of %d mails failed at %s", result.Failed, len(batch), c.addr))
+		}
+		pending = failed
+	}
+}
+
+// post sends batch in one request and returns what the handler answered.
+func (c *HttpChannel) post(ctx context.Context, batch [][]byte) (HttpChannelResult, *core.CoreError) {
+	var result HttpChannelResult
+	body := &bytes.Buffer{}
+	for _, data := range batch {
+		writeFrame(body, data)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url(), body)
+	if err != nil {
+		return result, core.NewCoreError(core.ERROR_CODE_ADDRESS_NOT_SUPPORTED, fmt.Sprintf("invalid http address %s: %v", c.addr, err))
+	}
+	req.Header.Set("Content-Type", httpChannelContentType)
+	resp, err := httpChannelClient.Do(req)
+	if err != nil {
+		return result, core.NewCoreError(core.ERROR_CODE_CHANNEL_CONNECT_ERROR, fmt.Sprintf("error while post to %s: %v", c.addr, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return result, core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, fmt.Sprintf("post to %s: %s", c.addr, resp.Status))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, fmt.Sprintf("invalid result from %s: %v", c.addr, err))
+	}
+	return result, nil
+}
+
+func (c *HttpChannel) Receive(ctx context.Context) ([]byte, *core.CoreError) {
+	if c.client != nil {
+		data, err := c.client.Receive(ctx)
+		if err != nil {
+			return nil, core.NewCoreError(core.ERROR_CODE_MAILBOX_RECEIVE_ERROR, err.Error())
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+func (c *HttpChannel) url() string {
+	u, err := url.Parse(c.addr)
+	if err != nil || (u.Path != "" && u.Path != "/") {
+		return c.addr
+	}
+	u.Path = HttpChannelPath
+	return u.String()
+}
+
+func NewHttpChannel(addr string) RpcChannel {
+	return &HttpChannel{addr: addr}
+}
+
+// HttpChannelHandler accepts mail batches posted by HttpChannel and routes
+// them to a local postman. Mount it with network.HttpServer.Register(HttpChannelPath, handler.ServeHTTP).
+type HttpChannelHandler struct {
+	postman Postman
+}
+
+func NewHttpChannelHandler(postman Postman) *HttpChannelHandler {
+	return &HttpChannelHandler{postman: postman}
+}
+
+func (h *HttpChannelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// the whole batch is decoded first, so a bad request delivers nothing and
+	// can be retried without duplicates
+	reader := bufio.NewReader(http.MaxBytesReader(w, r.Body, HttpChannelMaxBody))
+	var mails []Mail[any]
+	for {
+		payload, err := readFrame(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mail, err := DecodeMail(payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mails = append(mails, mail)
+	}
+	var result HttpChannelResult
+	for i, mail := range mails {
+		if cerr := h.postman.Receive(r.Context(), mail); cerr != nil {
+			result.Failed++
+			result.FailedIndices = append(result.FailedIndices, i)
+		} else {
+			result.Received++
+		}
+	}
+	data, _ := json.Marshal(result)
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(data)
+}
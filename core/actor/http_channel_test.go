@@ -0,0 +1,144 @@
+package actor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpChannel_RemoteAddress(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeChannelTest)
+	receiverID := idGen.Next()
+	pm, received := startReceiver(t, receiverID)
+	mux := http.NewServeMux()
+	mux.HandleFunc(HttpChannelPath, NewHttpChannelHandler(pm).ServeHTTP)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	remote := NewRemoteAddress(NewRpcAddress(server.URL), pm.ID())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, remote.Transfer(ctx, NewMail[any](idGen.Next(), receiverID, "single", codec.JSON_CODEC)))
+	batch := []Mail[any]{
+		NewMail[any](idGen.Next(), receiverID, "batch-1", codec.JSON_CODEC),
+		NewMail[any](idGen.Next(), receiverID, "batch-2", codec.JSON_CODEC),
+	}
+	assert.Nil(t, remote.(*RemoteAddress).TransferBatch(ctx, batch))
+
+	for _, expected := range []string{"single", "batch-1", "batch-2"} {
+		select {
+		case mail := <-received:
+			assert.Equal(t, expected, mail.Message())
+		case <-ctx.Done():
+			t.Fatal("mail should be delivered over http")
+		}
+	}
+}
+
+func TestHttpChannel_Rejects(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeChannelTest)
+	pm, _ := startReceiver(t, idGen.Next())
+	server := httptest.NewServer(NewHttpChannelHandler(pm))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	channel := NewHttpChannel(server.URL + "/other")
+	cerr := channel.Send(context.Background(), []byte{255})
+	assert.NotNil(t, cerr, "undecodable mail should be rejected")
+}
+
+func TestHttpChannel_Failures(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeChannelTest)
+	receiverID := idGen.Next()
+	pm, received := startReceiver(t, receiverID)
+	server := httptest.NewServer(NewHttpChannelHandler(pm))
+	defer server.Close()
+	channel := NewHttpChannel(server.URL).(*HttpChannel)
+	ctx := context.Background()
+
+	good, _ := EncodeMail(NewMail[any](idGen.Next(), receiverID, "good", codec.JSON_CODEC))
+	lost, _ := EncodeMail(NewMail[any](idGen.Next(), idGen.Next(), "lost", codec.JSON_CODEC))
+	cerr := channel.SendBatch(ctx, [][]byte{good, lost})
+	if assert.NotNil(t, cerr, "a failed delivery should be reported") {
+		assert.Contains(t, cerr.Msg(), "1 of 2")
+	}
+	select {
+	case mail := <-received:
+		assert.Equal(t, "good", mail.Message())
+	case <-time.After(time.Second):
+		t.Fatal("the good mail should be delivered")
+	}
+
+	// 批次中途解码失败时前面的 mail 也不投递
+	cerr = channel.SendBatch(ctx, [][]byte{good, {255}})
+	assert.NotNil(t, cerr)
+	select {
+	case mail := <-received:
+		t.Fatalf("nothing of a bad batch should be delivered, got %v", mail.Message())
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	body := &bytes.Buffer{}
+	writeFrame(body, good)
+	writeFrame(body, good)
+	limit := HttpChannelMaxBody
+	HttpChannelMaxBody = int64(body.Len() - 1)
+	defer func() { HttpChannelMaxBody = limit }()
+	resp, err := http.Post(server.URL, httpChannelContentType, body)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestHttpChannel_ResendFailed(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeChannelTest)
+	receiverID := idGen.Next()
+	pm, received := startReceiver(t, receiverID)
+	handler := NewHttpChannelHandler(pm)
+	var frames []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reader := bytes.NewReader(body)
+		count := 0
+		for {
+			if _, err := readFrame(reader); err != nil {
+				break
+			}
+			count++
+		}
+		frames = append(frames, count)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	channel := NewHttpChannel(server.URL).(*HttpChannel)
+
+	good, _ := EncodeMail(NewMail[any](idGen.Next(), receiverID, "good", codec.JSON_CODEC))
+	lost, _ := EncodeMail(NewMail[any](idGen.Next(), idGen.Next(), "lost", codec.JSON_CODEC))
+	assert.NotNil(t, channel.SendBatch(context.Background(), [][]byte{good, lost}))
+	// 只重发失败的那一封，已投递的不会重复
+	assert.Equal(t, []int{2, 1, 1}, frames)
+	select {
+	case mail := <-received:
+		assert.Equal(t, "good", mail.Message())
+	case <-time.After(time.Second):
+		t.Fatal("the good mail should be delivered")
+	}
+	select {
+	case mail := <-received:
+		t.Fatalf("the good mail should be delivered once, got %v", mail.Message())
+	case <-time.After(50 * time.Millisecond):
+	}
+}
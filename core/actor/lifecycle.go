@@ -0,0 +1,64 @@
+package actor
+
+import (
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/log"
+)
+
+// The actor data may implement any of these hooks.
+type PreStarter interface {
+	PreStart()
+}
+
+type PostStopper interface {
+	PostStop()
+}
+
+// PreRestarter runs after the receive loop was halted for a restart.
+type PreRestarter interface {
+	PreRestart(cause *core.CoreError)
+}
+
+// PostRestarter runs before the receive loop is launched again.
+type PostRestarter interface {
+	PostRestart(cause *core.CoreError)
+}
+
+type StopPolicy string
+
+// DISCARD stops right after the mail in progress and drops the pending mail,
+// DRAIN processes the mail already queued before stopping.
+const StopPolicy_DISCARD StopPolicy = "discard"
+const StopPolicy_DRAIN StopPolicy = "drain"
+
+func (a *actorImpl[T, D]) SetStopPolicy(policy StopPolicy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopPolicy = policy
+}
+
+// Terminated is closed once the actor stopped, its goroutine exited and
+// PostStop returned.
+func (a *actorImpl[T, D]) Terminated() <-chan struct{} {
+	return a.terminated
+}
+
+// terminate runs PostStop and closes Terminated, only the first call counts.
+func (a *actorImpl[T, D]) terminate() {
+	a.terminateOnce.Do(func() {
+		if hook, ok := any(a.data).(PostStopper); ok {
+			a.hook("PostStop", hook.PostStop)
+		}
+		close(a.terminated)
+	})
+}
+
+// hook runs a lifecycle hook, a panic is logged instead of killing the caller.
+func (a *actorImpl[T, D]) hook(name string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.ErrorF("actor %s panic in %s: %v\n", (&a.id).String(), name, r)
+		}
+	}()
+	fn()
+}
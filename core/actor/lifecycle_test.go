@@ -0,0 +1,149 @@
+package actor_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/testkit"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeLifecycle uuid.UUIDType = "lifecycle"
+
+type hookRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (h *hookRecorder) record(call string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call)
+}
+
+func (h *hookRecorder) Calls() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.calls...)
+}
+
+func (h *hookRecorder) PreStart() { h.record("PreStart") }
+func (h *hookRecorder) PostStop() { h.record("PostStop") }
+func (h *hookRecorder) PreRestart(cause *core.CoreError) {
+	h.record("PreRestart")
+}
+func (h *hookRecorder) PostRestart(cause *core.CoreError) {
+	h.record("PostRestart")
+}
+
+// tell puts message in the mailbox of a, sent by nobody.
+func tell(t *testing.T, a actor.Actor[actor.Mail[any], any], message any) {
+	t.Helper()
+	err := a.Mailbox().Send(context.Background(), actor.NewMail[any](uuid.UUID{}, a.ID(), message, codec.JSON_CODEC))
+	assert.NoError(t, err)
+}
+
+// forward hands message on to probe as mail from a.
+func forward(a actor.Actor[actor.Mail[any], any], probe *testkit.TestProbe, message any) {
+	probe.Mailbox().Send(context.Background(), actor.NewMail[any](a.ID(), probe.ID(), message, codec.JSON_CODEC))
+}
+
+func TestActor_LifecycleHooks(t *testing.T) {
+	testkit.VerifyNoLeaks(t)
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeLifecycle)
+	probe := testkit.NewTestProbe(t, idGen.Next())
+	hooks := &hookRecorder{}
+	parent := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, idGen.Next(), nil)
+	defer parent.Stop()
+	child := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, idGen.Next(), hooks)
+	parent.Supervise(child)
+
+	child.Start(func(mail actor.Mail[any]) {
+		if mail.Message() == "panic" {
+			panic("boom")
+		}
+		forward(child, probe, mail.Message())
+	})
+	tell(t, child, "panic")
+	tell(t, child, "after restart")
+	probe.ExpectMsg("after restart")
+	assert.Equal(t, []string{"PreStart", "PreRestart", "PostRestart"}, hooks.Calls())
+
+	child.Stop()
+	testkit.ExpectTerminated(t, child)
+	assert.Equal(t, []string{"PreStart", "PreRestart", "PostRestart", "PostStop"}, hooks.Calls())
+	child.Stop()
+	assert.Len(t, hooks.Calls(), 4, "stop should be idempotent")
+}
+
+// testStopPolicy stops an actor busy with the first of three mails, the
+// probe must get exactly the messages processed.
+func testStopPolicy(t *testing.T, policy actor.StopPolicy, processed ...any) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeLifecycle)
+	probe := testkit.NewTestProbe(t, idGen.Next())
+	a := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, idGen.Next(), nil)
+	a.SetStopPolicy(policy)
+	gate := make(chan struct{})
+	a.Start(func(mail actor.Mail[any]) {
+		<-gate
+		forward(a, probe, mail.Message())
+	})
+	for i := 0; i < 3; i++ {
+		tell(t, a, i)
+	}
+	// 等待第一封信进入处理函数
+	assert.Eventually(t, func() bool {
+		return actor.MailboxLen(a) == 2
+	}, time.Second, time.Millisecond)
+	a.Stop()
+	err := a.Mailbox().Send(context.Background(), actor.NewMail[any](uuid.UUID{}, a.ID(), "late", codec.JSON_CODEC))
+	assert.ErrorIs(t, err, actor.ErrMailboxClosed)
+	close(gate)
+	testkit.ExpectTerminated(t, a)
+	for _, message := range processed {
+		probe.ExpectMsg(message)
+	}
+	// 已终止，不会再有信转发过来
+	probe.ExpectNoMsg(20 * time.Millisecond)
+}
+
+func TestActor_StopDrain(t *testing.T) {
+	testStopPolicy(t, actor.StopPolicy_DRAIN, 0, 1, 2)
+}
+
+func TestActor_StopDiscard(t *testing.T) {
+	testStopPolicy(t, actor.StopPolicy_DISCARD, 0)
+}
+
+func TestActor_StopWithoutStart(t *testing.T) {
+	hooks := &hookRecorder{}
+	a := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, uuid.NewSimpleUUIDGenerator(UUIDTypeLifecycle).Next(), hooks)
+	a.Stop()
+	testkit.ExpectTerminated(t, a)
+	assert.Equal(t, []string{"PostStop"}, hooks.Calls())
+}
+
+func TestActor_NoGoroutineLeak(t *testing.T) {
+	testkit.VerifyNoLeaks(t)
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeLifecycle)
+	ctx := context.Background()
+	pm := actor.NewPostman()
+	actors := make([]actor.Actor[actor.Mail[any], any], 0, 50)
+	for i := 0; i < 50; i++ {
+		a := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, idGen.Next(), nil)
+		a.Start(func(mail actor.Mail[any]) {})
+		pm.Add(ctx, a)
+		actors = append(actors, a)
+	}
+	// receive loops and gatherers have to exit, VerifyNoLeaks checks it
+	for _, a := range actors {
+		a.Stop()
+		testkit.ExpectTerminated(t, a)
+	}
+}
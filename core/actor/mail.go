@@ -10,17 +10,102 @@ type Mail[M any] interface {
 	Receiver() uuid.UUID
 	Message() M
 	CodeC() codec.CODEC_TYPE
+	CorrelationID() int64
+	// Seq is set on mail sent through ReliableDelivery, Ack on its acknowledgement.
+	Seq() int64
+	Ack() int64
+	// Epoch tells the runs of the sequence of a sender apart, both mail and
+	// acknowledgement carry it.
+	Epoch() int64
+	// Trace is empty unless the mail belongs to a trace, see WithTrace.
+	Trace() TraceContext
+	// Kind is empty on user mail, see WithKind.
+	Kind() MailKind
+}
+
+// MailKind marks the system mail exchanged by postmen, mediators and nodes.
+// System mail is recognized by its kind, never by the shape of its message,
+// so a user message with the same fields is still delivered.
+type MailKind string
+
+const MailKind_WATCH_REQUEST MailKind = "watch_request"
+const MailKind_WATCH_ACK MailKind = "watch_ack"
+const MailKind_TERMINATED MailKind = "terminated"
+const MailKind_POSTMAN_UNREACHABLE MailKind = "postman_unreachable"
+const MailKind_NAME_BINDING MailKind = "name_binding"
+const MailKind_SUBSCRIBE MailKind = "subscribe"
+const MailKind_PUBLISH MailKind = "publish"
+const MailKind_SUBSCRIPTIONS MailKind = "subscriptions"
+const MailKind_TIMER MailKind = "timer"
+
+// mailHeader holds the optional metadata that travels with a mail.
+type mailHeader struct {
+	kind          MailKind
+	correlationID int64
+	seq           int64
+	ack           int64
+	epoch         int64
+	trace         TraceContext
+}
+
+type MailOption func(h *mailHeader)
+
+func WithKind(kind MailKind) MailOption {
+	return func(h *mailHeader) {
+		h.kind = kind
+	}
+}
+
+func WithCorrelationID(id int64) MailOption {
+	return func(h *mailHeader) {
+		h.correlationID = id
+	}
+}
+
+func WithSeq(seq int64) MailOption {
+	return func(h *mailHeader) {
+		h.seq = seq
+	}
+}
+
+func WithAck(ack int64) MailOption {
+	return func(h *mailHeader) {
+		h.ack = ack
+	}
+}
+
+func WithEpoch(epoch int64) MailOption {
+	return func(h *mailHeader) {
+		h.epoch = epoch
+	}
+}
+
+// WithTrace puts the mail in a trace, NewTraceContext starts one.
+func WithTrace(trace TraceContext) MailOption {
+	return func(h *mailHeader) {
+		h.trace = trace
+	}
 }
 
 type mailImpl[M any] struct {
+	mailHeader
 	sender   uuid.UUID
 	receiver uuid.UUID
 	message  M
 	codec    codec.CODEC_TYPE
 }
 
-func NewMail[M any](sender uuid.UUID, receiver uuid.UUID, message M, codec codec.CODEC_TYPE) Mail[M] {
-	return &mailImpl[M]{sender: sender, receiver: receiver, message: message, codec: codec}
+func NewMail[M any](sender uuid.UUID, receiver uuid.UUID, message M, codec codec.CODEC_TYPE, opts ...MailOption) Mail[M] {
+	m := &mailImpl[M]{sender: sender, receiver: receiver, message: message, codec: codec}
+	for _, opt := range opts {
+		opt(&m.mailHeader)
+	}
+	return m
+}
+
+// NewReply answers request, addressing the reply to its sender under the same correlation ID.
+func NewReply[M any](request Mail[any], message M) Mail[M] {
+	return NewMail(request.Receiver(), request.Sender(), message, request.CodeC(), WithCorrelationID(request.CorrelationID()), WithTrace(request.Trace()))
 }
 
 func (m *mailImpl[M]) CodeC() codec.CODEC_TYPE {
@@ -38,3 +123,27 @@ func (m *mailImpl[M]) Receiver() uuid.UUID {
 func (m *mailImpl[M]) Message() M {
 	return m.message
 }
+
+func (m *mailImpl[M]) CorrelationID() int64 {
+	return m.correlationID
+}
+
+func (m *mailImpl[M]) Seq() int64 {
+	return m.seq
+}
+
+func (m *mailImpl[M]) Ack() int64 {
+	return m.ack
+}
+
+func (m *mailImpl[M]) Epoch() int64 {
+	return m.epoch
+}
+
+func (m *mailImpl[M]) Trace() TraceContext {
+	return m.trace
+}
+
+func (m *mailImpl[M]) Kind() MailKind {
+	return m.kind
+}
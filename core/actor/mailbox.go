@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/shooyaaa/core"
 	"github.com/shooyaaa/core/uuid"
 )
 
@@ -16,6 +17,7 @@ const MailboxType_IPC MailboxType = "ipc"
 const MailboxType_UNIX MailboxType = "unix"
 const MailboxType_FILE MailboxType = "file"
 const MailboxType_PIPE MailboxType = "pipe"
+const MailboxType_PRIORITY MailboxType = "priority"
 
 type Mailbox[T Mail[any]] interface {
 	MailboxSender[T]
@@ -37,41 +39,111 @@ type MailboxCloser[T Mail[any]] interface {
 	Close(ctx context.Context) error
 }
 
+// MailboxAcker is implemented by durable mailboxes. The actor acknowledges
+// every mail it processed, so that mail is not received again after a restart.
+type MailboxAcker interface {
+	Ack(ctx context.Context) error
+}
+
+// MailboxPrepender puts mail back at the head of the queue, ahead of the mail
+// sent meanwhile. An actor needs it to unstash.
+type MailboxPrepender[T Mail[any]] interface {
+	Prepend(ctx context.Context, mails ...T) error
+}
+
+// MailboxOption configures the memory mailbox created by NewMemoryMailbox.
+type MailboxOption func(*mailboxOptions)
+
+type mailboxOptions struct {
+	capacity int
+	policy   OverflowPolicy
+	priority bool
+}
+
+const DefaultMailboxCapacity = 100
+
+// WithMailboxCapacity bounds the queued mail, zero or negative is unbounded.
+func WithMailboxCapacity(capacity int) MailboxOption {
+	return func(o *mailboxOptions) {
+		o.capacity = capacity
+	}
+}
+
+// WithOverflowPolicy decides what Send does once the capacity is reached.
+func WithOverflowPolicy(policy OverflowPolicy) MailboxOption {
+	return func(o *mailboxOptions) {
+		o.policy = policy
+	}
+}
+
+// WithPriority lets SystemMessage mail jump ahead of user mail.
+func WithPriority() MailboxOption {
+	return func(o *mailboxOptions) {
+		o.priority = true
+	}
+}
+
 type memoryMailbox[T Mail[any]] struct {
-	name   string
-	recvCh chan T
-	sendCh chan T
-	id     uuid.UUID
+	mailboxTrace
+	name string
+	recv *mailQueue[T]
+	send *mailQueue[T]
+	id   uuid.UUID
 }
 
-func NewMemoryMailbox[T Mail[any]](name string) Mailbox[T] {
-	return &memoryMailbox[T]{name: name, recvCh: make(chan T, 100), sendCh: make(chan T, 100)}
+func NewMemoryMailbox[T Mail[any]](name string, opts ...MailboxOption) Mailbox[T] {
+	options := mailboxOptions{capacity: DefaultMailboxCapacity, policy: OverflowPolicy_BLOCK}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	var isSystem func(T) bool
+	if options.priority {
+		isSystem = func(mail T) bool {
+			_, ok := mail.Message().(SystemMessage)
+			return ok
+		}
+	}
+	return &memoryMailbox[T]{
+		name: name,
+		recv: newMailQueue(options.capacity, options.policy, isSystem),
+		send: newMailQueue[T](options.capacity, options.policy, nil),
+	}
 }
 
+// Send queues mail addressed to the owner for Receive, mail addressed to
+// anybody else leaves through Gather. A mailbox without owner keeps everything.
+// A full mailbox is handled by its OverflowPolicy, a blocked Send gives up
+// when ctx is done.
 func (mb *memoryMailbox[T]) Send(ctx context.Context, data T) error {
-	mb.sendCh <- data
-	return nil
+	if mb.outgoing(data) {
+		return mb.send.push(ctx, any(mb.stamp(data)).(T))
+	}
+	return mb.recv.push(ctx, data)
+}
+
+func (mb *memoryMailbox[T]) outgoing(data T) bool {
+	return mb.id != (uuid.UUID{}) && data.Receiver() != mb.id
 }
 
 func (mb *memoryMailbox[T]) Receive(ctx context.Context) (T, error) {
-	data, ok := <-mb.recvCh
-	if !ok {
-		return data, fmt.Errorf("channel closed")
-	}
-	return data, nil
+	return mb.recv.pop(ctx)
+}
+
+func (mb *memoryMailbox[T]) Prepend(ctx context.Context, mails ...T) error {
+	return mb.recv.pushFront(mails...)
 }
 
 func (mb *memoryMailbox[T]) Close(ctx context.Context) error {
-	close(mb.recvCh)
-	close(mb.sendCh)
+	mb.recv.close()
+	mb.send.close()
 	return nil
 }
 
 func (mb *memoryMailbox[T]) Gather(fn func(T)) {
 	go func() {
 		for {
-			data, ok := <-mb.sendCh
-			if !ok {
+			data, err := mb.send.pop(context.Background())
+			if err != nil {
 				break
 			}
 			fn(data)
@@ -83,10 +155,46 @@ func (mb *memoryMailbox[T]) ID() uuid.UUID {
 	return mb.id
 }
 
-func NewMailbox(mailboxType MailboxType) Mailbox[Mail[any]] {
+// Len reports the mail waiting to be received.
+func (mb *memoryMailbox[T]) Len() int {
+	return mb.recv.len()
+}
+
+// Dropped reports the mail discarded by a drop overflow policy.
+func (mb *memoryMailbox[T]) Dropped() int64 {
+	mb.recv.mu.Lock()
+	defer mb.recv.mu.Unlock()
+	mb.send.mu.Lock()
+	defer mb.send.mu.Unlock()
+	return mb.recv.dropped + mb.send.dropped
+}
+
+// NewMailbox creates a mailbox owned by id, the options apply to memory and
+// priority mailboxes. It panics when the mailbox can't be created, use
+// NewMailboxE for mailboxes backed by sockets, pipes or files.
+func NewMailbox(mailboxType MailboxType, id uuid.UUID, opts ...MailboxOption) Mailbox[Mail[any]] {
+	mb, err := NewMailboxE(mailboxType, id, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("error while create %v mailbox: %s", mailboxType, err.String()))
+	}
+	return mb
+}
+
+// NewMailboxE is NewMailbox returning the error instead of panicking, hand
+// the mailbox to NewActorWithMailbox. An unknown type still panics.
+func NewMailboxE(mailboxType MailboxType, id uuid.UUID, opts ...MailboxOption) (Mailbox[Mail[any]], *core.CoreError) {
 	switch mailboxType {
-	case MailboxType_MEMORY:
-		return NewMemoryMailbox[Mail[any]](string(mailboxType))
+	case MailboxType_MEMORY, MailboxType_PRIORITY:
+		if mailboxType == MailboxType_PRIORITY {
+			opts = append(opts, WithPriority())
+		}
+		mb := NewMemoryMailbox[Mail[any]](string(mailboxType), opts...).(*memoryMailbox[Mail[any]])
+		mb.id = id
+		return mb, nil
+	case MailboxType_FILE:
+		return NewFileMailbox(id, DefaultFileMailboxConfig)
+	case MailboxType_UNIX, MailboxType_IPC, MailboxType_PIPE:
+		return NewChannelMailbox(mailboxType, id)
 	default:
 		panic(fmt.Sprintf("unknown mailbox type: %v", mailboxType))
 	}
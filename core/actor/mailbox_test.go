@@ -3,6 +3,7 @@ package actor
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/shooyaaa/core/codec"
 	"github.com/shooyaaa/core/uuid"
@@ -27,3 +28,81 @@ func TestMemoryMailbox(t *testing.T) {
 	mb.Close(context.Background())
 	assert.NoError(t, err)
 }
+
+func fillMailbox(t *testing.T, mb Mailbox[Mail[any]], messages ...any) {
+	for _, message := range messages {
+		assert.NoError(t, mb.Send(context.Background(), NewMail[any](uuid.UUID{}, uuid.UUID{}, message, codec.JSON_CODEC)))
+	}
+}
+
+func drainMailbox(mb Mailbox[Mail[any]]) []any {
+	var messages []any
+	for mb.(*memoryMailbox[Mail[any]]).Len() > 0 {
+		mail, _ := mb.Receive(context.Background())
+		messages = append(messages, mail.Message())
+	}
+	return messages
+}
+
+func TestMemoryMailbox_Overflow(t *testing.T) {
+	mb := NewMemoryMailbox[Mail[any]]("drop_newest", WithMailboxCapacity(2), WithOverflowPolicy(OverflowPolicy_DROP_NEWEST))
+	fillMailbox(t, mb, "a", "b", "c")
+	assert.Equal(t, []any{"a", "b"}, drainMailbox(mb))
+	assert.Equal(t, int64(1), mb.(*memoryMailbox[Mail[any]]).Dropped())
+
+	mb = NewMemoryMailbox[Mail[any]]("drop_oldest", WithMailboxCapacity(2), WithOverflowPolicy(OverflowPolicy_DROP_OLDEST))
+	fillMailbox(t, mb, "a", "b", "c")
+	assert.Equal(t, []any{"b", "c"}, drainMailbox(mb))
+
+	mb = NewMemoryMailbox[Mail[any]]("fail", WithMailboxCapacity(1), WithOverflowPolicy(OverflowPolicy_FAIL))
+	fillMailbox(t, mb, "a")
+	err := mb.Send(context.Background(), NewMail[any](uuid.UUID{}, uuid.UUID{}, "b", codec.JSON_CODEC))
+	assert.ErrorIs(t, err, ErrMailboxFull)
+}
+
+func TestMemoryMailbox_BlockHonorsContext(t *testing.T) {
+	mb := NewMemoryMailbox[Mail[any]]("block", WithMailboxCapacity(1))
+	fillMailbox(t, mb, "a")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := mb.Send(ctx, NewMail[any](uuid.UUID{}, uuid.UUID{}, "b", codec.JSON_CODEC))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- mb.Send(context.Background(), NewMail[any](uuid.UUID{}, uuid.UUID{}, "c", codec.JSON_CODEC))
+	}()
+	mail, err := mb.Receive(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "a", mail.Message())
+	assert.NoError(t, <-sent)
+	assert.Equal(t, []any{"c"}, drainMailbox(mb))
+
+	mb.Close(context.Background())
+	assert.ErrorIs(t, mb.Send(context.Background(), NewMail[any](uuid.UUID{}, uuid.UUID{}, "d", codec.JSON_CODEC)), ErrMailboxClosed)
+}
+
+func TestPriorityMailbox(t *testing.T) {
+	mb := NewMailbox(MailboxType_PRIORITY, uuid.UUID{}, WithMailboxCapacity(1), WithOverflowPolicy(OverflowPolicy_FAIL))
+	fillMailbox(t, mb, "user", StopMessage{})
+	assert.Equal(t, []any{StopMessage{}, "user"}, drainMailbox(mb))
+}
+
+func TestPriorityMailbox_StopJumpsQueue(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeSupervisor)
+	a := NewActor[Mail[any], any](MailboxType_PRIORITY, idGen.Next(), nil)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, a.Mailbox().Send(ctx, NewMail[any](idGen.Next(), a.ID(), i, codec.JSON_CODEC)))
+	}
+	assert.NoError(t, a.Mailbox().Send(ctx, NewMail[any](idGen.Next(), a.ID(), StopMessage{}, codec.JSON_CODEC)))
+
+	processed := make(chan any, 5)
+	a.Start(func(mail Mail[any]) {
+		processed <- mail.Message()
+	})
+	assert.Eventually(t, func() bool {
+		return a.Mailbox().Send(ctx, NewMail[any](idGen.Next(), a.ID(), "late", codec.JSON_CODEC)) != nil
+	}, time.Second, 5*time.Millisecond, "stop should close the mailbox")
+	assert.Len(t, processed, 0, "stop should be handled before queued user mail")
+}
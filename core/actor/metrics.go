@@ -0,0 +1,309 @@
+package actor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+// MetricsPath and ActorsPath are where Metrics is expected to be registered,
+// network.HttpServer.RegisterMetrics registers both.
+const MetricsPath = "/metrics"
+const ActorsPath = "/actors"
+
+// LatencyBuckets are the upper bounds in seconds of the processing latency
+// histogram.
+var LatencyBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// Metrics collects the numbers of the postmen created WithPostmanMetrics and
+// of the actors added to them. Series of an actor are dropped once it leaves
+// its postman.
+type Metrics interface {
+	// ServeHTTP writes every metric in the Prometheus text format.
+	ServeHTTP(w http.ResponseWriter, r *http.Request)
+	// ServeActors lists the live actors of every postman as json.
+	ServeActors(w http.ResponseWriter, r *http.Request)
+	track(postman *postmanImpl)
+	processed(postman *postmanImpl, actor uuid.UUID, latency time.Duration)
+	restarted(postman *postmanImpl, actor uuid.UUID)
+	forget(postman *postmanImpl, actor uuid.UUID)
+	deadLetter(postman *postmanImpl, cause *core.CoreError)
+	dispatchFailed(postman *postmanImpl, cause *core.CoreError)
+}
+
+// WithPostmanMetrics reports the postman and its actors to metrics.
+func WithPostmanMetrics(metrics Metrics) PostmanOption {
+	return func(m *postmanImpl) {
+		m.metrics = metrics
+		metrics.track(m)
+	}
+}
+
+type actorStats struct {
+	processed atomic.Int64
+	restarts  atomic.Int64
+	// buckets counts the latencies per LatencyBuckets bound, the last one is +Inf
+	buckets []atomic.Int64
+	nanos   atomic.Int64
+}
+
+// postmanStats holds the numbers of one postman. Actor numbers are updated
+// with atomics on every mail, the error counts are guarded by the mu of
+// metricsImpl.
+type postmanStats struct {
+	postman        *postmanImpl
+	actors         sync.Map
+	deadLetters    map[int]int64
+	dispatchErrors map[int]int64
+}
+
+type metricsImpl struct {
+	// stats finds the numbers of a postman without taking mu
+	stats   sync.Map
+	mu      sync.Mutex
+	postmen []*postmanStats
+}
+
+func NewMetrics() Metrics {
+	return &metricsImpl{}
+}
+
+func (s *metricsImpl) track(postman *postmanImpl) {
+	p := &postmanStats{
+		postman:        postman,
+		deadLetters:    make(map[int]int64),
+		dispatchErrors: make(map[int]int64),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.postmen = append(s.postmen, p)
+	s.stats.Store(postman, p)
+}
+
+// actor returns the numbers of an actor on postman, nil once it left the
+// postman.
+func (s *metricsImpl) actor(postman *postmanImpl, id uuid.UUID) *actorStats {
+	value, ok := s.stats.Load(postman)
+	if !ok {
+		return nil
+	}
+	p := value.(*postmanStats)
+	if a, ok := p.actors.Load(id); ok {
+		return a.(*actorStats)
+	}
+	if _, live := p.postman.actors.Load(id); !live {
+		return nil
+	}
+	a, _ := p.actors.LoadOrStore(id, &actorStats{buckets: make([]atomic.Int64, len(LatencyBuckets)+1)})
+	// forget may have run since the check, an actor that left is not kept
+	if _, live := p.postman.actors.Load(id); !live {
+		p.actors.Delete(id)
+		return nil
+	}
+	return a.(*actorStats)
+}
+
+func (s *metricsImpl) processed(postman *postmanImpl, actor uuid.UUID, latency time.Duration) {
+	a := s.actor(postman, actor)
+	if a == nil {
+		return
+	}
+	a.processed.Add(1)
+	a.nanos.Add(int64(latency))
+	a.buckets[sort.SearchFloat64s(LatencyBuckets, latency.Seconds())].Add(1)
+}
+
+func (s *metricsImpl) restarted(postman *postmanImpl, actor uuid.UUID) {
+	if a := s.actor(postman, actor); a != nil {
+		a.restarts.Add(1)
+	}
+}
+
+func (s *metricsImpl) forget(postman *postmanImpl, actor uuid.UUID) {
+	if p, ok := s.stats.Load(postman); ok {
+		p.(*postmanStats).actors.Delete(actor)
+	}
+}
+
+func (s *metricsImpl) deadLetter(postman *postmanImpl, cause *core.CoreError) {
+	s.count(postman, cause, func(p *postmanStats) map[int]int64 {
+		return p.deadLetters
+	})
+}
+
+func (s *metricsImpl) dispatchFailed(postman *postmanImpl, cause *core.CoreError) {
+	s.count(postman, cause, func(p *postmanStats) map[int]int64 {
+		return p.dispatchErrors
+	})
+}
+
+func (s *metricsImpl) count(postman *postmanImpl, cause *core.CoreError, counts func(p *postmanStats) map[int]int64) {
+	p, ok := s.stats.Load(postman)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts(p.(*postmanStats))[int(cause.Code())]++
+}
+
+// actorView is an actor as ServeActors lists it.
+type actorView struct {
+	ID           string `json:"id"`
+	MailboxDepth int    `json:"mailbox_depth"`
+	Processed    int64  `json:"processed"`
+	Restarts     int64  `json:"restarts"`
+	// buckets is nil for an actor that has not processed or restarted yet
+	buckets []int64
+	sum     float64
+}
+
+type postmanView struct {
+	Postman        string      `json:"postman"`
+	Actors         []actorView `json:"actors"`
+	deadLetters    map[int]int64
+	dispatchErrors map[int]int64
+}
+
+// snapshot copies the numbers of every live actor, sorted by postman and
+// actor, so they are written without holding any lock.
+func (s *metricsImpl) snapshot() []postmanView {
+	s.mu.Lock()
+	postmen := append([]*postmanStats(nil), s.postmen...)
+	views := make([]postmanView, 0, len(postmen))
+	for _, p := range postmen {
+		id := p.postman.ID()
+		views = append(views, postmanView{
+			Postman:        (&id).String(),
+			Actors:         make([]actorView, 0),
+			deadLetters:    maps.Clone(p.deadLetters),
+			dispatchErrors: maps.Clone(p.dispatchErrors),
+		})
+	}
+	s.mu.Unlock()
+	for i, p := range postmen {
+		view := &views[i]
+		p.postman.actors.Range(func(key, value any) bool {
+			actorID := key.(uuid.UUID)
+			a := actorView{ID: (&actorID).String()}
+			if mb, ok := value.(Actor[Mail[any], any]).Mailbox().(interface{ Len() int }); ok {
+				a.MailboxDepth = mb.Len()
+			}
+			if stats, ok := p.actors.Load(actorID); ok {
+				stats := stats.(*actorStats)
+				a.Processed, a.Restarts = stats.processed.Load(), stats.restarts.Load()
+				a.sum = time.Duration(stats.nanos.Load()).Seconds()
+				a.buckets = make([]int64, len(stats.buckets))
+				for b := range stats.buckets {
+					a.buckets[b] = stats.buckets[b].Load()
+				}
+			}
+			view.Actors = append(view.Actors, a)
+			return true
+		})
+		sort.Slice(view.Actors, func(i, j int) bool {
+			return view.Actors[i].ID < view.Actors[j].ID
+		})
+	}
+	return views
+}
+
+func (s *metricsImpl) ServeActors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	views := s.snapshot()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(views); err != nil {
+		log.ErrorF("error while encode actors: %v\n", err)
+	}
+}
+
+func (s *metricsImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.write(w)
+}
+
+func (s *metricsImpl) write(w io.Writer) {
+	views := s.snapshot()
+	header := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	header("postman_actors", "gauge", "Live actors of the postman.")
+	for _, view := range views {
+		fmt.Fprintf(w, "postman_actors{postman=%q} %d\n", view.Postman, len(view.Actors))
+	}
+	header("actor_mailbox_depth", "gauge", "Mail queued for the actor.")
+	for _, view := range views {
+		for _, a := range view.Actors {
+			fmt.Fprintf(w, "actor_mailbox_depth{postman=%q,actor=%q} %d\n", view.Postman, a.ID, a.MailboxDepth)
+		}
+	}
+	header("actor_processed_total", "counter", "Mail processed by the actor.")
+	for _, view := range views {
+		for _, a := range view.Actors {
+			fmt.Fprintf(w, "actor_processed_total{postman=%q,actor=%q} %d\n", view.Postman, a.ID, a.Processed)
+		}
+	}
+	header("actor_restarts_total", "counter", "Restarts of the actor by its supervisor.")
+	for _, view := range views {
+		for _, a := range view.Actors {
+			fmt.Fprintf(w, "actor_restarts_total{postman=%q,actor=%q} %d\n", view.Postman, a.ID, a.Restarts)
+		}
+	}
+
+	header("actor_processing_seconds", "histogram", "Time the actor took to process a mail.")
+	for _, view := range views {
+		for _, a := range view.Actors {
+			if a.buckets == nil {
+				continue
+			}
+			var cumulative int64
+			for b, bound := range LatencyBuckets {
+				cumulative += a.buckets[b]
+				fmt.Fprintf(w, "actor_processing_seconds_bucket{postman=%q,actor=%q,le=%q} %d\n", view.Postman, a.ID, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+			}
+			cumulative += a.buckets[len(LatencyBuckets)]
+			fmt.Fprintf(w, "actor_processing_seconds_bucket{postman=%q,actor=%q,le=\"+Inf\"} %d\n", view.Postman, a.ID, cumulative)
+			fmt.Fprintf(w, "actor_processing_seconds_sum{postman=%q,actor=%q} %s\n", view.Postman, a.ID, strconv.FormatFloat(a.sum, 'g', -1, 64))
+			fmt.Fprintf(w, "actor_processing_seconds_count{postman=%q,actor=%q} %d\n", view.Postman, a.ID, cumulative)
+		}
+	}
+	writeCodes := func(name, help string, counts func(view postmanView) map[int]int64) {
+		header(name, "counter", help)
+		for _, view := range views {
+			c := counts(view)
+			codes := make([]int, 0, len(c))
+			for code := range c {
+				codes = append(codes, code)
+			}
+			sort.Ints(codes)
+			for _, code := range codes {
+				fmt.Fprintf(w, "%s{postman=%q,code=\"%d\"} %d\n", name, view.Postman, code, c[code])
+			}
+		}
+	}
+	writeCodes("postman_dead_letters_total", "Mail the postman could not deliver, by error code.", func(view postmanView) map[int]int64 {
+		return view.deadLetters
+	})
+	writeCodes("postman_dispatch_errors_total", "Mail the postman failed to dispatch, by error code.", func(view postmanView) map[int]int64 {
+		return view.dispatchErrors
+	})
+}
@@ -0,0 +1,97 @@
+package actor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeMetrics uuid.UUIDType = "metrics"
+
+func scrape(t *testing.T, metrics Metrics) string {
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", MetricsPath, nil))
+	assert.Equal(t, 200, recorder.Code)
+	return recorder.Body.String()
+}
+
+func listActors(t *testing.T, metrics Metrics) []postmanView {
+	recorder := httptest.NewRecorder()
+	metrics.ServeActors(recorder, httptest.NewRequest("GET", ActorsPath, nil))
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var views []postmanView
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &views))
+	return views
+}
+
+func TestMetrics_Actors(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeMetrics)
+	ctx := context.Background()
+	metrics := NewMetrics()
+	pmID := idGen.Next()
+	pm := NewPostman(WithPostmanID(pmID), WithPostmanMetrics(metrics))
+
+	gate := make(chan struct{})
+	room := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	room.Start(func(mail Mail[any]) {
+		<-gate
+	})
+	defer room.Stop()
+	pm.Add(ctx, room)
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, pm.Deliver(ctx, NewMail[any](uuid.UUID{}, room.ID(), i, codec.JSON_CODEC)))
+	}
+	roomID := room.ID()
+	// 房间阻塞在第一封邮件上，其余的积压在邮箱里
+	assert.Eventually(t, func() bool {
+		views := listActors(t, metrics)
+		return len(views) == 1 && len(views[0].Actors) == 1 && views[0].Actors[0].MailboxDepth == 4
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, (&pmID).String(), listActors(t, metrics)[0].Postman)
+	assert.Equal(t, (&roomID).String(), listActors(t, metrics)[0].Actors[0].ID)
+
+	close(gate)
+	labels := fmt.Sprintf("postman=%q,actor=%q", (&pmID).String(), (&roomID).String())
+	assert.Eventually(t, func() bool {
+		views := listActors(t, metrics)
+		return views[0].Actors[0].Processed == 5
+	}, time.Second, 5*time.Millisecond)
+	text := scrape(t, metrics)
+	assert.Contains(t, text, "# TYPE actor_processing_seconds histogram\n")
+	assert.Contains(t, text, "actor_mailbox_depth{"+labels+"} 0\n")
+	assert.Contains(t, text, "actor_processed_total{"+labels+"} 5\n")
+	assert.Contains(t, text, "actor_processing_seconds_bucket{"+labels+",le=\"+Inf\"} 5\n")
+	assert.Contains(t, text, "actor_processing_seconds_count{"+labels+"} 5\n")
+	assert.Contains(t, text, fmt.Sprintf("postman_actors{postman=%q} 1\n", (&pmID).String()))
+
+	room.(SupervisedActor).Restart(nil)
+	assert.Contains(t, scrape(t, metrics), "actor_restarts_total{"+labels+"} 1\n")
+
+	assert.Nil(t, pm.Remove(ctx, roomID))
+	assert.Empty(t, listActors(t, metrics)[0].Actors)
+	assert.NotContains(t, scrape(t, metrics), labels)
+}
+
+func TestMetrics_Errors(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeMetrics)
+	ctx := context.Background()
+	metrics := NewMetrics()
+	pmID := idGen.Next()
+	pm := NewPostman(WithPostmanID(pmID), WithPostmanMetrics(metrics))
+	postman := (&pmID).String()
+
+	assert.NotNil(t, pm.Receive(ctx, NewMail[any](uuid.UUID{}, idGen.Next(), "lost", codec.JSON_CODEC)))
+	assert.NotNil(t, pm.Deliver(ctx, NewMail[any](uuid.UUID{}, idGen.Next(), "nowhere", codec.JSON_CODEC)))
+	text := scrape(t, metrics)
+	assert.Contains(t, text, fmt.Sprintf("postman_dead_letters_total{postman=%q,code=\"%d\"} 1\n", postman, core.ERROR_CODE_ACTOR_NOT_FOUND))
+	assert.Contains(t, text, fmt.Sprintf("postman_dead_letters_total{postman=%q,code=\"%d\"} 1\n", postman, core.ERROR_CODE_POSTOFFICE_NOT_REGISTERED))
+	assert.Contains(t, text, fmt.Sprintf("postman_dispatch_errors_total{postman=%q,code=\"%d\"} 1\n", postman, core.ERROR_CODE_POSTOFFICE_NOT_REGISTERED))
+}
@@ -0,0 +1,176 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/uuid"
+)
+
+const AddressType_ACTOR AddressType = "actor"
+
+// ValidateActorName checks a hierarchical name such as /user/room/42.
+func ValidateActorName(name string) *core.CoreError {
+	if !strings.HasPrefix(name, "/") || len(name) < 2 || strings.HasSuffix(name, "/") {
+		return core.NewCoreError(core.ERROR_CODE_NAME_INVALID, fmt.Sprintf("actor name must look like /a/b: %q", name))
+	}
+	for _, segment := range strings.Split(name[1:], "/") {
+		if segment == "" || strings.ContainsAny(segment, " \t\r\n") {
+			return core.NewCoreError(core.ERROR_CODE_NAME_INVALID, fmt.Sprintf("invalid segment in actor name: %q", name))
+		}
+	}
+	return nil
+}
+
+// NameEvent reports a change of a name, Current is nil once the name is unbound.
+type NameEvent struct {
+	Name     string
+	Previous Address
+	Current  Address
+}
+
+type NameResolver interface {
+	Lookup(name string) (Address, bool)
+}
+
+type NameRegistry interface {
+	NameResolver
+	Bind(name string, a Address) *core.CoreError
+	Unbind(name string) bool
+	// Names lists the names bound to the actor id.
+	Names(id uuid.UUID) []string
+	// Watch calls fn on every later change of name until cancel is called.
+	Watch(name string, fn func(NameEvent)) (cancel func())
+}
+
+type nameRegistryImpl struct {
+	mu       sync.Mutex
+	bindings map[string]Address
+	watchers map[string]map[int]func(NameEvent)
+	next     int
+}
+
+func NewNameRegistry() NameRegistry {
+	return &nameRegistryImpl{bindings: make(map[string]Address), watchers: make(map[string]map[int]func(NameEvent))}
+}
+
+func (r *nameRegistryImpl) Bind(name string, a Address) *core.CoreError {
+	if err := ValidateActorName(name); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	previous := r.bindings[name]
+	r.bindings[name] = a
+	watchers := r.watching(name)
+	r.mu.Unlock()
+	notify(watchers, NameEvent{Name: name, Previous: previous, Current: a})
+	return nil
+}
+
+func (r *nameRegistryImpl) Unbind(name string) bool {
+	r.mu.Lock()
+	previous, ok := r.bindings[name]
+	delete(r.bindings, name)
+	watchers := r.watching(name)
+	r.mu.Unlock()
+	if ok {
+		notify(watchers, NameEvent{Name: name, Previous: previous})
+	}
+	return ok
+}
+
+func (r *nameRegistryImpl) Lookup(name string) (Address, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.bindings[name]
+	return a, ok
+}
+
+func (r *nameRegistryImpl) Names(id uuid.UUID) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for name, a := range r.bindings {
+		if a.ID() == id {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *nameRegistryImpl) Watch(name string, fn func(NameEvent)) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := r.next
+	r.next++
+	if r.watchers[name] == nil {
+		r.watchers[name] = make(map[int]func(NameEvent))
+	}
+	r.watchers[name][key] = fn
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.watchers[name], key)
+		if len(r.watchers[name]) == 0 {
+			delete(r.watchers, name)
+		}
+	}
+}
+
+func (r *nameRegistryImpl) watching(name string) []func(NameEvent) {
+	watchers := make([]func(NameEvent), 0, len(r.watchers[name]))
+	for _, fn := range r.watchers[name] {
+		watchers = append(watchers, fn)
+	}
+	return watchers
+}
+
+func notify(watchers []func(NameEvent), event NameEvent) {
+	for _, fn := range watchers {
+		fn(event)
+	}
+}
+
+// ActorAddress reaches one actor through another address, either the
+// postman holding it or a postoffice routing by the actor id.
+type ActorAddress struct {
+	id  uuid.UUID
+	via Address
+}
+
+func NewActorAddress(id uuid.UUID, via Address) Address {
+	return &ActorAddress{id: id, via: via}
+}
+
+func (a *ActorAddress) String() string {
+	return fmt.Sprintf("%s:%s", AddressType_ACTOR, (&a.id).String())
+}
+
+func (a *ActorAddress) ID() uuid.UUID {
+	return a.id
+}
+
+func (a *ActorAddress) Transfer(ctx context.Context, mail Mail[any]) *core.CoreError {
+	return a.via.Transfer(ctx, mail)
+}
+
+// NameBinding is sent by a postman to its postoffice so a name becomes
+// resolvable on the whole ring. Postman is the postman the actor lives on.
+type NameBinding struct {
+	Name    string
+	Actor   uuid.UUID
+	Postman uuid.UUID
+	Unbind  bool
+}
+
+func asNameBinding(mail Mail[any]) (NameBinding, bool) {
+	if mail.Kind() != MailKind_NAME_BINDING {
+		return NameBinding{}, false
+	}
+	return AsMessage[NameBinding](mail.Message())
+}
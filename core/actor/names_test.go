@@ -0,0 +1,118 @@
+package actor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeNameTest uuid.UUIDType = "name_test"
+
+func newReceivingActor(id uuid.UUID) (Actor[Mail[any], any], chan any) {
+	received := make(chan any, 10)
+	a := NewActor[Mail[any], any](MailboxType_MEMORY, id, nil)
+	a.Start(func(mail Mail[any]) {
+		received <- mail.Message()
+	})
+	return a, received
+}
+
+func TestValidateActorName(t *testing.T) {
+	for _, name := range []string{"/user", "/user/room/42"} {
+		assert.Nil(t, ValidateActorName(name), name)
+	}
+	for _, name := range []string{"", "/", "user/room", "/user/", "/user//room", "/user/room 42"} {
+		err := ValidateActorName(name)
+		if assert.NotNil(t, err, name) {
+			assert.Equal(t, core.ERROR_CODE_NAME_INVALID, err.Code())
+		}
+	}
+}
+
+func TestPostman_BindAndWatch(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeNameTest)
+	ctx := context.Background()
+	pm := NewPostman()
+	first, received := newReceivingActor(idGen.Next())
+	defer first.Stop()
+	second, _ := newReceivingActor(idGen.Next())
+	defer second.Stop()
+	pm.Add(ctx, first)
+	pm.Add(ctx, second)
+
+	assert.Equal(t, core.ERROR_CODE_ACTOR_NOT_FOUND, pm.Bind(ctx, "/user/room/42", idGen.Next()).Code())
+	assert.Nil(t, pm.Bind(ctx, "/user/room/42", first.ID()))
+	a, err := pm.Lookup(ctx, "/user/room/42")
+	assert.Nil(t, err)
+	assert.Equal(t, first.ID(), a.ID())
+	assert.Nil(t, a.Transfer(ctx, NewMail[any](idGen.Next(), a.ID(), "join", codec.JSON_CODEC)))
+	expectMessage(t, received, "join")
+
+	var events []NameEvent
+	cancel := pm.Watch("/user/room/42", func(event NameEvent) {
+		events = append(events, event)
+	})
+	assert.Nil(t, pm.Bind(ctx, "/user/room/42", second.ID()))
+	assert.Len(t, events, 1)
+	assert.Equal(t, first.ID(), events[0].Previous.ID())
+	assert.Equal(t, second.ID(), events[0].Current.ID())
+
+	assert.Nil(t, pm.Remove(ctx, second.ID()))
+	assert.Len(t, events, 2)
+	assert.Nil(t, events[1].Current, "removing the actor should unbind its names")
+	_, err = pm.Lookup(ctx, "/user/room/42")
+	assert.Equal(t, core.ERROR_CODE_NAME_NOT_FOUND, err.Code())
+
+	cancel()
+	assert.Nil(t, pm.Bind(ctx, "/user/room/42", first.ID()))
+	assert.Len(t, events, 2, "cancelled watch should not be notified")
+}
+
+func TestPostoffice_NameLookupAcrossRing(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeNameTest)
+	ctx := context.Background()
+	ring := library.NewConsistentHash[Address](150, nil, nil)
+	po := NewPostoffice(ring, idGen.Next())
+	gateway := NewPostman(WithPostmanID(idGen.Next()))
+	rooms := NewPostman(WithPostmanID(idGen.Next()))
+	po.Add(ctx, NewLocalPostManAddress(gateway))
+	po.Add(ctx, NewLocalPostManAddress(rooms))
+	gateway.Register(ctx, NewLocalPostOfficeAddress(po))
+	rooms.Register(ctx, NewLocalPostOfficeAddress(po))
+
+	// room 的 id 哈希到 gateway，名字必须解析到真正持有它的 rooms
+	room, received := newReceivingActor(idOn(ring, idGen, gateway))
+	defer room.Stop()
+	rooms.Add(ctx, room)
+	assert.Nil(t, rooms.Bind(ctx, "/user/room/42", room.ID()))
+
+	a, err := gateway.Lookup(ctx, "/user/room/42")
+	assert.Nil(t, err)
+	assert.Equal(t, room.ID(), a.ID())
+	assert.Nil(t, a.Transfer(ctx, NewMail[any](idGen.Next(), a.ID(), "from gateway", codec.JSON_CODEC)))
+	expectMessage(t, received, "from gateway")
+
+	assert.Nil(t, rooms.Unbind(ctx, "/user/room/42"))
+	_, ok := po.Lookup("/user/room/42")
+	assert.False(t, ok)
+}
+
+func TestNameBinding_OverWire(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeNameTest)
+	binding := NameBinding{Name: "/user/room/42", Actor: idGen.Next(), Postman: idGen.Next()}
+	data, err := EncodeMail(NewMail[any](idGen.Next(), idGen.Next(), binding, codec.JSON_CODEC, WithKind(MailKind_NAME_BINDING)))
+	assert.NoError(t, err)
+	mail, err := DecodeMail(data)
+	assert.NoError(t, err)
+	decoded, ok := asNameBinding(mail)
+	assert.True(t, ok)
+	assert.Equal(t, binding, decoded)
+	// 没有标记的用户邮件即使字段相同也不是 NameBinding
+	_, ok = asNameBinding(NewMail[any](idGen.Next(), idGen.Next(), binding, codec.JSON_CODEC))
+	assert.False(t, ok)
+}
@@ -0,0 +1,308 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+// JournalEntry is one encoded event, sequence numbers start at 1.
+type JournalEntry struct {
+	PersistenceID string
+	SequenceNr    int64
+	Payload       []byte
+}
+
+type Journal interface {
+	Append(ctx context.Context, entries []JournalEntry) error
+	// Replay calls fn for every entry of persistenceID from fromSequenceNr on, in order.
+	Replay(ctx context.Context, persistenceID string, fromSequenceNr int64, fn func(JournalEntry) error) error
+}
+
+// Snapshot is the encoded state after the event SequenceNr.
+type Snapshot struct {
+	PersistenceID string
+	SequenceNr    int64
+	Payload       []byte
+	Time          time.Time
+}
+
+type SnapshotStore interface {
+	Save(ctx context.Context, snapshot Snapshot) error
+	// Load returns the latest snapshot, ok is false when there is none.
+	Load(ctx context.Context, persistenceID string) (snapshot Snapshot, ok bool, err error)
+}
+
+type PersistenceConfig[E any, D any] struct {
+	PersistenceID string
+	Journal       Journal
+	// Snapshots is optional, without it recovery replays the whole journal
+	Snapshots SnapshotStore
+	// SnapshotEvery saves a snapshot after that many events, zero disables it
+	SnapshotEvery int64
+	Codec         codec.CODEC_TYPE
+	// Apply returns the state after the event, it runs for new events and on recovery
+	Apply func(state D, event E) D
+}
+
+// PersistentActor is an event sourced actor. The process function turns mail
+// into events with Persist, the state only changes by applying events, so it
+// is rebuilt from the last snapshot and the journal when the actor starts or
+// is restarted by its supervisor.
+type PersistentActor[E any, D any] interface {
+	Actor[Mail[any], any]
+	PersistenceID() string
+	State() D
+	SequenceNr() int64
+	Persist(event E) *core.CoreError
+	SaveSnapshot() *core.CoreError
+	Recover() *core.CoreError
+}
+
+type persistentActorImpl[E any, D any] struct {
+	*actorImpl[Mail[any], any]
+	config   PersistenceConfig[E, D]
+	initial  D
+	events   codec.Codec[E]
+	states   codec.Codec[D]
+	mu       sync.Mutex
+	state    D
+	sequence int64
+	// persist orders Persist calls, mu is not held while the journal writes
+	persist sync.Mutex
+}
+
+func NewPersistentActor[E any, D any](mailboxType MailboxType, id uuid.UUID, initial D, config PersistenceConfig[E, D], opts ...MailboxOption) PersistentActor[E, D] {
+	p := &persistentActorImpl[E, D]{config: config, initial: initial, state: initial}
+	a := NewActor[Mail[any], any](mailboxType, id, nil, opts...).(*actorImpl[Mail[any], any])
+	a.restartHook = func(cause *core.CoreError) {
+		if err := p.Recover(); err != nil {
+			// going on from a stale sequence would overwrite journaled events
+			log.ErrorF("error while recover %s after restart, stopping: %s\n", config.PersistenceID, err.String())
+			p.actorImpl.Stop()
+		}
+	}
+	p.actorImpl = a
+	return p
+}
+
+func (p *persistentActorImpl[E, D]) PersistenceID() string {
+	return p.config.PersistenceID
+}
+
+func (p *persistentActorImpl[E, D]) State() D {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// Data returns the current state.
+func (p *persistentActorImpl[E, D]) Data() any {
+	return p.State()
+}
+
+func (p *persistentActorImpl[E, D]) SequenceNr() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sequence
+}
+
+// Start recovers the state before the first mail is processed. An actor that
+// can not recover is stopped instead of started.
+func (p *persistentActorImpl[E, D]) Start(process ActorProcessFn[Mail[any]]) {
+	if err := p.Recover(); err != nil {
+		log.ErrorF("error while recover %s, stopping: %s\n", p.config.PersistenceID, err.String())
+		p.actorImpl.Stop()
+		return
+	}
+	p.actorImpl.Start(process)
+}
+
+// Persist journals the event and applies it once it is written.
+func (p *persistentActorImpl[E, D]) Persist(event E) *core.CoreError {
+	events, err := p.eventCodec()
+	if err != nil {
+		return err
+	}
+	payload, encodeErr := events.Encode(event)
+	if encodeErr != nil {
+		return core.NewCoreError(core.ERROR_CODE_CODEC_ENCODE_ERROR, fmt.Sprintf("error while encode event of %s: %v", p.config.PersistenceID, encodeErr))
+	}
+	p.persist.Lock()
+	defer p.persist.Unlock()
+	p.mu.Lock()
+	entry := JournalEntry{PersistenceID: p.config.PersistenceID, SequenceNr: p.sequence + 1, Payload: payload}
+	p.mu.Unlock()
+	if appendErr := p.config.Journal.Append(context.Background(), []JournalEntry{entry}); appendErr != nil {
+		return core.NewCoreError(core.ERROR_CODE_PERSISTENCE_ERROR, fmt.Sprintf("error while journal event of %s: %v", p.config.PersistenceID, appendErr))
+	}
+	p.mu.Lock()
+	p.state = p.config.Apply(p.state, event)
+	p.sequence = entry.SequenceNr
+	snapshot := p.config.SnapshotEvery > 0 && p.sequence%p.config.SnapshotEvery == 0
+	p.mu.Unlock()
+	if snapshot {
+		if err := p.SaveSnapshot(); err != nil {
+			log.ErrorF("error while snapshot %s: %s\n", p.config.PersistenceID, err.String())
+		}
+	}
+	return nil
+}
+
+func (p *persistentActorImpl[E, D]) SaveSnapshot() *core.CoreError {
+	if p.config.Snapshots == nil {
+		return core.NewCoreError(core.ERROR_CODE_PERSISTENCE_ERROR, fmt.Sprintf("no snapshot store for %s", p.config.PersistenceID))
+	}
+	states, err := p.stateCodec()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	payload, encodeErr := states.Encode(p.state)
+	sequence := p.sequence
+	p.mu.Unlock()
+	if encodeErr != nil {
+		return core.NewCoreError(core.ERROR_CODE_CODEC_ENCODE_ERROR, fmt.Sprintf("error while encode state of %s: %v", p.config.PersistenceID, encodeErr))
+	}
+	snapshot := Snapshot{PersistenceID: p.config.PersistenceID, SequenceNr: sequence, Payload: payload, Time: time.Now()}
+	if saveErr := p.config.Snapshots.Save(context.Background(), snapshot); saveErr != nil {
+		return core.NewCoreError(core.ERROR_CODE_PERSISTENCE_ERROR, fmt.Sprintf("error while save snapshot of %s: %v", p.config.PersistenceID, saveErr))
+	}
+	return nil
+}
+
+// Recover rebuilds the state from the latest snapshot and the events after it.
+func (p *persistentActorImpl[E, D]) Recover() *core.CoreError {
+	events, err := p.eventCodec()
+	if err != nil {
+		return err
+	}
+	states, err := p.stateCodec()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	state, sequence := p.initial, int64(0)
+	if p.config.Snapshots != nil {
+		snapshot, ok, loadErr := p.config.Snapshots.Load(ctx, p.config.PersistenceID)
+		if loadErr != nil {
+			return core.NewCoreError(core.ERROR_CODE_PERSISTENCE_ERROR, fmt.Sprintf("error while load snapshot of %s: %v", p.config.PersistenceID, loadErr))
+		}
+		if ok {
+			if state, loadErr = states.Decode(snapshot.Payload); loadErr != nil {
+				return core.NewCoreError(core.ERROR_CODE_CODEC_DECODE_ERROR, fmt.Sprintf("error while decode snapshot of %s: %v", p.config.PersistenceID, loadErr))
+			}
+			sequence = snapshot.SequenceNr
+		}
+	}
+	replayErr := p.config.Journal.Replay(ctx, p.config.PersistenceID, sequence+1, func(entry JournalEntry) error {
+		event, decodeErr := events.Decode(entry.Payload)
+		if decodeErr != nil {
+			return fmt.Errorf("event %d: %w", entry.SequenceNr, decodeErr)
+		}
+		state = p.config.Apply(state, event)
+		sequence = entry.SequenceNr
+		return nil
+	})
+	if replayErr != nil {
+		return core.NewCoreError(core.ERROR_CODE_PERSISTENCE_ERROR, fmt.Sprintf("error while replay %s: %v", p.config.PersistenceID, replayErr))
+	}
+	p.mu.Lock()
+	p.state, p.sequence = state, sequence
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *persistentActorImpl[E, D]) eventCodec() (codec.Codec[E], *core.CoreError) {
+	if p.events == nil {
+		c, err := newPersistenceCodec[E](p.config.Codec)
+		if err != nil {
+			return nil, err
+		}
+		p.events = c
+	}
+	return p.events, nil
+}
+
+func (p *persistentActorImpl[E, D]) stateCodec() (codec.Codec[D], *core.CoreError) {
+	if p.states == nil {
+		c, err := newPersistenceCodec[D](p.config.Codec)
+		if err != nil {
+			return nil, err
+		}
+		p.states = c
+	}
+	return p.states, nil
+}
+
+func newPersistenceCodec[T any](codecType codec.CODEC_TYPE) (c codec.Codec[T], err *core.CoreError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = core.NewCoreError(core.ERROR_CODE_CODEC_ENCODE_ERROR, fmt.Sprintf("%v", r))
+		}
+	}()
+	return codec.NewCodec[T](codecType), nil
+}
+
+type memoryJournal struct {
+	mu      sync.Mutex
+	entries map[string][]JournalEntry
+}
+
+// NewMemoryJournal keeps the journal in memory, it is meant for tests.
+func NewMemoryJournal() Journal {
+	return &memoryJournal{entries: make(map[string][]JournalEntry)}
+}
+
+func (j *memoryJournal) Append(ctx context.Context, entries []JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, entry := range entries {
+		j.entries[entry.PersistenceID] = append(j.entries[entry.PersistenceID], entry)
+	}
+	return nil
+}
+
+func (j *memoryJournal) Replay(ctx context.Context, persistenceID string, fromSequenceNr int64, fn func(JournalEntry) error) error {
+	j.mu.Lock()
+	entries := append([]JournalEntry(nil), j.entries[persistenceID]...)
+	j.mu.Unlock()
+	for _, entry := range entries {
+		if entry.SequenceNr < fromSequenceNr {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type memorySnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[string]Snapshot
+}
+
+func NewMemorySnapshotStore() SnapshotStore {
+	return &memorySnapshotStore{snapshots: make(map[string]Snapshot)}
+}
+
+func (s *memorySnapshotStore) Save(ctx context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[snapshot.PersistenceID] = snapshot
+	return nil
+}
+
+func (s *memorySnapshotStore) Load(ctx context.Context, persistenceID string) (Snapshot, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, ok := s.snapshots[persistenceID]
+	return snapshot, ok, nil
+}
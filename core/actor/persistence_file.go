@@ -0,0 +1,206 @@
+package actor
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/shooyaaa/core"
+)
+
+const journalSuffix = ".journal"
+const snapshotSuffix = ".snapshot"
+
+// fileJournal keeps one append only file per persistence id. Every record is
+// [length][crc32][sequence nr][event] like the records of the file mailbox,
+// a record torn by a crash is cut off when the file is opened again.
+type fileJournal struct {
+	dir string
+
+	mu      sync.Mutex
+	writers map[string]*os.File
+}
+
+func NewFileJournal(dir string) (Journal, *core.CoreError) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, core.NewCoreError(core.ERROR_CODE_PERSISTENCE_ERROR, fmt.Sprintf("error while open journal %s: %v", dir, err))
+	}
+	return &fileJournal{dir: dir, writers: make(map[string]*os.File)}, nil
+}
+
+func (j *fileJournal) path(persistenceID string) string {
+	return filepath.Join(j.dir, url.PathEscape(persistenceID)+journalSuffix)
+}
+
+func (j *fileJournal) Append(ctx context.Context, entries []JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	touched := make(map[string]*os.File)
+	for _, entry := range entries {
+		writer, err := j.writer(entry.PersistenceID)
+		if err != nil {
+			return err
+		}
+		payload := make([]byte, 8+len(entry.Payload))
+		binary.BigEndian.PutUint64(payload, uint64(entry.SequenceNr))
+		copy(payload[8:], entry.Payload)
+		record := make([]byte, recordHeaderSize+len(payload))
+		binary.BigEndian.PutUint32(record, uint32(len(payload)))
+		binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+		copy(record[recordHeaderSize:], payload)
+		if _, err := writer.Write(record); err != nil {
+			return err
+		}
+		touched[entry.PersistenceID] = writer
+	}
+	for _, writer := range touched {
+		if err := writer.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writer opens the journal of persistenceID for appending, the caller holds mu.
+func (j *fileJournal) writer(persistenceID string) (*os.File, error) {
+	if writer, ok := j.writers[persistenceID]; ok {
+		return writer, nil
+	}
+	file, err := os.OpenFile(j.path(persistenceID), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+	var size int64
+	for {
+		payload, err := readRecord(reader)
+		if err != nil {
+			break
+		}
+		size += int64(recordHeaderSize + len(payload))
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	j.writers[persistenceID] = file
+	return file, nil
+}
+
+func (j *fileJournal) Replay(ctx context.Context, persistenceID string, fromSequenceNr int64, fn func(JournalEntry) error) error {
+	file, err := os.Open(j.path(persistenceID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		payload, err := readRecord(reader)
+		if err != nil {
+			// end of the journal or a torn record of an interrupted write
+			return nil
+		}
+		if len(payload) < 8 {
+			return fmt.Errorf("journal record of %s too short", persistenceID)
+		}
+		entry := JournalEntry{PersistenceID: persistenceID, SequenceNr: int64(binary.BigEndian.Uint64(payload)), Payload: payload[8:]}
+		if entry.SequenceNr < fromSequenceNr {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the open journal files, appending opens them again.
+func (j *fileJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var err error
+	for id, writer := range j.writers {
+		if closeErr := writer.Close(); closeErr != nil {
+			err = closeErr
+		}
+		delete(j.writers, id)
+	}
+	return err
+}
+
+// fileSnapshotStore keeps the latest snapshot of every persistence id, a new
+// snapshot is written to a temporary file and renamed over the old one.
+type fileSnapshotStore struct {
+	dir string
+}
+
+func NewFileSnapshotStore(dir string) (SnapshotStore, *core.CoreError) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, core.NewCoreError(core.ERROR_CODE_PERSISTENCE_ERROR, fmt.Sprintf("error while open snapshot store %s: %v", dir, err))
+	}
+	return &fileSnapshotStore{dir: dir}, nil
+}
+
+func (s *fileSnapshotStore) path(persistenceID string) string {
+	return filepath.Join(s.dir, url.PathEscape(persistenceID)+snapshotSuffix)
+}
+
+func (s *fileSnapshotStore) Save(ctx context.Context, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(s.dir, url.PathEscape(snapshot.PersistenceID)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.path(snapshot.PersistenceID))
+}
+
+func (s *fileSnapshotStore) Load(ctx context.Context, persistenceID string) (Snapshot, bool, error) {
+	data, err := os.ReadFile(s.path(persistenceID))
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return Snapshot{}, false, err
+	}
+	return snapshot, true, nil
+}
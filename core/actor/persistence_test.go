@@ -0,0 +1,178 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypePersistence uuid.UUIDType = "persistence"
+
+type counterEvent struct {
+	Delta int
+}
+
+type counterState struct {
+	Total  int
+	Events int
+}
+
+func counterConfig(id string, journal Journal, snapshots SnapshotStore) PersistenceConfig[counterEvent, counterState] {
+	return PersistenceConfig[counterEvent, counterState]{
+		PersistenceID: id,
+		Journal:       journal,
+		Snapshots:     snapshots,
+		SnapshotEvery: 2,
+		Codec:         codec.JSON_CODEC,
+		Apply: func(state counterState, event counterEvent) counterState {
+			state.Total += event.Delta
+			state.Events++
+			return state
+		},
+	}
+}
+
+func TestPersistentActor_RecoverFromSnapshot(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypePersistence)
+	journal, snapshots := NewMemoryJournal(), NewMemorySnapshotStore()
+	config := counterConfig("counter-1", journal, snapshots)
+
+	p := NewPersistentActor(MailboxType_MEMORY, idGen.Next(), counterState{}, config)
+	for _, delta := range []int{1, 2, 3} {
+		assert.Nil(t, p.Persist(counterEvent{Delta: delta}))
+	}
+	assert.Equal(t, counterState{Total: 6, Events: 3}, p.State())
+	assert.Equal(t, counterState{Total: 6, Events: 3}, p.Data())
+	assert.Equal(t, int64(3), p.SequenceNr())
+
+	snapshot, ok, err := snapshots.Load(context.Background(), "counter-1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), snapshot.SequenceNr, "快照每两个事件保存一次")
+
+	replayed := 0
+	config.Apply = func(state counterState, event counterEvent) counterState {
+		replayed++
+		state.Total += event.Delta
+		state.Events++
+		return state
+	}
+	recovered := NewPersistentActor(MailboxType_MEMORY, idGen.Next(), counterState{}, config)
+	recovered.Start(func(mail Mail[any]) {})
+	defer recovered.Stop()
+	assert.Equal(t, counterState{Total: 6, Events: 3}, recovered.State())
+	assert.Equal(t, int64(3), recovered.SequenceNr())
+	assert.Equal(t, 1, replayed, "only the event after the snapshot should be replayed")
+}
+
+func TestPersistentActor_FileJournal(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypePersistence)
+	dir := t.TempDir()
+	journal, err := NewFileJournal(filepath.Join(dir, "journal"))
+	assert.Nil(t, err)
+	snapshots, err := NewFileSnapshotStore(filepath.Join(dir, "snapshots"))
+	assert.Nil(t, err)
+	config := counterConfig("room/42", journal, snapshots)
+	config.SnapshotEvery = 0
+
+	p := NewPersistentActor(MailboxType_MEMORY, idGen.Next(), counterState{}, config)
+	for _, delta := range []int{5, 7} {
+		assert.Nil(t, p.Persist(counterEvent{Delta: delta}))
+	}
+	assert.Nil(t, p.SaveSnapshot())
+	assert.Nil(t, p.Persist(counterEvent{Delta: 1}))
+	assert.NoError(t, journal.(*fileJournal).Close())
+
+	// 模拟写入中途崩溃留下的半条记录
+	path := filepath.Join(dir, "journal", "room%2F42"+journalSuffix)
+	file, openErr := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, openErr)
+	_, writeErr := file.Write([]byte{0, 0, 0, 64, 1, 2})
+	assert.NoError(t, writeErr)
+	assert.NoError(t, file.Close())
+
+	reopened, err := NewFileJournal(filepath.Join(dir, "journal"))
+	assert.Nil(t, err)
+	config.Journal = reopened
+	recovered := NewPersistentActor(MailboxType_MEMORY, idGen.Next(), counterState{}, config)
+	assert.Nil(t, recovered.Recover())
+	assert.Equal(t, counterState{Total: 13, Events: 3}, recovered.State())
+	assert.Equal(t, int64(3), recovered.SequenceNr())
+
+	assert.Nil(t, recovered.Persist(counterEvent{Delta: 2}))
+	var sequences []int64
+	assert.NoError(t, reopened.Replay(context.Background(), "room/42", 1, func(entry JournalEntry) error {
+		sequences = append(sequences, entry.SequenceNr)
+		return nil
+	}))
+	assert.Equal(t, []int64{1, 2, 3, 4}, sequences, "the torn record should be cut off before appending")
+	assert.NoError(t, reopened.(*fileJournal).Close())
+}
+
+func TestPersistentActor_RecoverOnRestart(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypePersistence)
+	journal := NewMemoryJournal()
+	config := counterConfig("counter-2", journal, nil)
+	config.SnapshotEvery = 0
+
+	parent := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	defer parent.Stop()
+	p := NewPersistentActor(MailboxType_MEMORY, idGen.Next(), counterState{}, config)
+	assert.Nil(t, parent.Supervise(p))
+	received := make(chan any, 10)
+	p.Start(func(mail Mail[any]) {
+		switch delta := mail.Message().(type) {
+		case int:
+			assert.Nil(t, p.Persist(counterEvent{Delta: delta}))
+			received <- p.State().Total
+		case string:
+			panic(delta)
+		}
+	})
+	sendTo(t, p, 3)
+	expectMessage(t, received, 3)
+
+	// an event journaled by an earlier incarnation that this one has not applied
+	payload, _ := codec.NewCodec[counterEvent](codec.JSON_CODEC).Encode(counterEvent{Delta: 10})
+	assert.NoError(t, journal.Append(context.Background(), []JournalEntry{{PersistenceID: "counter-2", SequenceNr: 2, Payload: payload}}))
+	sendTo(t, p, "boom")
+	sendTo(t, p, 1)
+	expectMessage(t, received, 14)
+	assert.Equal(t, int64(3), p.SequenceNr())
+}
+
+type brokenJournal struct {
+	Journal
+}
+
+func (j brokenJournal) Replay(ctx context.Context, persistenceID string, fromSequenceNr int64, fn func(JournalEntry) error) error {
+	return errors.New("journal unavailable")
+}
+
+func TestPersistentActor_RecoverFailureStops(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypePersistence)
+	journal := NewMemoryJournal()
+	config := counterConfig("counter-3", brokenJournal{journal}, nil)
+	config.SnapshotEvery = 0
+
+	p := NewPersistentActor(MailboxType_MEMORY, idGen.Next(), counterState{}, config)
+	processed := make(chan any, 1)
+	p.Start(func(mail Mail[any]) {
+		processed <- mail.Message()
+	})
+	select {
+	case <-p.Terminated():
+	case <-time.After(time.Second):
+		t.Fatal("an actor that can not recover should stop")
+	}
+	// 恢复失败的 actor 不能从序号 0 开始写日志
+	assert.NotNil(t, p.Mailbox().Send(context.Background(), NewMail[any](idGen.Next(), p.ID(), 1, codec.JSON_CODEC)))
+	assert.Len(t, processed, 0)
+}
@@ -0,0 +1,149 @@
+//go:build !windows
+
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/shooyaaa/core"
+)
+
+// PipeChannel writes length prefixed frames into a named pipe. Writes of at
+// most PIPE_BUF bytes are atomic, so several processes may share one pipe as
+// long as their mails stay small.
+type PipeChannel struct {
+	addr string
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (c *PipeChannel) Send(ctx context.Context, data []byte) *core.CoreError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		file, err := c.open(ctx)
+		if err != nil {
+			return err
+		}
+		werr := writeFrame(file, data)
+		if werr == nil {
+			return nil
+		}
+		c.reset()
+		if attempt > 0 {
+			return core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, fmt.Sprintf("error while write to %s: %v", c.addr, werr))
+		}
+	}
+}
+
+// Receive is not supported, the read end of the pipe belongs to PipeChannelServer.
+func (c *PipeChannel) Receive(ctx context.Context) ([]byte, *core.CoreError) {
+	return nil, core.NewCoreError(core.ERROR_CODE_ADDRESS_NOT_SUPPORTED, fmt.Sprintf("pipe channel to %s is send only", c.addr))
+}
+
+func (c *PipeChannel) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset()
+	return nil
+}
+
+// open waits with backoff until somebody reads the pipe, opening a pipe
+// without reader fails with ENXIO in non blocking mode.
+func (c *PipeChannel) open(ctx context.Context) (*os.File, *core.CoreError) {
+	if c.file != nil {
+		return c.file, nil
+	}
+	path := strings.TrimPrefix(c.addr, "pipe://")
+	backoff := tcpMinBackoff
+	for attempt := 1; ; attempt++ {
+		fd, err := syscall.Open(path, syscall.O_WRONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+		if err == nil {
+			syscall.SetNonblock(fd, false)
+			c.file = os.NewFile(uintptr(fd), path)
+			return c.file, nil
+		}
+		if attempt >= tcpDialAttempts {
+			return nil, core.NewCoreError(core.ERROR_CODE_CHANNEL_CONNECT_ERROR, fmt.Sprintf("error while open %s: %v", c.addr, err))
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, core.NewCoreError(core.ERROR_CODE_CHANNEL_CONNECT_ERROR, fmt.Sprintf("error while open %s: %v", c.addr, ctx.Err()))
+		}
+		backoff *= 2
+		if backoff > tcpMaxBackoff {
+			backoff = tcpMaxBackoff
+		}
+	}
+}
+
+func (c *PipeChannel) reset() {
+	if c.file != nil {
+		c.file.Close()
+	}
+	c.file = nil
+}
+
+func NewPipeChannel(addr string) RpcChannel {
+	return &PipeChannel{addr: addr}
+}
+
+// PipeChannelServer creates the named pipe and hands every frame written
+// into it to a MailReceiver.
+type PipeChannelServer struct {
+	receiver MailReceiver
+	path     string
+	file     *os.File
+	wg       sync.WaitGroup
+}
+
+func NewPipeChannelServer(receiver MailReceiver) *PipeChannelServer {
+	return &PipeChannelServer{receiver: receiver}
+}
+
+func (s *PipeChannelServer) Listen(addr string) *core.CoreError {
+	s.path = strings.TrimPrefix(addr, "pipe://")
+	if err := syscall.Mkfifo(s.path, 0600); err != nil && !errors.Is(err, os.ErrExist) {
+		return core.NewCoreError(core.ERROR_CODE_CHANNEL_LISTEN_ERROR, fmt.Sprintf("error while create pipe %s: %v", addr, err))
+	}
+	// Keeping the pipe open for writing too means readFrame never sees EOF
+	// when the last writer goes away.
+	file, err := os.OpenFile(s.path, os.O_RDWR, 0)
+	if err != nil {
+		return core.NewCoreError(core.ERROR_CODE_CHANNEL_LISTEN_ERROR, fmt.Sprintf("error while open pipe %s: %v", addr, err))
+	}
+	s.file = file
+	s.wg.Add(1)
+	go s.serve()
+	return nil
+}
+
+func (s *PipeChannelServer) Addr() string {
+	return "pipe://" + s.path
+}
+
+func (s *PipeChannelServer) Close() error {
+	err := s.file.Close()
+	s.wg.Wait()
+	os.Remove(s.path)
+	return err
+}
+
+func (s *PipeChannelServer) serve() {
+	defer s.wg.Done()
+	for {
+		payload, err := readFrame(s.file)
+		if err != nil {
+			return
+		}
+		receiveFrame(context.Background(), s.receiver, payload)
+	}
+}
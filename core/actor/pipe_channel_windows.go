@@ -0,0 +1,44 @@
+package actor
+
+import (
+	"context"
+
+	"github.com/shooyaaa/core"
+)
+
+// Named pipes are only implemented on unix like systems.
+type PipeChannel struct {
+	addr string
+}
+
+func (c *PipeChannel) Send(ctx context.Context, data []byte) *core.CoreError {
+	return core.NewCoreError(core.ERROR_CODE_ADDRESS_NOT_SUPPORTED, "pipe channel is not supported on windows")
+}
+
+func (c *PipeChannel) Receive(ctx context.Context) ([]byte, *core.CoreError) {
+	return nil, core.NewCoreError(core.ERROR_CODE_ADDRESS_NOT_SUPPORTED, "pipe channel is not supported on windows")
+}
+
+func NewPipeChannel(addr string) RpcChannel {
+	return &PipeChannel{addr: addr}
+}
+
+type PipeChannelServer struct {
+	receiver MailReceiver
+}
+
+func NewPipeChannelServer(receiver MailReceiver) *PipeChannelServer {
+	return &PipeChannelServer{receiver: receiver}
+}
+
+func (s *PipeChannelServer) Listen(addr string) *core.CoreError {
+	return core.NewCoreError(core.ERROR_CODE_ADDRESS_NOT_SUPPORTED, "pipe channel is not supported on windows")
+}
+
+func (s *PipeChannelServer) Addr() string {
+	return ""
+}
+
+func (s *PipeChannelServer) Close() error {
+	return nil
+}
@@ -19,16 +19,72 @@ type Postman interface {
 	Receive(ctx context.Context, mail Mail[any]) *core.CoreError
 	ID() uuid.UUID
 	Register(ctx context.Context, pa Address) *core.CoreError
+	Ask(ctx context.Context, receiver uuid.UUID, message any) (Mail[any], *core.CoreError)
+	AskAddress(ctx context.Context, address Address, message any) (Mail[any], *core.CoreError)
+	// Bind names a local actor and publishes the name to the postoffice.
+	Bind(ctx context.Context, name string, id uuid.UUID) *core.CoreError
+	Unbind(ctx context.Context, name string) *core.CoreError
+	// Lookup resolves a name bound here first and then on the postoffice.
+	Lookup(ctx context.Context, name string) (Address, *core.CoreError)
+	Watch(name string, fn func(NameEvent)) (cancel func())
+	// WatchActor sends watcher a Terminated mail once target is gone.
+	WatchActor(ctx context.Context, watcher uuid.UUID, target Address) *core.CoreError
+	UnwatchActor(ctx context.Context, watcher uuid.UUID, target Address) *core.CoreError
+	// Unreachable tells the watchers of the actors held by host that they are gone.
+	Unreachable(ctx context.Context, host uuid.UUID)
+	// Host hands every mail for actors of entityType to receiver instead,
+	// a shard region uses it to spawn entities on their first mail.
+	Host(entityType uuid.UUIDType, receiver MailReceiver) (cancel func())
 }
 
 type postmanImpl struct {
-	actors     sync.Map
-	id         uuid.UUID
-	postoffice Address
+	actors      sync.Map
+	id          uuid.UUID
+	postoffice  Address
+	futures     sync.Map
+	names       NameRegistry
+	watch       *deathWatch
+	deadLetters DeadLetterOffice
+	hosts       sync.Map
+	metrics     Metrics
+	exporter    SpanExporter
 }
 
-func NewPostman() Postman {
-	return &postmanImpl{}
+type PostmanOption func(m *postmanImpl)
+
+// WithPostmanID identifies the postman, postmen sharing a postoffice ring
+// need distinct ids.
+func WithPostmanID(id uuid.UUID) PostmanOption {
+	return func(m *postmanImpl) {
+		m.id = id
+	}
+}
+
+// WithPostmanDeadLetters captures mail the postman can not deliver.
+func WithPostmanDeadLetters(deadLetters DeadLetterOffice) PostmanOption {
+	return func(m *postmanImpl) {
+		m.deadLetters = deadLetters
+	}
+}
+
+func NewPostman(opts ...PostmanOption) Postman {
+	m := &postmanImpl{names: NewNameRegistry(), watch: newDeathWatch()}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// undeliverable hands mail that can not be delivered to the dead letter
+// office and returns the cause unchanged.
+func (m *postmanImpl) undeliverable(ctx context.Context, mail Mail[any], cause *core.CoreError) *core.CoreError {
+	if cause != nil && m.metrics != nil {
+		m.metrics.deadLetter(m, cause)
+	}
+	if cause != nil && m.deadLetters != nil {
+		m.deadLetters.Capture(ctx, mail, cause)
+	}
+	return cause
 }
 
 func (m *postmanImpl) ID() uuid.UUID {
@@ -37,41 +93,75 @@ func (m *postmanImpl) ID() uuid.UUID {
 
 func (m *postmanImpl) Add(ctx context.Context, a Actor[Mail[any], any]) *core.CoreError {
 	m.actors.Store(a.ID(), a)
+	if member, ok := a.(interface{ setPostman(Postman) }); ok {
+		member.setPostman(m)
+	}
+	if mailbox, ok := a.Mailbox().(interface{ setDeadLetters(DeadLetterOffice) }); ok && m.deadLetters != nil {
+		mailbox.setDeadLetters(m.deadLetters)
+	}
+	go func() {
+		<-a.Terminated()
+		m.terminated(a)
+	}()
 	a.Mailbox().Gather(func(mail Mail[any]) {
 		err2 := m.Deliver(ctx, mail)
 		if err2 != nil {
 			log.ErrorF("error while deliver message: %s", err2.String())
 		}
 	})
+	if m.deadLetters != nil && m.deadLetters.RedeliverEnabled() {
+		m.deadLetters.Redeliver(ctx, a.ID(), m.Receive)
+	}
 	return nil
 }
 
 func (m *postmanImpl) Receive(ctx context.Context, mail Mail[any]) *core.CoreError {
+	if resolved, err := m.resolve(mail); resolved {
+		return m.undeliverable(ctx, mail, err)
+	}
+	if m.control(ctx, mail) {
+		return nil
+	}
+	if handled, err := m.reliable(ctx, mail); handled {
+		return err
+	}
+	if host, ok := m.hosts.Load(mail.Receiver().Type); ok {
+		return host.(MailReceiver).Receive(ctx, mail)
+	}
 	a, ok := m.actors.Load(mail.Receiver())
-	if ok {
-		err1 := a.(Actor[Mail[any], any]).Mailbox().Send(ctx, mail)
-		if err1 != nil {
-			return core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, err1.Error())
-		}
-	} else {
+	if !ok {
 		receiver := mail.Receiver()
-		return core.NewCoreError(core.ERROR_CODE_ACTOR_NOT_FOUND, fmt.Sprintf("postman receive a mail but actor not found: %s", (&receiver).String()))
+		return m.undeliverable(ctx, mail, core.NewCoreError(core.ERROR_CODE_ACTOR_NOT_FOUND, fmt.Sprintf("postman receive a mail but actor not found: %s", (&receiver).String())))
 	}
-	return nil
+	return m.enqueue(ctx, a.(Actor[Mail[any], any]), mail)
+}
+
+// enqueue puts mail into the mailbox of a local actor.
+func (m *postmanImpl) enqueue(ctx context.Context, a Actor[Mail[any], any], mail Mail[any]) *core.CoreError {
+	traced, finish := startSpan(m.exporter, SpanKind_ENQUEUE, (&m.id).String(), mail)
+	var err *core.CoreError
+	if err1 := a.Mailbox().Send(ctx, traced); err1 != nil {
+		err = m.undeliverable(ctx, mail, core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, err1.Error()))
+	}
+	finish(err)
+	return err
 }
 
 func (m *postmanImpl) Deliver(ctx context.Context, mail Mail[any]) *core.CoreError {
+	if resolved, err := m.resolve(mail); resolved {
+		return m.undeliverable(ctx, mail, err)
+	}
+	if host, ok := m.hosts.Load(mail.Receiver().Type); ok {
+		return host.(MailReceiver).Receive(ctx, mail)
+	}
 	a, ok := m.actors.Load(mail.Receiver())
-	if ok {
-		err1 := a.(Actor[Mail[any], any]).Mailbox().Send(ctx, mail)
-		if err1 != nil {
-			return core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, err1.Error())
-		}
-	} else {
+	if !ok {
 		return m.Dispatch(ctx, mail)
 	}
-	return nil
-
+	if handled, err := m.reliable(ctx, mail); handled {
+		return err
+	}
+	return m.enqueue(ctx, a.(Actor[Mail[any], any]), mail)
 }
 
 func (m *postmanImpl) Register(ctx context.Context, pa Address) *core.CoreError {
@@ -80,10 +170,16 @@ func (m *postmanImpl) Register(ctx context.Context, pa Address) *core.CoreError
 }
 
 func (m *postmanImpl) Dispatch(ctx context.Context, mail Mail[any]) *core.CoreError {
+	var err *core.CoreError
 	if m.postoffice == nil {
-		return core.NewCoreError(core.ERROR_CODE_POSTOFFICE_NOT_REGISTERED, "postoffice not registered")
+		err = m.undeliverable(ctx, mail, core.NewCoreError(core.ERROR_CODE_POSTOFFICE_NOT_REGISTERED, "postoffice not registered"))
+	} else {
+		err = m.postoffice.Transfer(ctx, mail)
+	}
+	if err != nil && m.metrics != nil {
+		m.metrics.dispatchFailed(m, err)
 	}
-	return m.postoffice.Transfer(ctx, mail)
+	return err
 }
 
 func (m *postmanImpl) Remove(ctx context.Context, id uuid.UUID) *core.CoreError {
@@ -91,5 +187,73 @@ func (m *postmanImpl) Remove(ctx context.Context, id uuid.UUID) *core.CoreError
 	if !ok {
 		return core.NewCoreError(core.ERROR_CODE_ACTOR_NOT_FOUND, fmt.Sprintf("actor not found: %s", (&id).String()))
 	}
+	m.detach(ctx, id)
+	m.notifyWatchers(ctx, Terminated{Actor: id, Reason: TerminatedReason_REMOVED})
 	return nil
 }
+
+// detach drops what the postman keeps for an actor that left it.
+func (m *postmanImpl) detach(ctx context.Context, id uuid.UUID) {
+	m.abandon(id)
+	if m.metrics != nil {
+		m.metrics.forget(m, id)
+	}
+	for _, name := range m.names.Names(id) {
+		m.Unbind(ctx, name)
+	}
+	for _, target := range m.watch.forget(id) {
+		target.Transfer(ctx, NewMail[any](id, target.ID(), WatchRequest{Watcher: id, Target: target.ID(), Unwatch: true}, codec.JSON_CODEC, WithKind(MailKind_WATCH_REQUEST)))
+	}
+}
+
+func (m *postmanImpl) Bind(ctx context.Context, name string, id uuid.UUID) *core.CoreError {
+	if _, ok := m.actors.Load(id); !ok {
+		return core.NewCoreError(core.ERROR_CODE_ACTOR_NOT_FOUND, fmt.Sprintf("can not bind %s to unknown actor: %s", name, (&id).String()))
+	}
+	if err := m.names.Bind(name, NewActorAddress(id, NewLocalPostManAddress(m))); err != nil {
+		return err
+	}
+	return m.publishName(ctx, NameBinding{Name: name, Actor: id, Postman: m.id})
+}
+
+func (m *postmanImpl) Unbind(ctx context.Context, name string) *core.CoreError {
+	a, ok := m.names.Lookup(name)
+	if !ok {
+		return core.NewCoreError(core.ERROR_CODE_NAME_NOT_FOUND, fmt.Sprintf("name not bound: %s", name))
+	}
+	m.names.Unbind(name)
+	return m.publishName(ctx, NameBinding{Name: name, Actor: a.ID(), Postman: m.id, Unbind: true})
+}
+
+func (m *postmanImpl) publishName(ctx context.Context, binding NameBinding) *core.CoreError {
+	if m.postoffice == nil {
+		return nil
+	}
+	return m.postoffice.Transfer(ctx, NewMail[any](m.id, m.postoffice.ID(), binding, codec.JSON_CODEC, WithKind(MailKind_NAME_BINDING)))
+}
+
+func (m *postmanImpl) Lookup(ctx context.Context, name string) (Address, *core.CoreError) {
+	if err := ValidateActorName(name); err != nil {
+		return nil, err
+	}
+	if a, ok := m.names.Lookup(name); ok {
+		return a, nil
+	}
+	if resolver, ok := m.postoffice.(NameResolver); ok {
+		if a, ok := resolver.Lookup(name); ok {
+			return a, nil
+		}
+	}
+	return nil, core.NewCoreError(core.ERROR_CODE_NAME_NOT_FOUND, fmt.Sprintf("name not bound: %s", name))
+}
+
+func (m *postmanImpl) Watch(name string, fn func(NameEvent)) func() {
+	return m.names.Watch(name, fn)
+}
+
+func (m *postmanImpl) Host(entityType uuid.UUIDType, receiver MailReceiver) func() {
+	m.hosts.Store(entityType, receiver)
+	return func() {
+		m.hosts.CompareAndDelete(entityType, receiver)
+	}
+}
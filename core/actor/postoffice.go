@@ -2,10 +2,13 @@ package actor
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
 	"github.com/shooyaaa/core/library"
 	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
 )
 
 type Postoffice interface {
@@ -13,19 +16,48 @@ type Postoffice interface {
 	Remove(ctx context.Context, a Address) *core.CoreError
 	Dispatch(ctx context.Context, mail Mail[any]) *core.CoreError
 	ID() uuid.UUID
+	// Lookup resolves a name published by any postman on the ring, the
+	// address routes through this postoffice.
+	Lookup(name string) (Address, bool)
+	Watch(name string, fn func(NameEvent)) (cancel func())
 }
 
 type postofficeImpl struct {
-	h  library.ConsistentHash[Address]
-	id uuid.UUID
+	h           library.ConsistentHash[Address]
+	id          uuid.UUID
+	deadLetters DeadLetterOffice
+	names       NameRegistry
+	shards      map[uuid.UUIDType]int
+	exporter    SpanExporter
+}
+
+type PostofficeOption func(p *postofficeImpl)
+
+// WithPostofficeDeadLetters captures mail no postman is found for.
+func WithPostofficeDeadLetters(deadLetters DeadLetterOffice) PostofficeOption {
+	return func(p *postofficeImpl) {
+		p.deadLetters = deadLetters
+	}
+}
+
+// WithPostofficeSharding routes the mail for actors of entityType by their
+// shard, so all entities of a shard live on the same postman.
+func WithPostofficeSharding(entityType uuid.UUIDType, shards int) PostofficeOption {
+	return func(p *postofficeImpl) {
+		p.shards[entityType] = shards
+	}
 }
 
 func (p *postofficeImpl) ID() uuid.UUID {
 	return p.id
 }
 
-func NewPostoffice(h library.ConsistentHash[Address], id uuid.UUID) Postoffice {
-	return &postofficeImpl{h: h, id: id}
+func NewPostoffice(h library.ConsistentHash[Address], id uuid.UUID, opts ...PostofficeOption) Postoffice {
+	p := &postofficeImpl{h: h, id: id, names: NewNameRegistry(), shards: make(map[uuid.UUIDType]int)}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 func (p *postofficeImpl) Add(ctx context.Context, a Address) *core.CoreError {
@@ -33,16 +65,80 @@ func (p *postofficeImpl) Add(ctx context.Context, a Address) *core.CoreError {
 	return nil
 }
 
+// Remove takes a postman off the ring and tells the remaining postmen, so
+// watchers of its actors learn they are unreachable.
 func (p *postofficeImpl) Remove(ctx context.Context, a Address) *core.CoreError {
 	p.h.Remove(a)
+	for _, node := range p.h.GetNodes() {
+		err := node.Transfer(ctx, NewMail[any](p.id, node.ID(), PostmanUnreachable{Unreachable: a.ID()}, codec.JSON_CODEC, WithKind(MailKind_POSTMAN_UNREACHABLE)))
+		if err != nil {
+			log.ErrorF("error while report unreachable postman to %s: %s\n", node.String(), err.String())
+		}
+	}
 	return nil
 }
 
 func (p *postofficeImpl) Dispatch(ctx context.Context, mail Mail[any]) *core.CoreError {
 	receiver := mail.Receiver()
-	a, ok := p.h.Get((&receiver).String())
+	if receiver == p.id {
+		if binding, ok := asNameBinding(mail); ok {
+			return p.bind(binding)
+		}
+	}
+	var a Address
+	var ok bool
+	if postman, isReply := askPostman(receiver); isReply {
+		// replies go back to the asking postman, not to the hash of the reply address
+		a, ok = p.postman(postman)
+	} else {
+		key := (&receiver).String()
+		if shards, sharded := p.shards[receiver.Type]; sharded {
+			key = ShardKey(receiver, shards)
+		}
+		a, ok = p.h.Get(key)
+	}
 	if ok {
-		return a.Transfer(ctx, mail)
+		traced, finish := startSpan(p.exporter, SpanKind_DISPATCH, (&p.id).String(), mail)
+		err := a.Transfer(ctx, traced)
+		finish(err)
+		return err
+	}
+	err := core.NewCoreError(core.ERROR_CODE_POSTMAN_NOT_FOUND, "postman not found in dispatch")
+	if p.deadLetters != nil {
+		p.deadLetters.Capture(ctx, mail, err)
 	}
-	return core.NewCoreError(core.ERROR_CODE_POSTMAN_NOT_FOUND, "postman not found in dispatch")
+	return err
+}
+
+// postman returns the address of the postman with the given id on the ring.
+func (p *postofficeImpl) postman(id uuid.UUID) (Address, bool) {
+	for _, a := range p.h.GetNodes() {
+		if a.ID() == id {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+func (p *postofficeImpl) bind(binding NameBinding) *core.CoreError {
+	if binding.Unbind {
+		if a, ok := p.names.Lookup(binding.Name); ok && a.ID() == binding.Actor {
+			p.names.Unbind(binding.Name)
+		}
+		return nil
+	}
+	// the actor is reached through its own postman, not by the hash of its id
+	postman, ok := p.postman(binding.Postman)
+	if !ok {
+		return core.NewCoreError(core.ERROR_CODE_POSTMAN_NOT_FOUND, fmt.Sprintf("can not bind %s, postman not on the ring: %s", binding.Name, (&binding.Postman).String()))
+	}
+	return p.names.Bind(binding.Name, NewActorAddress(binding.Actor, postman))
+}
+
+func (p *postofficeImpl) Lookup(name string) (Address, bool) {
+	return p.names.Lookup(name)
+}
+
+func (p *postofficeImpl) Watch(name string, fn func(NameEvent)) func() {
+	return p.names.Watch(name, fn)
 }
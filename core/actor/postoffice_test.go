@@ -2,7 +2,6 @@ package actor
 
 import (
 	"context"
-	"sync"
 	"testing"
 
 	"github.com/shooyaaa/core"
@@ -310,38 +309,3 @@ func TestPostoffice_ID(t *testing.T) {
 
 	assert.Equal(t, expectedID, po.ID())
 }
-
-func TestPostoffice_Actor(t *testing.T) {
-	hash := library.NewConsistentHash[Address](150, nil, nil)
-	const UUIDTypePostoffice uuid.UUIDType = "postoffice"
-	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypePostoffice)
-	po := NewPostoffice(hash, idGen.Next())
-
-	const UUIDTypeTest uuid.UUIDType = "test"
-	addrGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTest)
-	pa := NewActor[Mail[any], any](MailboxType_MEMORY, addrGen.Next(), po)
-	pa.Start(func(mail Mail[any]) {
-		assert.Equal(t, po, mail.Receiver())
-	})
-
-	a1 := NewActor[Mail[any], any](MailboxType_MEMORY, addrGen.Next(), func(mail Mail[any]) {
-	})
-	ctx := context.Background()
-	pm1 := NewPostman()
-	pm1.Add(ctx, a1)
-	pm1.Register(ctx, NewLocalPostOfficeAddress(po))
-
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	a2 := NewActor[Mail[any], any](MailboxType_MEMORY, addrGen.Next(), func(mail Mail[any]) {
-		assert.Equal(t, po, mail.Receiver())
-		wg.Done()
-	})
-	pm2 := NewPostman()
-	pm2.Add(ctx, a2)
-	pm2.Register(ctx, NewLocalPostManAddress(pm2))
-	mail1 := NewMail[any](addrGen.Next(), a2.ID(), "test", codec.JSON_CODEC)
-	err := a1.Mailbox().Send(ctx, mail1)
-	assert.Nil(t, err)
-	wg.Wait()
-}
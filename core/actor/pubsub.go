@@ -0,0 +1,331 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+// UUIDType_PUBSUB marks the mediator of a postman, see PubSubID.
+const UUIDType_PUBSUB uuid.UUIDType = "pubsub"
+
+const DefaultPubSubSyncInterval = 5 * time.Second
+
+// PubSubID is the id the pub/sub mediator of a postman is reached by.
+func PubSubID(postman uuid.UUID) uuid.UUID {
+	return uuid.UUID{Type: UUIDType_PUBSUB, ID: postman.ID}
+}
+
+// ActorPubSubImpl needs the actor to be added to a postman hosting a PubSub.
+// Subscribers receive the published message itself, sent by the publisher.
+type ActorPubSubImpl interface {
+	Subscribe(topic string) *core.CoreError
+	Unsubscribe(topic string) *core.CoreError
+	Publish(topic string, message any) *core.CoreError
+}
+
+type SubscribeRequest struct {
+	Topic       string
+	Subscriber  uuid.UUID
+	Unsubscribe bool
+}
+
+// Publish is sent to a mediator, Forwarded is set on the copy a mediator
+// passes to the mediators of other postmen.
+type Publish struct {
+	Topic     string
+	Message   any
+	Forwarded bool
+}
+
+// Subscriptions tells a peer every topic with subscribers on Node.
+type Subscriptions struct {
+	Node   uuid.UUID
+	Topics []string
+}
+
+// PubSub is the mediator of a postman. It keeps the local subscribers of
+// every topic and replicates the set of its topics to its peers, so a publish
+// is passed once to every postman with subscribers of the topic. The whole
+// set is sent again every sync interval, so a peer that missed an update or
+// restarted catches up. Peers have to add each other.
+type PubSub interface {
+	MailReceiver
+	Subscribe(topic string, subscriber uuid.UUID)
+	Unsubscribe(topic string, subscriber uuid.UUID)
+	Publish(ctx context.Context, sender uuid.UUID, topic string, message any) *core.CoreError
+	// Topics lists the topics with local subscribers.
+	Topics() []string
+	// AddPeer starts replicating to the postman reached by peer.
+	AddPeer(ctx context.Context, peer Address)
+	RemovePeer(id uuid.UUID)
+	Stop()
+}
+
+type pubSubImpl struct {
+	postman      Postman
+	unhost       func()
+	syncInterval time.Duration
+
+	mu     sync.Mutex
+	local  map[string]map[uuid.UUID]bool
+	peers  map[uuid.UUID]Address
+	remote map[uuid.UUID]map[string]bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+type PubSubOption func(ps *pubSubImpl)
+
+// WithPubSubSyncInterval sets how often the topics are sent to every peer
+// again, DefaultPubSubSyncInterval when not positive.
+func WithPubSubSyncInterval(interval time.Duration) PubSubOption {
+	return func(ps *pubSubImpl) {
+		ps.syncInterval = interval
+	}
+}
+
+// NewPubSub hosts a mediator on the postman.
+func NewPubSub(postman Postman, opts ...PubSubOption) PubSub {
+	ps := &pubSubImpl{
+		postman: postman,
+		local:   make(map[string]map[uuid.UUID]bool),
+		peers:   make(map[uuid.UUID]Address),
+		remote:  make(map[uuid.UUID]map[string]bool),
+		stop:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(ps)
+	}
+	if ps.syncInterval <= 0 {
+		ps.syncInterval = DefaultPubSubSyncInterval
+	}
+	ps.unhost = postman.Host(UUIDType_PUBSUB, ps)
+	ps.wg.Add(1)
+	go ps.run()
+	return ps
+}
+
+func (ps *pubSubImpl) run() {
+	defer ps.wg.Done()
+	ticker := time.NewTicker(ps.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ps.replicate(context.Background())
+		case <-ps.stop:
+			return
+		}
+	}
+}
+
+func (ps *pubSubImpl) Receive(ctx context.Context, mail Mail[any]) *core.CoreError {
+	message := mail.Message()
+	switch mail.Kind() {
+	case MailKind_SUBSCRIBE:
+		if request, ok := AsMessage[SubscribeRequest](message); ok {
+			if request.Unsubscribe {
+				ps.Unsubscribe(request.Topic, request.Subscriber)
+			} else {
+				ps.Subscribe(request.Topic, request.Subscriber)
+			}
+			return nil
+		}
+	case MailKind_PUBLISH:
+		if publish, ok := AsMessage[Publish](message); ok {
+			if publish.Forwarded {
+				ps.deliver(ctx, mail.Sender(), publish.Topic, publish.Message)
+				return nil
+			}
+			return ps.Publish(ctx, mail.Sender(), publish.Topic, publish.Message)
+		}
+	case MailKind_SUBSCRIPTIONS:
+		if subscriptions, ok := AsMessage[Subscriptions](message); ok {
+			topics := make(map[string]bool, len(subscriptions.Topics))
+			for _, topic := range subscriptions.Topics {
+				topics[topic] = true
+			}
+			ps.mu.Lock()
+			ps.remote[subscriptions.Node] = topics
+			ps.mu.Unlock()
+			return nil
+		}
+	}
+	return core.NewCoreError(core.ERROR_CODE_ACTOR_NOT_FOUND, fmt.Sprintf("unknown pub/sub mail: %v", message))
+}
+
+func (ps *pubSubImpl) Subscribe(topic string, subscriber uuid.UUID) {
+	ps.mu.Lock()
+	subscribers, ok := ps.local[topic]
+	if !ok {
+		subscribers = make(map[uuid.UUID]bool)
+		ps.local[topic] = subscribers
+	}
+	subscribers[subscriber] = true
+	ps.mu.Unlock()
+	if !ok {
+		ps.replicate(context.Background())
+	}
+}
+
+func (ps *pubSubImpl) Unsubscribe(topic string, subscriber uuid.UUID) {
+	ps.mu.Lock()
+	subscribers := ps.local[topic]
+	delete(subscribers, subscriber)
+	emptied := subscribers != nil && len(subscribers) == 0
+	if emptied {
+		delete(ps.local, topic)
+	}
+	ps.mu.Unlock()
+	if emptied {
+		ps.replicate(context.Background())
+	}
+}
+
+// Publish delivers to the local subscribers and once to every peer with
+// subscribers of the topic.
+func (ps *pubSubImpl) Publish(ctx context.Context, sender uuid.UUID, topic string, message any) *core.CoreError {
+	ps.mu.Lock()
+	var peers []Address
+	for id, peer := range ps.peers {
+		if ps.remote[id][topic] {
+			peers = append(peers, peer)
+		}
+	}
+	ps.mu.Unlock()
+	ps.deliver(ctx, sender, topic, message)
+	var failed *core.CoreError
+	for _, peer := range peers {
+		mail := NewMail[any](sender, PubSubID(peer.ID()), Publish{Topic: topic, Message: message, Forwarded: true}, codec.JSON_CODEC, WithKind(MailKind_PUBLISH))
+		if err := peer.Transfer(ctx, mail); err != nil {
+			log.ErrorF("error while publish %s to %s: %s\n", topic, peer.String(), err.String())
+			failed = err
+		}
+	}
+	return failed
+}
+
+// deliver hands the message to the local subscribers, subscribers that are
+// gone are dropped.
+func (ps *pubSubImpl) deliver(ctx context.Context, sender uuid.UUID, topic string, message any) {
+	ps.mu.Lock()
+	subscribers := make([]uuid.UUID, 0, len(ps.local[topic]))
+	for subscriber := range ps.local[topic] {
+		subscribers = append(subscribers, subscriber)
+	}
+	ps.mu.Unlock()
+	local, direct := ps.postman.(interface {
+		deliverLocal(ctx context.Context, mail Mail[any]) *core.CoreError
+	})
+	for _, subscriber := range subscribers {
+		mail := NewMail[any](sender, subscriber, message, codec.JSON_CODEC)
+		var err *core.CoreError
+		if direct {
+			err = local.deliverLocal(ctx, mail)
+		} else {
+			err = ps.postman.Receive(ctx, mail)
+		}
+		if err != nil && err.Code() == core.ERROR_CODE_ACTOR_NOT_FOUND {
+			ps.Unsubscribe(topic, subscriber)
+		}
+	}
+}
+
+// deliverLocal puts mail straight into the mailbox of an actor added to the
+// postman, without the control and hosting steps of Receive.
+func (m *postmanImpl) deliverLocal(ctx context.Context, mail Mail[any]) *core.CoreError {
+	receiver := mail.Receiver()
+	a, ok := m.actors.Load(receiver)
+	if !ok {
+		return core.NewCoreError(core.ERROR_CODE_ACTOR_NOT_FOUND, fmt.Sprintf("actor not found: %s", (&receiver).String()))
+	}
+	return m.enqueue(ctx, a.(Actor[Mail[any], any]), mail)
+}
+
+func (ps *pubSubImpl) Topics() []string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	topics := make([]string, 0, len(ps.local))
+	for topic := range ps.local {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func (ps *pubSubImpl) AddPeer(ctx context.Context, peer Address) {
+	ps.mu.Lock()
+	ps.peers[peer.ID()] = peer
+	ps.mu.Unlock()
+	ps.sendSubscriptions(ctx, peer, ps.Topics())
+}
+
+func (ps *pubSubImpl) RemovePeer(id uuid.UUID) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	delete(ps.peers, id)
+	delete(ps.remote, id)
+}
+
+// replicate sends the local topics to every peer.
+func (ps *pubSubImpl) replicate(ctx context.Context) {
+	topics := ps.Topics()
+	ps.mu.Lock()
+	peers := make([]Address, 0, len(ps.peers))
+	for _, peer := range ps.peers {
+		peers = append(peers, peer)
+	}
+	ps.mu.Unlock()
+	for _, peer := range peers {
+		ps.sendSubscriptions(ctx, peer, topics)
+	}
+}
+
+func (ps *pubSubImpl) sendSubscriptions(ctx context.Context, peer Address, topics []string) {
+	self := PubSubID(ps.postman.ID())
+	mail := NewMail[any](self, PubSubID(peer.ID()), Subscriptions{Node: ps.postman.ID(), Topics: topics}, codec.JSON_CODEC, WithKind(MailKind_SUBSCRIPTIONS))
+	if err := peer.Transfer(ctx, mail); err != nil {
+		log.ErrorF("error while replicate subscriptions to %s: %s\n", peer.String(), err.String())
+	}
+}
+
+func (ps *pubSubImpl) Stop() {
+	ps.unhost()
+	select {
+	case <-ps.stop:
+		return
+	default:
+		close(ps.stop)
+	}
+	ps.wg.Wait()
+}
+
+func (a *actorImpl[T, D]) Subscribe(topic string) *core.CoreError {
+	return a.pubSub(MailKind_SUBSCRIBE, SubscribeRequest{Topic: topic, Subscriber: a.id})
+}
+
+func (a *actorImpl[T, D]) Unsubscribe(topic string) *core.CoreError {
+	return a.pubSub(MailKind_SUBSCRIBE, SubscribeRequest{Topic: topic, Subscriber: a.id, Unsubscribe: true})
+}
+
+func (a *actorImpl[T, D]) Publish(topic string, message any) *core.CoreError {
+	return a.pubSub(MailKind_PUBLISH, Publish{Topic: topic, Message: message})
+}
+
+// pubSub sends message to the mediator of the postman the actor joined.
+func (a *actorImpl[T, D]) pubSub(kind MailKind, message any) *core.CoreError {
+	postman, err := a.joinedPostman()
+	if err != nil {
+		return err
+	}
+	return postman.Deliver(context.Background(), NewMail[any](a.id, PubSubID(postman.ID()), message, codec.JSON_CODEC, WithKind(kind)))
+}
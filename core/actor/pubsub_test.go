@@ -0,0 +1,148 @@
+package actor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypePubSub uuid.UUIDType = "pubsub_test"
+
+// countingAddress counts the publishes passed to a peer.
+type countingAddress struct {
+	Address
+	publishes int32
+}
+
+func (c *countingAddress) Transfer(ctx context.Context, mail Mail[any]) *core.CoreError {
+	if _, ok := mail.Message().(Publish); ok {
+		atomic.AddInt32(&c.publishes, 1)
+	}
+	return c.Address.Transfer(ctx, mail)
+}
+
+// failingAddress fails the first fail transfers.
+type failingAddress struct {
+	Address
+	fail int32
+}
+
+func (f *failingAddress) Transfer(ctx context.Context, mail Mail[any]) *core.CoreError {
+	if atomic.AddInt32(&f.fail, -1) >= 0 {
+		return core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, "peer down")
+	}
+	return f.Address.Transfer(ctx, mail)
+}
+
+func expectNothing(t *testing.T, ch chan any) {
+	select {
+	case m := <-ch:
+		t.Fatalf("unexpected message %v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPubSub_AcrossPostmen(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypePubSub)
+	ctx := context.Background()
+	pm1 := NewPostman(WithPostmanID(idGen.Next()))
+	pm2 := NewPostman(WithPostmanID(idGen.Next()))
+	ps1, ps2 := NewPubSub(pm1), NewPubSub(pm2)
+	defer ps1.Stop()
+	defer ps2.Stop()
+	toPm2 := &countingAddress{Address: NewLocalPostManAddress(pm2)}
+	ps1.AddPeer(ctx, toPm2)
+	ps2.AddPeer(ctx, NewLocalPostManAddress(pm1))
+
+	a1, a1Received := newReceivingActor(idGen.Next())
+	a2, a2Received := newReceivingActor(idGen.Next())
+	b1, b1Received := newReceivingActor(idGen.Next())
+	b2, b2Received := newReceivingActor(idGen.Next())
+	for _, a := range []Actor[Mail[any], any]{a1, a2, b1, b2} {
+		defer a.Stop()
+	}
+	pm1.Add(ctx, a1)
+	pm1.Add(ctx, a2)
+	pm2.Add(ctx, b1)
+	pm2.Add(ctx, b2)
+	assert.Nil(t, a1.Subscribe("room/1"))
+	assert.Nil(t, b1.Subscribe("room/1"))
+	assert.Nil(t, b2.Subscribe("room/1"))
+	assert.Equal(t, []string{"room/1"}, ps2.Topics())
+
+	assert.Nil(t, a2.Publish("room/1", "hello"))
+	expectMessage(t, a1Received, "hello")
+	expectMessage(t, b1Received, "hello")
+	expectMessage(t, b2Received, "hello")
+	expectNothing(t, a2Received)
+	expectNothing(t, b1Received)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&toPm2.publishes), "一个节点只转发一次")
+
+	// once nobody on pm2 listens, the publish stays on pm1
+	assert.Nil(t, b1.Unsubscribe("room/1"))
+	assert.Nil(t, b2.Unsubscribe("room/1"))
+	assert.Empty(t, ps2.Topics())
+	assert.Nil(t, a2.Publish("room/1", "again"))
+	expectMessage(t, a1Received, "again")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&toPm2.publishes))
+}
+
+func TestPubSub_DropsStoppedSubscribers(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypePubSub)
+	ctx := context.Background()
+	pm := NewPostman(WithPostmanID(idGen.Next()))
+	ps := NewPubSub(pm)
+	defer ps.Stop()
+
+	subscriber, _ := newReceivingActor(idGen.Next())
+	pm.Add(ctx, subscriber)
+	assert.Nil(t, subscriber.Subscribe("news"))
+	subscriber.Stop()
+	expectTerminated(t, subscriber)
+	// the postman forgets the actor right after it terminated
+	assert.Eventually(t, func() bool {
+		assert.Nil(t, ps.Publish(ctx, uuid.UUID{}, "news", "anyone?"))
+		return len(ps.Topics()) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestPubSub_NotJoined(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypePubSub)
+	a := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	err := a.Subscribe("news")
+	assert.NotNil(t, err)
+	assert.Equal(t, core.ERROR_CODE_POSTMAN_NOT_FOUND, err.Code())
+}
+
+func TestPubSub_ResyncAfterFailure(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypePubSub)
+	ctx := context.Background()
+	pm1 := NewPostman(WithPostmanID(idGen.Next()))
+	pm2 := NewPostman(WithPostmanID(idGen.Next()))
+	ps1 := NewPubSub(pm1, WithPubSubSyncInterval(20*time.Millisecond))
+	ps2 := NewPubSub(pm2, WithPubSubSyncInterval(20*time.Millisecond))
+	defer ps1.Stop()
+	defer ps2.Stop()
+	ps1.AddPeer(ctx, NewLocalPostManAddress(pm2))
+	// 订阅第一次同步时对端不可达，之后的定期同步补上
+	ps2.AddPeer(ctx, &failingAddress{Address: NewLocalPostManAddress(pm1), fail: 2})
+
+	subscriber, received := newReceivingActor(idGen.Next())
+	defer subscriber.Stop()
+	pm2.Add(ctx, subscriber)
+	assert.Nil(t, subscriber.Subscribe("news"))
+	assert.Eventually(t, func() bool {
+		assert.Nil(t, ps1.Publish(ctx, uuid.UUID{}, "news", "extra"))
+		select {
+		case message := <-received:
+			return message == "extra"
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+}
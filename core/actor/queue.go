@@ -0,0 +1,163 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type OverflowPolicy string
+
+// BLOCK waits for room until the context is done, DROP_NEWEST discards the
+// incoming mail, DROP_OLDEST discards the head of the queue and FAIL returns
+// ErrMailboxFull.
+const OverflowPolicy_BLOCK OverflowPolicy = "block"
+const OverflowPolicy_DROP_NEWEST OverflowPolicy = "drop_newest"
+const OverflowPolicy_DROP_OLDEST OverflowPolicy = "drop_oldest"
+const OverflowPolicy_FAIL OverflowPolicy = "fail"
+
+var ErrMailboxFull = errors.New("mailbox full")
+var ErrMailboxClosed = errors.New("mailbox closed")
+
+// mailQueue is a bounded FIFO. Items classified as system skip the capacity
+// check and are taken before any other item.
+type mailQueue[T any] struct {
+	mu       sync.Mutex
+	system   []T
+	items    []T
+	capacity int
+	policy   OverflowPolicy
+	isSystem func(T) bool
+	closed   bool
+	dropped  int64
+	changed  chan struct{}
+}
+
+func newMailQueue[T any](capacity int, policy OverflowPolicy, isSystem func(T) bool) *mailQueue[T] {
+	return &mailQueue[T]{capacity: capacity, policy: policy, isSystem: isSystem, changed: make(chan struct{})}
+}
+
+// signal wakes every goroutine waiting for a change, the caller holds mu.
+func (q *mailQueue[T]) signal() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+func (q *mailQueue[T]) push(ctx context.Context, item T) error {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return ErrMailboxClosed
+		}
+		if q.isSystem != nil && q.isSystem(item) {
+			q.system = append(q.system, item)
+			q.signal()
+			q.mu.Unlock()
+			return nil
+		}
+		if q.capacity <= 0 || len(q.items) < q.capacity {
+			q.items = append(q.items, item)
+			q.signal()
+			q.mu.Unlock()
+			return nil
+		}
+		switch q.policy {
+		case OverflowPolicy_DROP_NEWEST:
+			q.dropped++
+			q.mu.Unlock()
+			return nil
+		case OverflowPolicy_DROP_OLDEST:
+			var zero T
+			q.items[0] = zero
+			q.items = append(q.items[1:], item)
+			q.dropped++
+			q.signal()
+			q.mu.Unlock()
+			return nil
+		case OverflowPolicy_FAIL:
+			q.mu.Unlock()
+			return fmt.Errorf("%w: capacity %d", ErrMailboxFull, q.capacity)
+		}
+		changed := q.changed
+		q.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// pushFront puts items back at the head in their original order, regardless
+// of the capacity.
+func (q *mailQueue[T]) pushFront(items ...T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrMailboxClosed
+	}
+	q.items = append(append(make([]T, 0, len(items)+len(q.items)), items...), q.items...)
+	q.signal()
+	return nil
+}
+
+// pop takes the next item, remaining items are still handed out after close.
+func (q *mailQueue[T]) pop(ctx context.Context) (T, error) {
+	for {
+		q.mu.Lock()
+		if item, ok := q.take(); ok {
+			q.signal()
+			q.mu.Unlock()
+			return item, nil
+		}
+		if q.closed {
+			q.mu.Unlock()
+			var zero T
+			return zero, fmt.Errorf("channel closed")
+		}
+		changed := q.changed
+		q.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+func (q *mailQueue[T]) take() (T, bool) {
+	var zero T
+	if len(q.system) > 0 {
+		item := q.system[0]
+		q.system[0] = zero
+		q.system = q.system[1:]
+		return item, true
+	}
+	if len(q.items) > 0 {
+		item := q.items[0]
+		q.items[0] = zero
+		q.items = q.items[1:]
+		return item, true
+	}
+	return zero, false
+}
+
+func (q *mailQueue[T]) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.system) + len(q.items)
+}
+
+func (q *mailQueue[T]) close() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	q.closed = true
+	q.signal()
+	return true
+}
@@ -0,0 +1,321 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+// UUIDType_RELIABLE is the type ReliableDelivery is hosted under, no mail is
+// addressed to it.
+const UUIDType_RELIABLE uuid.UUIDType = "reliable"
+
+const DefaultRedeliverAfter = time.Second
+const DefaultDedupWindow = 1024
+const DefaultReliableExpiry = 10 * time.Minute
+
+type ReliableConfig struct {
+	// RedeliverAfter is how long a mail waits for its ack before it is sent again
+	RedeliverAfter time.Duration
+	// MaxAttempts gives up on a mail after that many sends, zero never gives up
+	MaxAttempts int
+	// DedupWindow is how many sequence numbers of a sender are remembered
+	DedupWindow int64
+	// Expiry forgets a sender idle for that long, its sequence and the
+	// window of its delivered sequence numbers
+	Expiry time.Duration
+}
+
+// ReliableDelivery delivers mail at least once. Every mail sent through it
+// gets the next sequence number of its sender and is kept until the postman
+// of the receiver acknowledges it, mail not acknowledged within
+// RedeliverAfter is delivered again. The receiving side drops mail whose
+// (sender, seq) it has already delivered, so the receiver sees it once as
+// long as the duplicate arrives within DedupWindow. The sequence of a sender
+// is numbered under an epoch that grows with every run, a restarted process
+// or a sender forgotten after Expiry starts again from 1 under a new epoch
+// and the receiver starts a new window for it. Acks are delivered to the
+// sender, so senders have to be reachable like any other actor and both
+// postmen need a ReliableDelivery.
+type ReliableDelivery interface {
+	MailReceiver
+	Send(ctx context.Context, mail Mail[any]) *core.CoreError
+	// Unconfirmed counts the mail waiting for an ack.
+	Unconfirmed() int
+	Stop()
+}
+
+type reliableKey struct {
+	sender uuid.UUID
+	epoch  int64
+	seq    int64
+}
+
+// senderSequence numbers the mail of one sender.
+type senderSequence struct {
+	epoch    int64
+	seq      int64
+	lastUsed time.Time
+}
+
+type unconfirmedMail struct {
+	mail     Mail[any]
+	attempts int
+	deadline time.Time
+}
+
+// dedupWindow remembers the sequence numbers delivered from one epoch of a
+// sender.
+type dedupWindow struct {
+	epoch    int64
+	highest  int64
+	seen     map[int64]bool
+	lastSeen time.Time
+}
+
+type reliableImpl struct {
+	postman Postman
+	config  ReliableConfig
+	unhost  func()
+
+	mu          sync.Mutex
+	epoch       int64
+	sequences   map[uuid.UUID]*senderSequence
+	unconfirmed map[reliableKey]*unconfirmedMail
+	windows     map[uuid.UUID]*dedupWindow
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewReliableDelivery hosts the reliable delivery of the postman.
+func NewReliableDelivery(postman Postman, config ReliableConfig) ReliableDelivery {
+	if config.RedeliverAfter <= 0 {
+		config.RedeliverAfter = DefaultRedeliverAfter
+	}
+	if config.DedupWindow <= 0 {
+		config.DedupWindow = DefaultDedupWindow
+	}
+	if config.Expiry <= 0 {
+		config.Expiry = DefaultReliableExpiry
+	}
+	r := &reliableImpl{
+		postman:     postman,
+		config:      config,
+		sequences:   make(map[uuid.UUID]*senderSequence),
+		unconfirmed: make(map[reliableKey]*unconfirmedMail),
+		windows:     make(map[uuid.UUID]*dedupWindow),
+		stop:        make(chan struct{}),
+	}
+	r.unhost = postman.Host(UUIDType_RELIABLE, r)
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+// Send numbers the mail and delivers it, the mail is kept for redelivery
+// even when the first delivery fails.
+func (r *reliableImpl) Send(ctx context.Context, mail Mail[any]) *core.CoreError {
+	sender := mail.Sender()
+	now := time.Now()
+	r.mu.Lock()
+	sequence, ok := r.sequences[sender]
+	if !ok {
+		sequence = &senderSequence{epoch: r.nextEpoch(now)}
+		r.sequences[sender] = sequence
+	}
+	sequence.seq++
+	sequence.lastUsed = now
+	key := reliableKey{sender: sender, epoch: sequence.epoch, seq: sequence.seq}
+	numbered := NewMail(sender, mail.Receiver(), mail.Message(), mail.CodeC(), WithCorrelationID(mail.CorrelationID()), WithSeq(key.seq), WithEpoch(key.epoch), WithTrace(mail.Trace()))
+	r.unconfirmed[key] = &unconfirmedMail{
+		mail:     numbered,
+		attempts: 1,
+		deadline: now.Add(r.config.RedeliverAfter),
+	}
+	r.mu.Unlock()
+	return r.postman.Deliver(ctx, numbered)
+}
+
+// nextEpoch starts a new sequence, epochs follow the clock so they also grow
+// across restarts. The caller holds mu.
+func (r *reliableImpl) nextEpoch(now time.Time) int64 {
+	epoch := now.UnixNano()
+	if epoch <= r.epoch {
+		epoch = r.epoch + 1
+	}
+	r.epoch = epoch
+	return epoch
+}
+
+// Receive handles the numbered mail and the acks the postman got.
+func (r *reliableImpl) Receive(ctx context.Context, mail Mail[any]) *core.CoreError {
+	if mail.Seq() == 0 {
+		r.confirm(mail)
+		return nil
+	}
+	key := reliableKey{sender: mail.Sender(), epoch: mail.Epoch(), seq: mail.Seq()}
+	if !r.delivered(key) {
+		plain := NewMail(mail.Sender(), mail.Receiver(), mail.Message(), mail.CodeC(), WithCorrelationID(mail.CorrelationID()), WithTrace(mail.Trace()))
+		if err := r.postman.Receive(ctx, plain); err != nil {
+			// not acknowledged, the sender tries again
+			return err
+		}
+		r.remember(key)
+	}
+	ack := NewMail[any](mail.Receiver(), mail.Sender(), nil, mail.CodeC(), WithAck(mail.Seq()), WithEpoch(mail.Epoch()))
+	if err := r.postman.Deliver(ctx, ack); err != nil {
+		log.ErrorF("error while ack mail %d of %s: %s\n", key.seq, (&key.sender).String(), err.String())
+	}
+	return nil
+}
+
+// confirm forgets the mail the ack is for, the ack is addressed to its sender.
+func (r *reliableImpl) confirm(ack Mail[any]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.unconfirmed, reliableKey{sender: ack.Receiver(), epoch: ack.Epoch(), seq: ack.Ack()})
+}
+
+func (r *reliableImpl) delivered(key reliableKey) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.windows[key.sender]
+	if !ok || key.epoch > w.epoch {
+		return false
+	}
+	if key.epoch < w.epoch {
+		// a late copy from a run the sender has left behind
+		return true
+	}
+	// older than the window, it must have been delivered long ago
+	return w.seen[key.seq] || key.seq <= w.highest-r.config.DedupWindow
+}
+
+func (r *reliableImpl) remember(key reliableKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.windows[key.sender]
+	if !ok || key.epoch > w.epoch {
+		w = &dedupWindow{epoch: key.epoch, seen: make(map[int64]bool)}
+		r.windows[key.sender] = w
+	}
+	w.lastSeen = time.Now()
+	w.seen[key.seq] = true
+	if key.seq <= w.highest {
+		return
+	}
+	w.highest = key.seq
+	for seq := range w.seen {
+		if seq <= w.highest-r.config.DedupWindow {
+			delete(w.seen, seq)
+		}
+	}
+}
+
+// expire forgets the senders idle for Expiry. A sender with unconfirmed mail
+// keeps its sequence, a new one would restart below the mail redelivered.
+func (r *reliableImpl) expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pending := make(map[uuid.UUID]bool)
+	for key := range r.unconfirmed {
+		pending[key.sender] = true
+	}
+	for sender, sequence := range r.sequences {
+		if !pending[sender] && now.Sub(sequence.lastUsed) >= r.config.Expiry {
+			delete(r.sequences, sender)
+		}
+	}
+	for sender, w := range r.windows {
+		if now.Sub(w.lastSeen) >= r.config.Expiry {
+			delete(r.windows, sender)
+		}
+	}
+}
+
+func (r *reliableImpl) Unconfirmed() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.unconfirmed)
+}
+
+// redeliver sends the overdue mail again and gives up on the mail that ran
+// out of attempts.
+func (r *reliableImpl) redeliver(now time.Time) {
+	var due, expired []Mail[any]
+	r.mu.Lock()
+	for key, u := range r.unconfirmed {
+		if now.Before(u.deadline) {
+			continue
+		}
+		if r.config.MaxAttempts > 0 && u.attempts >= r.config.MaxAttempts {
+			delete(r.unconfirmed, key)
+			expired = append(expired, u.mail)
+			continue
+		}
+		u.attempts++
+		u.deadline = now.Add(r.config.RedeliverAfter)
+		due = append(due, u.mail)
+	}
+	r.mu.Unlock()
+	ctx := context.Background()
+	for _, mail := range due {
+		if err := r.postman.Deliver(ctx, mail); err != nil {
+			log.WarnF("error while redeliver mail %d: %s", mail.Seq(), err.String())
+		}
+	}
+	for _, mail := range expired {
+		receiver := mail.Receiver()
+		cause := core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, fmt.Sprintf("mail %d to %s not acknowledged after %d attempts", mail.Seq(), (&receiver).String(), r.config.MaxAttempts))
+		if m, ok := r.postman.(*postmanImpl); ok {
+			m.undeliverable(ctx, mail, cause)
+		}
+		log.ErrorF("%s\n", cause.String())
+	}
+}
+
+func (r *reliableImpl) run() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.config.RedeliverAfter / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.redeliver(now)
+			r.expire(now)
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Stop stops redelivering, unconfirmed mail is dropped.
+func (r *reliableImpl) Stop() {
+	r.unhost()
+	select {
+	case <-r.stop:
+		return
+	default:
+		close(r.stop)
+	}
+	r.wg.Wait()
+}
+
+// reliable hands numbered mail and acks to the ReliableDelivery hosted on the
+// postman, without one they are delivered as plain mail.
+func (m *postmanImpl) reliable(ctx context.Context, mail Mail[any]) (bool, *core.CoreError) {
+	if mail.Seq() == 0 && mail.Ack() == 0 {
+		return false, nil
+	}
+	r, ok := m.hosts.Load(UUIDType_RELIABLE)
+	if !ok {
+		return false, nil
+	}
+	return true, r.(MailReceiver).Receive(ctx, mail)
+}
@@ -0,0 +1,189 @@
+package actor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeReliable uuid.UUIDType = "reliable_test"
+
+// flakyAddress drops the first numbered mails and can pass every numbered
+// mail twice.
+type flakyAddress struct {
+	Address
+	mu        sync.Mutex
+	drop      int
+	duplicate bool
+}
+
+func (f *flakyAddress) Transfer(ctx context.Context, mail Mail[any]) *core.CoreError {
+	if mail.Seq() == 0 {
+		return f.Address.Transfer(ctx, mail)
+	}
+	f.mu.Lock()
+	dropped := f.drop > 0
+	if dropped {
+		f.drop--
+	}
+	f.mu.Unlock()
+	if dropped {
+		return nil
+	}
+	if f.duplicate {
+		f.Address.Transfer(ctx, mail)
+	}
+	return f.Address.Transfer(ctx, mail)
+}
+
+type reliablePair struct {
+	sender, receiver     Postman
+	toReceiver           *flakyAddress
+	outgoing, incoming   ReliableDelivery
+	senderID, receiverID uuid.UUID
+	received             chan any
+}
+
+func newReliablePair(t *testing.T, config ReliableConfig, opts ...PostmanOption) *reliablePair {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeReliable)
+	ctx := context.Background()
+	p := &reliablePair{
+		sender:   NewPostman(append([]PostmanOption{WithPostmanID(idGen.Next())}, opts...)...),
+		receiver: NewPostman(WithPostmanID(idGen.Next())),
+	}
+	p.toReceiver = &flakyAddress{Address: NewLocalPostManAddress(p.receiver)}
+	ring := library.NewConsistentHash[Address](150, nil, nil)
+	po := NewPostoffice(ring, idGen.Next())
+	po.Add(ctx, NewLocalPostManAddress(p.sender))
+	po.Add(ctx, p.toReceiver)
+	p.sender.Register(ctx, NewLocalPostOfficeAddress(po))
+	p.receiver.Register(ctx, NewLocalPostOfficeAddress(po))
+	p.outgoing = NewReliableDelivery(p.sender, config)
+	p.incoming = NewReliableDelivery(p.receiver, config)
+	t.Cleanup(p.outgoing.Stop)
+	t.Cleanup(p.incoming.Stop)
+
+	p.senderID = idOn(ring, idGen, p.sender)
+	p.receiverID = idOn(ring, idGen, p.receiver)
+	var a Actor[Mail[any], any]
+	a, p.received = newReceivingActor(p.receiverID)
+	t.Cleanup(a.Stop)
+	p.receiver.Add(ctx, a)
+	return p
+}
+
+func (p *reliablePair) send(t *testing.T, message any) {
+	assert.Nil(t, p.outgoing.Send(context.Background(), NewMail[any](p.senderID, p.receiverID, message, codec.JSON_CODEC)))
+}
+
+func (p *reliablePair) expectConfirmed(t *testing.T) {
+	assert.Eventually(t, func() bool {
+		return p.outgoing.Unconfirmed() == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestReliableDelivery_Redeliver(t *testing.T) {
+	p := newReliablePair(t, ReliableConfig{RedeliverAfter: 50 * time.Millisecond})
+	p.toReceiver.drop = 1
+	p.send(t, "lost once")
+	assert.Equal(t, 1, p.outgoing.Unconfirmed())
+	expectMessage(t, p.received, "lost once")
+	p.expectConfirmed(t)
+	expectNothing(t, p.received)
+}
+
+func TestReliableDelivery_Dedup(t *testing.T) {
+	p := newReliablePair(t, ReliableConfig{RedeliverAfter: 50 * time.Millisecond})
+	p.toReceiver.duplicate = true
+	for _, message := range []any{"m1", "m2", "m3"} {
+		p.send(t, message)
+	}
+	for _, message := range []any{"m1", "m2", "m3"} {
+		expectMessage(t, p.received, message)
+	}
+	p.expectConfirmed(t)
+	expectNothing(t, p.received)
+}
+
+func TestReliableDelivery_GiveUp(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeReliable)
+	deadLetters := NewDeadLetterOffice(idGen.Next(), 10, false)
+	defer deadLetters.Stop()
+	p := newReliablePair(t, ReliableConfig{RedeliverAfter: 20 * time.Millisecond, MaxAttempts: 3}, WithPostmanDeadLetters(deadLetters))
+	p.toReceiver.drop = 3
+	p.send(t, "never arrives")
+	p.expectConfirmed(t)
+	assert.Eventually(t, func() bool {
+		return len(deadLetters.Letters()) == 1
+	}, time.Second, 5*time.Millisecond)
+	if letters := deadLetters.Letters(); assert.Len(t, letters, 1) {
+		assert.Equal(t, "never arrives", letters[0].Mail.Message())
+		assert.Equal(t, int64(1), letters[0].Mail.Seq())
+	}
+	expectNothing(t, p.received)
+}
+
+func TestReliableDelivery_SenderRestart(t *testing.T) {
+	p := newReliablePair(t, ReliableConfig{RedeliverAfter: 50 * time.Millisecond})
+	p.send(t, "before restart")
+	expectMessage(t, p.received, "before restart")
+	p.expectConfirmed(t)
+
+	// 重启后序号从 1 重新开始，新的 epoch 不能被当成重复
+	p.outgoing.Stop()
+	p.outgoing = NewReliableDelivery(p.sender, ReliableConfig{RedeliverAfter: 50 * time.Millisecond})
+	t.Cleanup(p.outgoing.Stop)
+	p.send(t, "after restart")
+	expectMessage(t, p.received, "after restart")
+	p.expectConfirmed(t)
+	expectNothing(t, p.received)
+}
+
+func TestReliableDelivery_OldEpoch(t *testing.T) {
+	r := NewReliableDelivery(NewPostman(), ReliableConfig{}).(*reliableImpl)
+	defer r.Stop()
+	sender := uuid.NewSimpleUUIDGenerator(UUIDTypeReliable).Next()
+	r.remember(reliableKey{sender: sender, epoch: 2, seq: 1})
+	assert.True(t, r.delivered(reliableKey{sender: sender, epoch: 2, seq: 1}))
+	assert.True(t, r.delivered(reliableKey{sender: sender, epoch: 1, seq: 5}))
+	assert.False(t, r.delivered(reliableKey{sender: sender, epoch: 3, seq: 1}))
+}
+
+func TestReliableDelivery_Expire(t *testing.T) {
+	p := newReliablePair(t, ReliableConfig{RedeliverAfter: 20 * time.Millisecond, Expiry: 50 * time.Millisecond})
+	p.send(t, "m1")
+	expectMessage(t, p.received, "m1")
+	p.expectConfirmed(t)
+	outgoing, incoming := p.outgoing.(*reliableImpl), p.incoming.(*reliableImpl)
+	assert.Eventually(t, func() bool {
+		outgoing.mu.Lock()
+		defer outgoing.mu.Unlock()
+		incoming.mu.Lock()
+		defer incoming.mu.Unlock()
+		return len(outgoing.sequences) == 0 && len(incoming.windows) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	// 遗忘之后重新编号，仍然只投递一次
+	p.send(t, "m2")
+	expectMessage(t, p.received, "m2")
+	p.expectConfirmed(t)
+	expectNothing(t, p.received)
+}
+
+func TestMailEnvelope_SeqAck(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeReliable)
+	data, err := EncodeMail(NewMail[any](idGen.Next(), idGen.Next(), "numbered", codec.JSON_CODEC, WithSeq(3), WithAck(2), WithEpoch(7)))
+	assert.NoError(t, err)
+	decoded, err := DecodeMail(data)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), decoded.Seq())
+	assert.Equal(t, int64(2), decoded.Ack())
+	assert.Equal(t, int64(7), decoded.Epoch())
+}
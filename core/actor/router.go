@@ -0,0 +1,379 @@
+package actor
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+type RoutingStrategy string
+
+const RoutingStrategy_ROUND_ROBIN RoutingStrategy = "round_robin"
+const RoutingStrategy_RANDOM RoutingStrategy = "random"
+const RoutingStrategy_SMALLEST_MAILBOX RoutingStrategy = "smallest_mailbox"
+const RoutingStrategy_CONSISTENT_HASH RoutingStrategy = "consistent_hash"
+const RoutingStrategy_BROADCAST RoutingStrategy = "broadcast"
+
+// RoutingStrategy_SCATTER_GATHER asks every routee and replies to the sender
+// with the first answer, the router has to be added to a postman.
+const RoutingStrategy_SCATTER_GATHER RoutingStrategy = "scatter_gather"
+
+const DefaultScatterGatherWithin = 5 * time.Second
+const DefaultResizeInterval = time.Second
+
+// ConsistentHashable lets a message choose the key it is hashed by.
+type ConsistentHashable interface {
+	ConsistentHashKey() any
+}
+
+// PoolResizer grows the pool by one worker when every worker has at least
+// PressureThreshold mails queued, and shrinks it by one when less than
+// BackoffThreshold of the workers have any mail at all.
+type PoolResizer struct {
+	Lower             int
+	Upper             int
+	PressureThreshold int
+	BackoffThreshold  float64
+	Interval          time.Duration
+}
+
+type RouterConfig struct {
+	Strategy RoutingStrategy
+	// Within bounds a scatter gather, DefaultScatterGatherWithin when zero
+	Within time.Duration
+	// HashKey picks the consistent hash key, ConsistentHashKey or the message when nil
+	HashKey  func(mail Mail[any]) any
+	Replicas int
+	// Resizer only applies to pools
+	Resizer *PoolResizer
+}
+
+// Router forwards the mail it receives to its routees, keeping the sender so
+// replies go straight back. A pool router creates and supervises its workers,
+// a group router routes to addresses of actors living elsewhere.
+type Router interface {
+	Actor[Mail[any], any]
+	Routees() []uuid.UUID
+	AddRoutee(a Address)
+	RemoveRoutee(id uuid.UUID) bool
+}
+
+// WorkerFactory creates a started worker of a pool.
+type WorkerFactory func() Actor[Mail[any], any]
+
+// routee is either a worker of the pool, mailed directly, or the address of
+// a group member.
+type routee struct {
+	address Address
+	worker  Actor[Mail[any], any]
+}
+
+func (r *routee) ID() uuid.UUID {
+	if r.worker != nil {
+		return r.worker.ID()
+	}
+	return r.address.ID()
+}
+
+func (r *routee) String() string {
+	id := r.ID()
+	return (&id).String()
+}
+
+func (r *routee) send(ctx context.Context, mail Mail[any]) *core.CoreError {
+	if r.worker == nil {
+		return r.address.Transfer(ctx, mail)
+	}
+	if err := r.worker.Mailbox().Send(ctx, mail); err != nil {
+		return core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, err.Error())
+	}
+	return nil
+}
+
+// pending is the mailbox length of a worker, ok is false when it is unknown.
+func (r *routee) pending() (int, bool) {
+	if r.worker == nil {
+		return 0, false
+	}
+	mb, ok := r.worker.Mailbox().(interface{ Len() int })
+	if !ok {
+		return 0, false
+	}
+	return mb.Len(), true
+}
+
+type resizeTick struct{}
+
+type routerImpl struct {
+	*actorImpl[Mail[any], any]
+	config  RouterConfig
+	factory WorkerFactory
+	next    uint64
+
+	routeMu sync.Mutex
+	routees []*routee
+	hash    library.ConsistentHash[*routee]
+}
+
+func newRouter(mailboxType MailboxType, id uuid.UUID, config RouterConfig, opts ...MailboxOption) *routerImpl {
+	r := &routerImpl{
+		actorImpl: NewActor[Mail[any], any](mailboxType, id, nil, opts...).(*actorImpl[Mail[any], any]),
+		config:    config,
+		hash: library.NewConsistentHash[*routee](config.Replicas, nil, func(r *routee) string {
+			return r.String()
+		}),
+	}
+	return r
+}
+
+// NewPoolRouter starts a router over size workers created by factory.
+func NewPoolRouter(mailboxType MailboxType, id uuid.UUID, size int, factory WorkerFactory, config RouterConfig, opts ...MailboxOption) Router {
+	r := newRouter(mailboxType, id, config, opts...)
+	r.factory = factory
+	for i := 0; i < size; i++ {
+		r.grow()
+	}
+	r.Start(r.route)
+	if resizer := config.Resizer; resizer != nil {
+		interval := resizer.Interval
+		if interval <= 0 {
+			interval = DefaultResizeInterval
+		}
+		r.StartPeriodicTimer("resize", resizeTick{}, interval)
+	}
+	return r
+}
+
+// NewGroupRouter starts a router over existing actors.
+func NewGroupRouter(mailboxType MailboxType, id uuid.UUID, routees []Address, config RouterConfig, opts ...MailboxOption) Router {
+	r := newRouter(mailboxType, id, config, opts...)
+	for _, a := range routees {
+		r.AddRoutee(a)
+	}
+	r.Start(r.route)
+	return r
+}
+
+func (r *routerImpl) Routees() []uuid.UUID {
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
+	ids := make([]uuid.UUID, 0, len(r.routees))
+	for _, routee := range r.routees {
+		ids = append(ids, routee.ID())
+	}
+	return ids
+}
+
+func (r *routerImpl) AddRoutee(a Address) {
+	r.addRoutee(&routee{address: a})
+}
+
+func (r *routerImpl) addRoutee(routee *routee) {
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
+	r.routees = append(r.routees, routee)
+	r.hash.Add(routee)
+}
+
+// RemoveRoutee stops the routee if it is a worker of the pool.
+func (r *routerImpl) RemoveRoutee(id uuid.UUID) bool {
+	r.routeMu.Lock()
+	var removed *routee
+	for i, routee := range r.routees {
+		if routee.ID() == id {
+			removed = routee
+			r.routees = append(r.routees[:i:i], r.routees[i+1:]...)
+			r.hash.Remove(routee)
+			break
+		}
+	}
+	r.routeMu.Unlock()
+	if removed == nil {
+		return false
+	}
+	if removed.worker != nil {
+		removed.worker.SetStopPolicy(StopPolicy_DRAIN)
+		removed.worker.Stop()
+	}
+	return true
+}
+
+// grow adds a worker, it joins the postman of the router.
+func (r *routerImpl) grow() {
+	worker := r.factory()
+	if err := r.Supervise(worker); err != nil {
+		log.ErrorF("error while supervise worker of router %s: %s\n", (&r.id).String(), err.String())
+	}
+	if postman, err := r.joinedPostman(); err == nil {
+		postman.Add(context.Background(), worker)
+	}
+	r.addRoutee(&routee{worker: worker})
+}
+
+// setPostman also adds the workers of the pool to the postman, so their
+// replies can be delivered.
+func (r *routerImpl) setPostman(postman Postman) {
+	r.actorImpl.setPostman(postman)
+	r.routeMu.Lock()
+	routees := append([]*routee(nil), r.routees...)
+	r.routeMu.Unlock()
+	for _, routee := range routees {
+		if routee.worker != nil {
+			postman.Add(context.Background(), routee.worker)
+		}
+	}
+}
+
+func (r *routerImpl) snapshot() []*routee {
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
+	return append([]*routee(nil), r.routees...)
+}
+
+func (r *routerImpl) route(mail Mail[any]) {
+	if _, ok := mail.Message().(resizeTick); ok {
+		r.resize()
+		return
+	}
+	ctx := context.Background()
+	routees := r.snapshot()
+	if len(routees) == 0 {
+		log.WarnF("router %s has no routees, mail dropped", (&r.id).String())
+		return
+	}
+	switch r.config.Strategy {
+	case RoutingStrategy_BROADCAST:
+		for _, routee := range routees {
+			r.forward(ctx, routee, mail)
+		}
+	case RoutingStrategy_SCATTER_GATHER:
+		r.scatterGather(mail, routees)
+	default:
+		r.forward(ctx, r.pick(mail, routees), mail)
+	}
+}
+
+func (r *routerImpl) pick(mail Mail[any], routees []*routee) *routee {
+	switch r.config.Strategy {
+	case RoutingStrategy_RANDOM:
+		return routees[rand.Intn(len(routees))]
+	case RoutingStrategy_SMALLEST_MAILBOX:
+		var picked *routee
+		smallest := 0
+		for _, routee := range routees {
+			if pending, ok := routee.pending(); ok && (picked == nil || pending < smallest) {
+				picked, smallest = routee, pending
+			}
+		}
+		if picked != nil {
+			return picked
+		}
+	case RoutingStrategy_CONSISTENT_HASH:
+		if picked, ok := r.hash.Get(r.hashKey(mail)); ok {
+			return picked
+		}
+	}
+	return routees[atomic.AddUint64(&r.next, 1)%uint64(len(routees))]
+}
+
+func (r *routerImpl) hashKey(mail Mail[any]) any {
+	if r.config.HashKey != nil {
+		return r.config.HashKey(mail)
+	}
+	if hashable, ok := mail.Message().(ConsistentHashable); ok {
+		return hashable.ConsistentHashKey()
+	}
+	return mail.Message()
+}
+
+func (r *routerImpl) forward(ctx context.Context, routee *routee, mail Mail[any]) {
+	forwarded := NewMail[any](mail.Sender(), routee.ID(), mail.Message(), mail.CodeC(), WithCorrelationID(mail.CorrelationID()))
+	if err := routee.send(ctx, forwarded); err != nil {
+		log.ErrorF("error while route mail to %s: %s\n", routee.String(), err.String())
+	}
+}
+
+// scatterGather asks every routee in the background and replies to the
+// sender with the first answer within the deadline.
+func (r *routerImpl) scatterGather(mail Mail[any], routees []*routee) {
+	postman, err := r.joinedPostman()
+	if err != nil {
+		log.ErrorF("error while scatter mail of router %s: %s\n", (&r.id).String(), err.String())
+		return
+	}
+	within := r.config.Within
+	if within <= 0 {
+		within = DefaultScatterGatherWithin
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), within)
+		defer cancel()
+		replies := make(chan Mail[any], len(routees))
+		for _, routee := range routees {
+			go func(id uuid.UUID) {
+				reply, err := postman.Ask(ctx, id, mail.Message())
+				if err == nil {
+					replies <- reply
+				}
+			}(routee.ID())
+		}
+		select {
+		case reply := <-replies:
+			if err := postman.Deliver(context.Background(), NewMail[any](r.id, mail.Sender(), reply.Message(), mail.CodeC(), WithCorrelationID(mail.CorrelationID()))); err != nil {
+				log.ErrorF("error while reply scatter gather of router %s: %s\n", (&r.id).String(), err.String())
+			}
+		case <-ctx.Done():
+			sender := mail.Sender()
+			log.WarnF("scatter gather of router %s for %s got no reply within %s", (&r.id).String(), (&sender).String(), within)
+		}
+	}()
+}
+
+// resize runs on the router goroutine on every tick of the resizer.
+func (r *routerImpl) resize() {
+	resizer := r.config.Resizer
+	routees := r.snapshot()
+	threshold := resizer.PressureThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	var pressured, busy int
+	var workers []*routee
+	for _, routee := range routees {
+		pending, ok := routee.pending()
+		if !ok {
+			continue
+		}
+		workers = append(workers, routee)
+		if pending > 0 {
+			busy++
+		}
+		if pending >= threshold {
+			pressured++
+		}
+	}
+	size := len(workers)
+	switch {
+	case size < resizer.Lower || (size > 0 && pressured == size && size < resizer.Upper):
+		r.grow()
+	case size > resizer.Lower && float64(busy) < resizer.BackoffThreshold*float64(size):
+		r.RemoveRoutee(r.idlest(workers).ID())
+	}
+}
+
+func (r *routerImpl) idlest(workers []*routee) *routee {
+	picked, smallest := workers[len(workers)-1], -1
+	for i := len(workers) - 1; i >= 0; i-- {
+		if pending, _ := workers[i].pending(); smallest < 0 || pending < smallest {
+			picked, smallest = workers[i], pending
+		}
+	}
+	return picked
+}
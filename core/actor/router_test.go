@@ -0,0 +1,215 @@
+package actor_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/testkit"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeRouter uuid.UUIDType = "router"
+
+// newWorkerFactory creates workers that forward every mail they process to
+// probe, the mail the probe receives is sent by the worker.
+func newWorkerFactory(idGen uuid.SimpleUUIDGenerator, probe *testkit.TestProbe, process func(a actor.Actor[actor.Mail[any], any], mail actor.Mail[any])) actor.WorkerFactory {
+	var mu sync.Mutex
+	return func() actor.Actor[actor.Mail[any], any] {
+		mu.Lock()
+		id := idGen.Next()
+		mu.Unlock()
+		a := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, id, nil)
+		a.Start(func(mail actor.Mail[any]) {
+			if process != nil {
+				process(a, mail)
+			}
+			forward(a, probe, mail.Message())
+		})
+		return a
+	}
+}
+
+// collect takes the next n mails of probe.
+func collect(probe *testkit.TestProbe, n int) []actor.Mail[any] {
+	all := make([]actor.Mail[any], 0, n)
+	for len(all) < n {
+		all = append(all, probe.ReceiveMsg(testkit.DefaultExpectTimeout))
+	}
+	return all
+}
+
+func TestRouter_RoundRobin(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeRouter)
+	probe := testkit.NewTestProbe(t, idGen.Next())
+	router := actor.NewPoolRouter(actor.MailboxType_MEMORY, idGen.Next(), 3, newWorkerFactory(idGen, probe, nil), actor.RouterConfig{Strategy: actor.RoutingStrategy_ROUND_ROBIN})
+	defer router.Stop()
+	assert.Len(t, router.Routees(), 3)
+
+	for i := 0; i < 6; i++ {
+		tell(t, router, i)
+	}
+	counts := make(map[uuid.UUID]int)
+	for _, mail := range collect(probe, 6) {
+		counts[mail.Sender()]++
+	}
+	assert.Len(t, counts, 3)
+	for _, count := range counts {
+		assert.Equal(t, 2, count)
+	}
+}
+
+type jobKey string
+
+func (k jobKey) ConsistentHashKey() any {
+	return string(k)[:4]
+}
+
+func TestRouter_ConsistentHash(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeRouter)
+	probe := testkit.NewTestProbe(t, idGen.Next())
+	router := actor.NewPoolRouter(actor.MailboxType_MEMORY, idGen.Next(), 4, newWorkerFactory(idGen, probe, nil), actor.RouterConfig{Strategy: actor.RoutingStrategy_CONSISTENT_HASH})
+	defer router.Stop()
+
+	for _, key := range []jobKey{"siteA/1", "siteA/2", "siteA/3"} {
+		tell(t, router, key)
+	}
+	all := collect(probe, 3)
+	assert.Equal(t, all[0].Sender(), all[1].Sender(), "同一个 key 应该落在同一个 worker")
+	assert.Equal(t, all[0].Sender(), all[2].Sender())
+}
+
+func TestRouter_Broadcast(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeRouter)
+	probe := testkit.NewTestProbe(t, idGen.Next())
+	router := actor.NewPoolRouter(actor.MailboxType_MEMORY, idGen.Next(), 3, newWorkerFactory(idGen, probe, nil), actor.RouterConfig{Strategy: actor.RoutingStrategy_BROADCAST})
+	defer router.Stop()
+
+	tell(t, router, "all")
+	workers := make(map[uuid.UUID]bool)
+	for _, mail := range collect(probe, 3) {
+		workers[mail.Sender()] = true
+		assert.Equal(t, "all", mail.Message())
+	}
+	assert.Len(t, workers, 3)
+}
+
+func TestRouter_SmallestMailbox(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeRouter)
+	probe := testkit.NewTestProbe(t, idGen.Next())
+	release := make(chan struct{})
+	blocked := make(chan struct{}, 1)
+	factory := newWorkerFactory(idGen, probe, func(a actor.Actor[actor.Mail[any], any], mail actor.Mail[any]) {
+		if mail.Message() == "slow" {
+			blocked <- struct{}{}
+			<-release
+		}
+	})
+	router := actor.NewGroupRouter(actor.MailboxType_MEMORY, idGen.Next(), nil, actor.RouterConfig{Strategy: actor.RoutingStrategy_SMALLEST_MAILBOX})
+	defer router.Stop()
+	busy, idle := factory(), factory()
+	defer busy.Stop()
+	defer idle.Stop()
+	actor.AddWorker(router, busy)
+	actor.AddWorker(router, idle)
+
+	tell(t, busy, "slow")
+	<-blocked
+	tell(t, busy, "queued")
+	tell(t, router, "job")
+	probe.ExpectMsg("job")
+	assert.Equal(t, idle.ID(), probe.LastSender())
+	close(release)
+}
+
+func TestRouter_ScatterGather(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeRouter)
+	ctx := context.Background()
+	pm := actor.NewPostman()
+	probe := testkit.NewTestProbe(t, idGen.Next())
+	factory := newWorkerFactory(idGen, probe, func(a actor.Actor[actor.Mail[any], any], mail actor.Mail[any]) {
+		a.Mailbox().Send(context.Background(), actor.NewReply[any](mail, "rendered"))
+	})
+	router := actor.NewPoolRouter(actor.MailboxType_MEMORY, idGen.Next(), 3, factory, actor.RouterConfig{Strategy: actor.RoutingStrategy_SCATTER_GATHER, Within: time.Second})
+	defer router.Stop()
+	assert.Nil(t, pm.Add(ctx, router))
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	reply, err := pm.Ask(ctx, router.ID(), "chart")
+	assert.Nil(t, err)
+	assert.Equal(t, "rendered", reply.Message())
+	assert.Equal(t, router.ID(), reply.Sender())
+	collect(probe, 3)
+}
+
+func TestRouter_Resize(t *testing.T) {
+	testkit.VerifyNoLeaks(t)
+	clock := testkit.UseVirtualTime(t, 10*time.Millisecond)
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeRouter)
+	probe := testkit.NewTestProbe(t, idGen.Next())
+	release := make(chan struct{})
+	factory := newWorkerFactory(idGen, probe, func(a actor.Actor[actor.Mail[any], any], mail actor.Mail[any]) {
+		<-release
+	})
+	resizer := &actor.PoolResizer{Lower: 1, Upper: 3, PressureThreshold: 1, BackoffThreshold: 0.5, Interval: 20 * time.Millisecond}
+	router := actor.NewPoolRouter(actor.MailboxType_MEMORY, idGen.Next(), 1, factory, actor.RouterConfig{Strategy: actor.RoutingStrategy_ROUND_ROBIN, Resizer: resizer})
+	defer router.Stop()
+	// resized runs one resize after another until the pool has size workers
+	resized := func(size int) bool {
+		return assert.Eventually(t, func() bool {
+			clock.Advance(resizer.Interval)
+			return len(router.Routees()) == size
+		}, time.Second, time.Millisecond)
+	}
+
+	// every worker blocks, so the queued jobs keep the pool under pressure
+	for _, size := range []int{2, 3} {
+		for i := 0; i < 10; i++ {
+			tell(t, router, i)
+		}
+		resized(size)
+	}
+	for i := 0; i < 10; i++ {
+		tell(t, router, i)
+	}
+	// the router takes the ticks in order, once the mailbox of the router is
+	// empty after a tick the resize of the tick before is done
+	for i := 0; i < 5; i++ {
+		clock.Advance(resizer.Interval)
+		assert.Eventually(t, func() bool {
+			return actor.MailboxLen(router) == 0
+		}, time.Second, time.Millisecond)
+	}
+	assert.Len(t, router.Routees(), 3, "the pool should not grow above Upper")
+
+	close(release)
+	resized(1)
+}
+
+func TestRouter_Group(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeRouter)
+	ctx := context.Background()
+	pm := actor.NewPostman()
+	first := testkit.NewTestProbe(t, idGen.Next())
+	second := testkit.NewTestProbe(t, idGen.Next())
+	pm.Add(ctx, first)
+	pm.Add(ctx, second)
+	via := actor.NewLocalPostManAddress(pm)
+	router := actor.NewGroupRouter(actor.MailboxType_MEMORY, idGen.Next(), []actor.Address{actor.NewActorAddress(first.ID(), via), actor.NewActorAddress(second.ID(), via)}, actor.RouterConfig{Strategy: actor.RoutingStrategy_BROADCAST})
+	defer router.Stop()
+
+	tell(t, router, "hello")
+	first.ExpectMsg("hello")
+	second.ExpectMsg("hello")
+
+	assert.True(t, router.RemoveRoutee(second.ID()))
+	assert.False(t, router.RemoveRoutee(second.ID()))
+	assert.Nil(t, router.Mailbox().Send(ctx, actor.NewMail[any](uuid.UUID{}, router.ID(), "again", codec.JSON_CODEC)))
+	first.ExpectMsg("again")
+	second.ExpectNoMsg(50 * time.Millisecond)
+}
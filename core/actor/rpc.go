@@ -3,7 +3,9 @@ package actor
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
+	"sync"
 
 	"github.com/shooyaaa/core"
 )
@@ -43,61 +45,36 @@ type RpcChannel interface {
 	Receive(ctx context.Context) ([]byte, *core.CoreError)
 }
 
-type TcpChannelClient interface {
-	Send(ctx context.Context, data []byte) *core.CoreError
-	Receive(ctx context.Context) ([]byte, *core.CoreError)
-}
-type TcpChannel struct {
-	addr   string
-	client TcpChannelClient
-}
-
-func (c *TcpChannel) Send(ctx context.Context, data []byte) *core.CoreError {
-	return nil
-}
-
-func (c *TcpChannel) Receive(ctx context.Context) ([]byte, *core.CoreError) {
-	return nil, nil
-}
-
-func NewTcpChannel(addr string) RpcChannel {
-	return &TcpChannel{addr: addr}
-}
-
-type HttpChannelClient interface {
-	Send(ctx context.Context, data []byte) error
-	Receive(ctx context.Context) ([]byte, error)
-}
-type HttpChannel struct {
-	addr   string
-	client HttpChannelClient
-}
-
-func (c *HttpChannel) Send(ctx context.Context, data []byte) *core.CoreError {
-	return nil
-}
-
-func (c *HttpChannel) Receive(ctx context.Context) ([]byte, *core.CoreError) {
-	if c.client != nil {
-		data, err := c.client.Receive(ctx)
-		if err != nil {
-			return nil, core.NewCoreError(core.ERROR_CODE_MAILBOX_RECEIVE_ERROR, err.Error())
-		}
-		return data, nil
-	}
-	return nil, nil
+// BatchRpcChannel is implemented by channels able to ship several encoded
+// mails in one round trip.
+type BatchRpcChannel interface {
+	SendBatch(ctx context.Context, batch [][]byte) *core.CoreError
 }
 
-func NewHttpChannel(addr string) RpcChannel {
-	return &HttpChannel{addr: addr}
-}
+// channelPool keeps one persistent channel per remote address.
+var channelPool sync.Map
 
 func GetChannelByAddress(addr string) RpcChannel {
 	if strings.HasPrefix(addr, "http://") {
 		return NewHttpChannel(addr)
-	} else if strings.HasPrefix(addr, "tcp://") {
-		return NewTcpChannel(addr)
+	} else if strings.HasPrefix(addr, "tcp://") || strings.HasPrefix(addr, "unix://") || strings.HasPrefix(addr, "ipc://") {
+		channel, _ := channelPool.LoadOrStore(addr, NewTcpChannel(addr))
+		return channel.(RpcChannel)
+	} else if strings.HasPrefix(addr, "pipe://") {
+		channel, _ := channelPool.LoadOrStore(addr, NewPipeChannel(addr))
+		return channel.(RpcChannel)
 	} else {
 		panic(fmt.Sprintf("unimplemented address channel type: %s", addr))
 	}
 }
+
+// CloseChannel drops the pooled channel of addr and closes its connection.
+func CloseChannel(addr string) {
+	channel, ok := channelPool.LoadAndDelete(addr)
+	if !ok {
+		return
+	}
+	if closer, ok := channel.(io.Closer); ok {
+		closer.Close()
+	}
+}
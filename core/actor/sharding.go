@@ -0,0 +1,331 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+const DefaultShards = 100
+const DefaultShardCheckInterval = time.Second
+
+// ShardOf maps an entity to one of shards shards.
+func ShardOf(id uuid.UUID, shards int) int {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	shard := int(id.ID % int64(shards))
+	if shard < 0 {
+		shard += shards
+	}
+	return shard
+}
+
+// ShardKey is the key a shard is placed on the ring by.
+func ShardKey(id uuid.UUID, shards int) string {
+	return shardKey(id.Type, ShardOf(id, shards))
+}
+
+func shardKey(entityType uuid.UUIDType, shard int) string {
+	return fmt.Sprintf("shard:%s:%d", entityType, shard)
+}
+
+type ShardingConfig struct {
+	EntityType uuid.UUIDType
+	// Shards has to match WithPostofficeSharding, DefaultShards when zero
+	Shards int
+	// NewEntity creates the started actor of an entity on its first mail
+	NewEntity func(id uuid.UUID) Actor[Mail[any], any]
+	// PassivateAfter stops entities idle for that long, zero keeps them
+	PassivateAfter time.Duration
+	// CheckInterval is how often ownership and idleness are checked
+	CheckInterval time.Duration
+}
+
+// ShardRegion hosts the entities of one type whose shards the ring places on
+// its postman. Entities are spawned by their first mail, mail for shards
+// owned elsewhere is dispatched through the postoffice. When the ring moves a
+// shard away its entities are stopped and the mail still queued for them is
+// handed to the new owner.
+type ShardRegion interface {
+	MailReceiver
+	Entities() []uuid.UUID
+	Shards() []int
+	// Rebalance hands off the shards the postman no longer owns, it also runs
+	// every CheckInterval.
+	Rebalance(ctx context.Context)
+	Stop()
+}
+
+type shardEntity struct {
+	actor    Actor[Mail[any], any]
+	shard    int
+	lastSeen time.Time
+}
+
+type shardRegionImpl struct {
+	postman Postman
+	ring    library.ConsistentHash[Address]
+	config  ShardingConfig
+	unhost  func()
+
+	mu       sync.Mutex
+	entities map[uuid.UUID]*shardEntity
+	// handoff buffers the mail of shards being handed off
+	handoff map[int][]Mail[any]
+
+	rebalanceMu sync.Mutex
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+func NewShardRegion(postman Postman, ring library.ConsistentHash[Address], config ShardingConfig) ShardRegion {
+	if config.Shards <= 0 {
+		config.Shards = DefaultShards
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = DefaultShardCheckInterval
+	}
+	r := &shardRegionImpl{
+		postman:  postman,
+		ring:     ring,
+		config:   config,
+		entities: make(map[uuid.UUID]*shardEntity),
+		handoff:  make(map[int][]Mail[any]),
+		stop:     make(chan struct{}),
+	}
+	r.unhost = postman.Host(config.EntityType, r)
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+func (r *shardRegionImpl) owns(shard int) bool {
+	a, ok := r.ring.Get(shardKey(r.config.EntityType, shard))
+	return ok && a.ID() == r.postman.ID()
+}
+
+func (r *shardRegionImpl) Receive(ctx context.Context, mail Mail[any]) *core.CoreError {
+	for {
+		e, err := r.route(ctx, mail)
+		if e == nil || err != nil {
+			return err
+		}
+		sendErr := e.actor.Mailbox().Send(ctx, mail)
+		if sendErr == nil {
+			return nil
+		}
+		if !errors.Is(sendErr, ErrMailboxClosed) {
+			return core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, sendErr.Error())
+		}
+		// the entity was passivated, handed off or stopped meanwhile, routing
+		// again spawns it anew or buffers the mail
+		r.mu.Lock()
+		if r.entities[mail.Receiver()] == e {
+			delete(r.entities, mail.Receiver())
+		}
+		r.mu.Unlock()
+	}
+}
+
+// route returns the entity to send the mail to, it is nil when the mail was
+// buffered for a hand off or dispatched to the owner of the shard.
+func (r *shardRegionImpl) route(ctx context.Context, mail Mail[any]) (*shardEntity, *core.CoreError) {
+	id := mail.Receiver()
+	shard := ShardOf(id, r.config.Shards)
+	r.mu.Lock()
+	if buffer, ok := r.handoff[shard]; ok {
+		r.handoff[shard] = append(buffer, mail)
+		r.mu.Unlock()
+		return nil, nil
+	}
+	if !r.owns(shard) {
+		r.mu.Unlock()
+		return nil, r.postman.Dispatch(ctx, mail)
+	}
+	e, ok := r.entities[id]
+	if !ok {
+		e = r.spawn(id, shard)
+	}
+	e.lastSeen = time.Now()
+	r.mu.Unlock()
+	if !ok {
+		// outside mu, adding may redeliver dead letters through the region
+		r.postman.Add(ctx, e.actor)
+	}
+	return e, nil
+}
+
+// spawn creates the entity, the caller holds mu.
+func (r *shardRegionImpl) spawn(id uuid.UUID, shard int) *shardEntity {
+	a := r.config.NewEntity(id)
+	e := &shardEntity{actor: a, shard: shard}
+	r.entities[id] = e
+	go func() {
+		<-a.Terminated()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.entities[id] == e {
+			delete(r.entities, id)
+		}
+	}()
+	return e
+}
+
+func (r *shardRegionImpl) Entities() []uuid.UUID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]uuid.UUID, 0, len(r.entities))
+	for id := range r.entities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].ID < ids[j].ID
+	})
+	return ids
+}
+
+// Shards lists the shards with live entities.
+func (r *shardRegionImpl) Shards() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := make(map[int]bool)
+	var shards []int
+	for _, e := range r.entities {
+		if !seen[e.shard] {
+			seen[e.shard] = true
+			shards = append(shards, e.shard)
+		}
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+func (r *shardRegionImpl) Rebalance(ctx context.Context) {
+	r.rebalanceMu.Lock()
+	defer r.rebalanceMu.Unlock()
+	r.mu.Lock()
+	moving := make(map[int][]*shardEntity)
+	for id, e := range r.entities {
+		if r.owns(e.shard) {
+			continue
+		}
+		moving[e.shard] = append(moving[e.shard], e)
+		delete(r.entities, id)
+		if _, ok := r.handoff[e.shard]; !ok {
+			r.handoff[e.shard] = []Mail[any]{}
+		}
+	}
+	r.mu.Unlock()
+	for shard, entities := range moving {
+		for _, e := range entities {
+			r.handOff(ctx, e)
+		}
+		r.mu.Lock()
+		buffered := r.handoff[shard]
+		delete(r.handoff, shard)
+		r.mu.Unlock()
+		for _, mail := range buffered {
+			r.forward(ctx, mail)
+		}
+		log.InfoF("shard %d of %s handed off with %d buffered mails", shard, r.config.EntityType, len(buffered))
+	}
+}
+
+// handOff stops the entity and forwards the mail it had not processed yet. A
+// closed mailbox still returns its queued mail, durable mailboxes acknowledge
+// the forwarded mail so it is not received again.
+func (r *shardRegionImpl) handOff(ctx context.Context, e *shardEntity) {
+	r.postman.Remove(ctx, e.actor.ID())
+	e.actor.Stop()
+	<-e.actor.Terminated()
+	mailbox := e.actor.Mailbox()
+	for {
+		mail, err := mailbox.Receive(ctx)
+		if err != nil {
+			break
+		}
+		r.forward(ctx, mail)
+	}
+	if acker, ok := mailbox.(MailboxAcker); ok {
+		if err := acker.Ack(ctx); err != nil {
+			id := e.actor.ID()
+			log.ErrorF("error while ack handed off mail of %s: %v\n", (&id).String(), err)
+		}
+	}
+}
+
+func (r *shardRegionImpl) forward(ctx context.Context, mail Mail[any]) {
+	if err := r.postman.Dispatch(ctx, mail); err != nil {
+		receiver := mail.Receiver()
+		log.ErrorF("error while hand off mail of %s: %s\n", (&receiver).String(), err.String())
+	}
+}
+
+// passivate stops the entities that have been idle for PassivateAfter. Mail
+// racing the stop is either drained or finds the mailbox closed and is routed
+// again by Receive.
+func (r *shardRegionImpl) passivate(now time.Time) {
+	if r.config.PassivateAfter <= 0 {
+		return
+	}
+	var idle []*shardEntity
+	r.mu.Lock()
+	for id, e := range r.entities {
+		if now.Sub(e.lastSeen) < r.config.PassivateAfter {
+			continue
+		}
+		if mb, ok := e.actor.Mailbox().(interface{ Len() int }); ok && mb.Len() > 0 {
+			continue
+		}
+		delete(r.entities, id)
+		idle = append(idle, e)
+	}
+	r.mu.Unlock()
+	for _, e := range idle {
+		e.actor.SetStopPolicy(StopPolicy_DRAIN)
+		e.actor.Stop()
+	}
+}
+
+func (r *shardRegionImpl) run() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.Rebalance(context.Background())
+			r.passivate(now)
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the region and all of its entities.
+func (r *shardRegionImpl) Stop() {
+	r.unhost()
+	select {
+	case <-r.stop:
+		return
+	default:
+		close(r.stop)
+	}
+	r.wg.Wait()
+	r.mu.Lock()
+	entities := r.entities
+	r.entities = make(map[uuid.UUID]*shardEntity)
+	r.mu.Unlock()
+	for _, e := range entities {
+		e.actor.Stop()
+	}
+}
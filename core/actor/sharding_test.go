@@ -0,0 +1,235 @@
+package actor
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeSharding uuid.UUIDType = "sharding"
+const UUIDTypeRoom uuid.UUIDType = "room"
+
+type processedMail struct {
+	entity  uuid.UUID
+	run     int
+	message any
+}
+
+// entityFactory counts the runs of every entity, so a respawn is visible.
+type entityFactory struct {
+	mu        sync.Mutex
+	runs      map[uuid.UUID]int
+	processed chan processedMail
+	blocked   chan struct{}
+	release   chan struct{}
+	// dir gives every run a file mailbox of its own when set
+	dir string
+}
+
+func newEntityFactory() *entityFactory {
+	return &entityFactory{runs: make(map[uuid.UUID]int), processed: make(chan processedMail, 100), blocked: make(chan struct{}, 10)}
+}
+
+func (f *entityFactory) newEntity(id uuid.UUID) Actor[Mail[any], any] {
+	f.mu.Lock()
+	f.runs[id]++
+	run := f.runs[id]
+	f.mu.Unlock()
+	var a Actor[Mail[any], any]
+	if f.dir == "" {
+		a = NewActor[Mail[any], any](MailboxType_MEMORY, id, nil)
+	} else {
+		config := FileMailboxConfig{Dir: filepath.Join(f.dir, strconv.Itoa(run)), SegmentSize: 1 << 20, Fsync: FsyncPolicy_NEVER}
+		mb, err := NewFileMailbox(id, config)
+		if err != nil {
+			panic(err.String())
+		}
+		a = NewActorWithMailbox[Mail[any], any](mb, id, nil)
+	}
+	a.Start(func(mail Mail[any]) {
+		if mail.Message() == "block" && f.release != nil {
+			f.blocked <- struct{}{}
+			<-f.release
+		}
+		f.processed <- processedMail{entity: id, run: run, message: mail.Message()}
+	})
+	return a
+}
+
+func (f *entityFactory) expect(t *testing.T) processedMail {
+	select {
+	case p := <-f.processed:
+		return p
+	case <-time.After(2 * time.Second):
+		t.Fatal("entity should process mail")
+	}
+	return processedMail{}
+}
+
+type shardedNode struct {
+	postman Postman
+	region  ShardRegion
+	address Address
+}
+
+func newShardedRing(t *testing.T, factory *entityFactory, config ShardingConfig, size int) (library.ConsistentHash[Address], Postoffice, []*shardedNode) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeSharding)
+	ctx := context.Background()
+	ring := library.NewConsistentHash[Address](150, nil, nil)
+	po := NewPostoffice(ring, idGen.Next(), WithPostofficeSharding(UUIDTypeRoom, config.Shards))
+	config.EntityType = UUIDTypeRoom
+	config.NewEntity = factory.newEntity
+	var nodes []*shardedNode
+	for i := 0; i < size; i++ {
+		pm := NewPostman(WithPostmanID(idGen.Next()))
+		node := &shardedNode{postman: pm, address: NewLocalPostManAddress(pm), region: NewShardRegion(pm, ring, config)}
+		po.Add(ctx, node.address)
+		pm.Register(ctx, NewLocalPostOfficeAddress(po))
+		nodes = append(nodes, node)
+		t.Cleanup(node.region.Stop)
+	}
+	return ring, po, nodes
+}
+
+func ownerOf(ring library.ConsistentHash[Address], nodes []*shardedNode, id uuid.UUID, shards int) *shardedNode {
+	a, _ := ring.Get(ShardKey(id, shards))
+	for _, node := range nodes {
+		if node.postman.ID() == a.ID() {
+			return node
+		}
+	}
+	return nil
+}
+
+func TestShardRegion_SpawnOnFirstMail(t *testing.T) {
+	factory := newEntityFactory()
+	config := ShardingConfig{Shards: 10}
+	ring, _, nodes := newShardedRing(t, factory, config, 3)
+	ctx := context.Background()
+
+	room := uuid.UUID{Type: UUIDTypeRoom, ID: 42}
+	owner := ownerOf(ring, nodes, room, 10)
+	for _, sender := range nodes {
+		assert.Nil(t, sender.postman.Deliver(ctx, NewMail[any](uuid.UUID{}, room, "join", codec.JSON_CODEC)))
+		p := factory.expect(t)
+		assert.Equal(t, room, p.entity)
+		assert.Equal(t, 1, p.run, "the entity is spawned once, on its owner")
+	}
+	assert.Equal(t, []uuid.UUID{room}, owner.region.Entities())
+	assert.Equal(t, []int{ShardOf(room, 10)}, owner.region.Shards())
+	for _, node := range nodes {
+		if node != owner {
+			assert.Empty(t, node.region.Entities())
+		}
+	}
+}
+
+func TestShardRegion_Handoff(t *testing.T) {
+	testShardRegionHandoff(t, newEntityFactory())
+}
+
+func TestShardRegion_HandoffFileMailbox(t *testing.T) {
+	factory := newEntityFactory()
+	factory.dir = t.TempDir()
+	// 文件邮箱关闭后排队的邮件也要转交给新的 owner
+	testShardRegionHandoff(t, factory)
+}
+
+func testShardRegionHandoff(t *testing.T, factory *entityFactory) {
+	factory.release = make(chan struct{})
+	config := ShardingConfig{Shards: 10, CheckInterval: time.Hour}
+	ring, po, nodes := newShardedRing(t, factory, config, 2)
+	ctx := context.Background()
+
+	room := uuid.UUID{Type: UUIDTypeRoom, ID: 7}
+	owner := ownerOf(ring, nodes, room, 10)
+	var other *shardedNode
+	for _, node := range nodes {
+		if node != owner {
+			other = node
+		}
+	}
+	// the entity blocks on the first mail, the rest stays queued
+	messages := []any{"block", "m1", "m2", "m3"}
+	for _, message := range messages {
+		assert.Nil(t, other.postman.Deliver(ctx, NewMail[any](uuid.UUID{}, room, message, codec.JSON_CODEC)))
+	}
+	select {
+	case <-factory.blocked:
+	case <-time.After(time.Second):
+		t.Fatal("the entity should block on the first mail")
+	}
+
+	po.Remove(ctx, owner.address)
+	done := make(chan struct{})
+	go func() {
+		owner.region.Rebalance(ctx)
+		close(done)
+	}()
+	// mail arriving during the hand off is buffered and follows the queued mail
+	assert.Eventually(t, func() bool {
+		return len(owner.region.Entities()) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Nil(t, owner.region.Receive(ctx, NewMail[any](uuid.UUID{}, room, "m4", codec.JSON_CODEC)))
+	close(factory.release)
+	<-done
+
+	first := factory.expect(t)
+	assert.Equal(t, processedMail{entity: room, run: 1, message: "block"}, first)
+	for _, message := range []any{"m1", "m2", "m3", "m4"} {
+		p := factory.expect(t)
+		assert.Equal(t, 2, p.run, "handed off mail is processed by the new entity")
+		assert.Equal(t, message, p.message)
+	}
+	assert.Equal(t, []uuid.UUID{room}, other.region.Entities())
+}
+
+func TestShardRegion_Passivation(t *testing.T) {
+	factory := newEntityFactory()
+	config := ShardingConfig{Shards: 10, PassivateAfter: 50 * time.Millisecond, CheckInterval: 10 * time.Millisecond}
+	_, _, nodes := newShardedRing(t, factory, config, 1)
+	ctx := context.Background()
+	node := nodes[0]
+
+	room := uuid.UUID{Type: UUIDTypeRoom, ID: 1}
+	assert.Nil(t, node.postman.Deliver(ctx, NewMail[any](uuid.UUID{}, room, "hello", codec.JSON_CODEC)))
+	assert.Equal(t, 1, factory.expect(t).run)
+	assert.Eventually(t, func() bool {
+		return len(node.region.Entities()) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Nil(t, node.postman.Deliver(ctx, NewMail[any](uuid.UUID{}, room, "again", codec.JSON_CODEC)))
+	p := factory.expect(t)
+	assert.Equal(t, 2, p.run, "a passivated entity is spawned again")
+	assert.Equal(t, "again", p.message)
+}
+
+func TestShardRegion_StoppedEntity(t *testing.T) {
+	factory := newEntityFactory()
+	config := ShardingConfig{Shards: 10, CheckInterval: time.Hour}
+	_, _, nodes := newShardedRing(t, factory, config, 1)
+	ctx := context.Background()
+	node := nodes[0]
+
+	room := uuid.UUID{Type: UUIDTypeRoom, ID: 3}
+	assert.Nil(t, node.postman.Deliver(ctx, NewMail[any](uuid.UUID{}, room, "hello", codec.JSON_CODEC)))
+	assert.Equal(t, 1, factory.expect(t).run)
+	region := node.region.(*shardRegionImpl)
+	region.mu.Lock()
+	e := region.entities[room]
+	region.mu.Unlock()
+	// 邮箱已关闭但实体还未移除时，邮件应交给重新创建的实体而不是丢失
+	e.actor.Stop()
+	assert.Nil(t, region.Receive(ctx, NewMail[any](uuid.UUID{}, room, "again", codec.JSON_CODEC)))
+	p := factory.expect(t)
+	assert.Equal(t, 2, p.run)
+	assert.Equal(t, "again", p.message)
+}
@@ -0,0 +1,242 @@
+package actor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+type SupervisorStrategyType string
+
+// ONE_FOR_ONE restarts only the failed child, ONE_FOR_ALL restarts every child
+// and REST_FOR_ONE restarts the failed child and the children supervised after it.
+const SupervisorStrategyType_ONE_FOR_ONE SupervisorStrategyType = "one_for_one"
+const SupervisorStrategyType_ONE_FOR_ALL SupervisorStrategyType = "one_for_all"
+const SupervisorStrategyType_REST_FOR_ONE SupervisorStrategyType = "rest_for_one"
+
+type SupervisorDirective int
+
+const (
+	SupervisorDirective_RESUME SupervisorDirective = iota + 1
+	SupervisorDirective_RESTART
+	SupervisorDirective_STOP
+	SupervisorDirective_ESCALATE
+)
+
+type SupervisorDecider func(cause *core.CoreError) SupervisorDirective
+
+type SupervisorStrategy struct {
+	Type SupervisorStrategyType
+	// MaxRestarts is the number of restarts allowed within Within, negative means unlimited
+	MaxRestarts int
+	Within      time.Duration
+	Decider     SupervisorDecider
+}
+
+func NewSupervisorStrategy(strategyType SupervisorStrategyType, maxRestarts int, within time.Duration, decider SupervisorDecider) *SupervisorStrategy {
+	return &SupervisorStrategy{Type: strategyType, MaxRestarts: maxRestarts, Within: within, Decider: decider}
+}
+
+var DefaultSupervisorStrategy = NewSupervisorStrategy(SupervisorStrategyType_ONE_FOR_ONE, 10, time.Minute, nil)
+
+func (s *SupervisorStrategy) decide(cause *core.CoreError) SupervisorDirective {
+	if s.Decider == nil {
+		return SupervisorDirective_RESTART
+	}
+	return s.Decider(cause)
+}
+
+type SupervisedActor interface {
+	ID() uuid.UUID
+	Stop()
+	Restart(cause *core.CoreError)
+	Resume()
+	setParent(parent Supervisor)
+}
+
+type Supervisor interface {
+	SupervisedActor
+	HandleFailure(child SupervisedActor, cause *core.CoreError)
+	removeChild(id uuid.UUID)
+}
+
+func (a *actorImpl[T, D]) Parent() Supervisor {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.parent
+}
+
+func (a *actorImpl[T, D]) Children() []SupervisedActor {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]SupervisedActor(nil), a.children...)
+}
+
+func (a *actorImpl[T, D]) SetSupervisorStrategy(strategy *SupervisorStrategy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.strategy = strategy
+}
+
+func (a *actorImpl[T, D]) Supervise(child SupervisedActor) *core.CoreError {
+	id := child.ID()
+	a.mu.Lock()
+	if id == a.id {
+		a.mu.Unlock()
+		return core.NewCoreError(core.ERROR_CODE_ACTOR_ALREADY_EXISTS, fmt.Sprintf("actor can not supervise itself: %s", (&id).String()))
+	}
+	for _, c := range a.children {
+		if c.ID() == id {
+			a.mu.Unlock()
+			return core.NewCoreError(core.ERROR_CODE_ACTOR_ALREADY_EXISTS, fmt.Sprintf("child already supervised: %s", (&id).String()))
+		}
+	}
+	a.children = append(a.children, child)
+	a.mu.Unlock()
+	child.setParent(a)
+	return nil
+}
+
+func (a *actorImpl[T, D]) setParent(parent Supervisor) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.parent = parent
+}
+
+func (a *actorImpl[T, D]) removeChild(id uuid.UUID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, c := range a.children {
+		if c.ID() == id {
+			a.children = append(a.children[:i], a.children[i+1:]...)
+			break
+		}
+	}
+	delete(a.restarts, id)
+}
+
+// Restart relaunches the receive loop of the actor and of its whole subtree.
+// Pending mail stays in the mailbox.
+func (a *actorImpl[T, D]) Restart(cause *core.CoreError) {
+	a.halt()
+	a.resetBehavior()
+	if metrics, postman := a.metrics(); metrics != nil {
+		metrics.restarted(postman, a.id)
+	}
+	if a.restartHook != nil {
+		a.restartHook(cause)
+	}
+	if hook, ok := any(a.data).(PreRestarter); ok {
+		a.hook("PreRestart", func() { hook.PreRestart(cause) })
+	}
+	for _, child := range a.Children() {
+		child.Restart(cause)
+	}
+	if hook, ok := any(a.data).(PostRestarter); ok {
+		a.hook("PostRestart", func() { hook.PostRestart(cause) })
+	}
+	a.launch()
+}
+
+// Resume continues with the next mail, keeping the children untouched.
+func (a *actorImpl[T, D]) Resume() {
+	a.launch()
+}
+
+func (a *actorImpl[T, D]) HandleFailure(child SupervisedActor, cause *core.CoreError) {
+	strategy := a.supervisorStrategy()
+	childID := child.ID()
+	switch strategy.decide(cause) {
+	case SupervisorDirective_RESUME:
+		child.Resume()
+	case SupervisorDirective_RESTART:
+		targets := a.restartTargets(child, strategy)
+		if !a.allowRestart(childID, strategy) {
+			for _, target := range targets {
+				target.Stop()
+			}
+			a.fail(core.NewCoreError(core.ERROR_CODE_SUPERVISOR_RESTART_LIMIT,
+				fmt.Sprintf("child %s exceeded %d restarts within %v: %s", (&childID).String(), strategy.MaxRestarts, strategy.Within, cause.String())))
+			return
+		}
+		for _, target := range targets {
+			target.Restart(cause)
+		}
+	case SupervisorDirective_STOP:
+		child.Stop()
+	default:
+		escalated := core.NewCoreError(core.ERROR_CODE_SUPERVISOR_ESCALATED,
+			fmt.Sprintf("failure of child %s escalated: %s", (&childID).String(), cause.String()))
+		if a.Parent() != nil {
+			a.fail(escalated)
+			return
+		}
+		// nobody to escalate to, the child gets the directive of the default strategy
+		log.ErrorF("actor %s can not escalate: %s\n", (&a.id).String(), escalated.String())
+		if a.allowRestart(childID, DefaultSupervisorStrategy) {
+			child.Restart(cause)
+		} else {
+			child.Stop()
+		}
+	}
+}
+
+// fail reports a failure of this actor to its parent. Top level actors have
+// nobody to escalate to, so the failure is logged and the actor resumes.
+func (a *actorImpl[T, D]) fail(cause *core.CoreError) {
+	parent := a.Parent()
+	if parent == nil {
+		log.ErrorF("actor %s failed without supervisor: %s\n", (&a.id).String(), cause.String())
+		a.Resume()
+		return
+	}
+	parent.HandleFailure(a, cause)
+}
+
+func (a *actorImpl[T, D]) supervisorStrategy() *SupervisorStrategy {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.strategy == nil {
+		return DefaultSupervisorStrategy
+	}
+	return a.strategy
+}
+
+func (a *actorImpl[T, D]) restartTargets(child SupervisedActor, strategy *SupervisorStrategy) []SupervisedActor {
+	children := a.Children()
+	switch strategy.Type {
+	case SupervisorStrategyType_ONE_FOR_ALL:
+		return children
+	case SupervisorStrategyType_REST_FOR_ONE:
+		for i, c := range children {
+			if c.ID() == child.ID() {
+				return children[i:]
+			}
+		}
+	}
+	return []SupervisedActor{child}
+}
+
+func (a *actorImpl[T, D]) allowRestart(id uuid.UUID, strategy *SupervisorStrategy) bool {
+	if strategy.MaxRestarts < 0 {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	history := a.restarts[id][:0]
+	for _, t := range a.restarts[id] {
+		if strategy.Within <= 0 || now.Sub(t) < strategy.Within {
+			history = append(history, t)
+		}
+	}
+	if len(history) >= strategy.MaxRestarts {
+		a.restarts[id] = history
+		return false
+	}
+	a.restarts[id] = append(history, now)
+	return true
+}
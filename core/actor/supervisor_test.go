@@ -0,0 +1,198 @@
+package actor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeSupervisor uuid.UUIDType = "supervisor"
+
+// restartRecorder 记录被重启的次数
+type restartRecorder struct {
+	SupervisedActor
+	restarts int32
+}
+
+func (r *restartRecorder) Restart(cause *core.CoreError) {
+	atomic.AddInt32(&r.restarts, 1)
+	r.SupervisedActor.Restart(cause)
+}
+
+// failureRecorder 作为顶层 Supervisor 接收升级上来的错误
+type failureRecorder struct {
+	id       uuid.UUID
+	failures chan *core.CoreError
+}
+
+func (f *failureRecorder) ID() uuid.UUID                 { return f.id }
+func (f *failureRecorder) Stop()                         {}
+func (f *failureRecorder) Restart(cause *core.CoreError) {}
+func (f *failureRecorder) Resume()                       {}
+func (f *failureRecorder) setParent(parent Supervisor)   {}
+func (f *failureRecorder) removeChild(id uuid.UUID)      {}
+func (f *failureRecorder) HandleFailure(child SupervisedActor, cause *core.CoreError) {
+	f.failures <- cause
+}
+
+func startFailingActor(id uuid.UUID, received chan any) Actor[Mail[any], any] {
+	a := NewActor[Mail[any], any](MailboxType_MEMORY, id, nil)
+	a.Start(func(mail Mail[any]) {
+		if mail.Message() == "boom" {
+			panic("boom")
+		}
+		received <- mail.Message()
+	})
+	return a
+}
+
+func sendTo(t *testing.T, a Actor[Mail[any], any], message any) {
+	err := a.Mailbox().Send(context.Background(), NewMail[any](uuid.UUID{}, a.ID(), message, codec.JSON_CODEC))
+	assert.NoError(t, err)
+}
+
+func expectTerminated(t *testing.T, a Actor[Mail[any], any]) {
+	select {
+	case <-a.Terminated():
+	case <-time.After(time.Second):
+		t.Fatal("actor should terminate")
+	}
+}
+
+func expectMessage(t *testing.T, ch chan any, expected any) {
+	select {
+	case msg := <-ch:
+		assert.Equal(t, expected, msg)
+	case <-time.After(time.Second):
+		t.Fatalf("expected message %v", expected)
+	}
+}
+
+func TestSupervisor_OneForOne(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeSupervisor)
+	parent := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	received := make(chan any, 10)
+	child := startFailingActor(idGen.Next(), received)
+	sibling := &restartRecorder{SupervisedActor: NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)}
+	assert.Nil(t, parent.Supervise(child))
+	assert.Nil(t, parent.Supervise(sibling))
+
+	sendTo(t, child, "boom")
+	sendTo(t, child, "after")
+	expectMessage(t, received, "after")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&sibling.restarts))
+	assert.Len(t, parent.Children(), 2)
+	parent.Stop()
+}
+
+func TestSupervisor_OneForAll(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeSupervisor)
+	parent := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	parent.SetSupervisorStrategy(NewSupervisorStrategy(SupervisorStrategyType_ONE_FOR_ALL, 10, time.Minute, nil))
+	received := make(chan any, 10)
+	first := &restartRecorder{SupervisedActor: NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)}
+	child := startFailingActor(idGen.Next(), received)
+	assert.Nil(t, parent.Supervise(first))
+	assert.Nil(t, parent.Supervise(child))
+
+	sendTo(t, child, "boom")
+	sendTo(t, child, "after")
+	expectMessage(t, received, "after")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&first.restarts))
+	parent.Stop()
+}
+
+func TestSupervisor_RestForOne(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeSupervisor)
+	parent := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	parent.SetSupervisorStrategy(NewSupervisorStrategy(SupervisorStrategyType_REST_FOR_ONE, 10, time.Minute, nil))
+	received := make(chan any, 10)
+	before := &restartRecorder{SupervisedActor: NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)}
+	child := startFailingActor(idGen.Next(), received)
+	after := &restartRecorder{SupervisedActor: NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)}
+	assert.Nil(t, parent.Supervise(before))
+	assert.Nil(t, parent.Supervise(child))
+	assert.Nil(t, parent.Supervise(after))
+
+	sendTo(t, child, "boom")
+	sendTo(t, child, "after")
+	expectMessage(t, received, "after")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&before.restarts))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&after.restarts))
+	parent.Stop()
+}
+
+func TestSupervisor_RestartLimitEscalates(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeSupervisor)
+	root := &failureRecorder{id: idGen.Next(), failures: make(chan *core.CoreError, 1)}
+	parent := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	parent.SetSupervisorStrategy(NewSupervisorStrategy(SupervisorStrategyType_ONE_FOR_ONE, 1, time.Minute, nil))
+	parent.setParent(root)
+	received := make(chan any, 10)
+	child := startFailingActor(idGen.Next(), received)
+	assert.Nil(t, parent.Supervise(child))
+
+	sendTo(t, child, "boom")
+	sendTo(t, child, "boom")
+	select {
+	case cause := <-root.failures:
+		assert.Equal(t, core.ERROR_CODE_SUPERVISOR_RESTART_LIMIT, cause.Code())
+	case <-time.After(time.Second):
+		t.Fatal("restart limit should be escalated")
+	}
+	assert.Len(t, parent.Children(), 0, "child exceeding the limit should be stopped")
+}
+
+func TestSupervisor_Decider(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeSupervisor)
+	root := &failureRecorder{id: idGen.Next(), failures: make(chan *core.CoreError, 1)}
+	parent := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	parent.SetSupervisorStrategy(NewSupervisorStrategy(SupervisorStrategyType_ONE_FOR_ONE, 10, time.Minute, func(cause *core.CoreError) SupervisorDirective {
+		assert.Equal(t, core.ERROR_CODE_ACTOR_PANIC, cause.Code())
+		return SupervisorDirective_ESCALATE
+	}))
+	parent.setParent(root)
+	child := startFailingActor(idGen.Next(), make(chan any, 1))
+	assert.Nil(t, parent.Supervise(child))
+
+	sendTo(t, child, "boom")
+	select {
+	case cause := <-root.failures:
+		assert.Equal(t, core.ERROR_CODE_SUPERVISOR_ESCALATED, cause.Code())
+	case <-time.After(time.Second):
+		t.Fatal("failure should be escalated")
+	}
+}
+
+func TestSupervisor_TopLevelResumes(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeSupervisor)
+	received := make(chan any, 10)
+	a := startFailingActor(idGen.Next(), received)
+	sendTo(t, a, "boom")
+	sendTo(t, a, "after")
+	expectMessage(t, received, "after")
+	a.Stop()
+}
+
+func TestSupervisor_TopLevelEscalateRestartsChild(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeSupervisor)
+	parent := NewActor[Mail[any], any](MailboxType_MEMORY, idGen.Next(), nil)
+	parent.SetSupervisorStrategy(NewSupervisorStrategy(SupervisorStrategyType_ONE_FOR_ONE, 10, time.Minute, func(cause *core.CoreError) SupervisorDirective {
+		return SupervisorDirective_ESCALATE
+	}))
+	received := make(chan any, 10)
+	a := startFailingActor(idGen.Next(), received)
+	assert.Nil(t, parent.Supervise(a))
+
+	sendTo(t, a, "boom")
+	sendTo(t, a, "after")
+	// 没有上级可升级时按默认策略重启子 actor，排队的消息继续处理
+	expectMessage(t, received, "after")
+	a.Stop()
+}
@@ -0,0 +1,47 @@
+package actor
+
+import (
+	"fmt"
+
+	"github.com/shooyaaa/core"
+)
+
+// SystemMessage is control mail handled by the actor itself instead of its
+// process function. A priority mailbox delivers it ahead of user mail.
+type SystemMessage interface {
+	systemMessage()
+}
+
+// StopMessage stops the receiving actor.
+type StopMessage struct{}
+
+// SuperviseMessage applies a supervisor directive to the receiving actor.
+type SuperviseMessage struct {
+	Directive SupervisorDirective
+}
+
+func (StopMessage) systemMessage()      {}
+func (SuperviseMessage) systemMessage() {}
+
+// handleSystem runs a system message inside the receive loop, stop tells the
+// loop to return.
+func (a *actorImpl[T, D]) handleSystem(message SystemMessage) (stop bool, cause *core.CoreError) {
+	switch m := message.(type) {
+	case StopMessage:
+		a.Stop()
+		return true, nil
+	case SuperviseMessage:
+		switch m.Directive {
+		case SupervisorDirective_RESTART:
+			// Restart waits for this loop to return
+			go a.Restart(nil)
+			return true, nil
+		case SupervisorDirective_STOP:
+			a.Stop()
+			return true, nil
+		case SupervisorDirective_ESCALATE:
+			return true, core.NewCoreError(core.ERROR_CODE_SUPERVISOR_ESCALATED, fmt.Sprintf("actor %s escalated by system message", (&a.id).String()))
+		}
+	}
+	return false, nil
+}
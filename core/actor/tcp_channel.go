@@ -0,0 +1,236 @@
+package actor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/log"
+)
+
+const tcpDialAttempts = 5
+const tcpMinBackoff = 50 * time.Millisecond
+const tcpMaxBackoff = 2 * time.Second
+
+type TcpChannelClient interface {
+	Send(ctx context.Context, data []byte) *core.CoreError
+	Receive(ctx context.Context) ([]byte, *core.CoreError)
+}
+
+// TcpChannel sends length prefixed frames over one persistent connection,
+// dialing again with exponential backoff whenever the connection breaks.
+// Besides tcp:// it serves unix:// and ipc:// addresses over unix sockets.
+type TcpChannel struct {
+	addr   string
+	client TcpChannelClient
+	mu     sync.Mutex
+	conn   net.Conn
+}
+
+func (c *TcpChannel) Send(ctx context.Context, data []byte) *core.CoreError {
+	if c.client != nil {
+		return c.client.Send(ctx, data)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		conn, err := c.connect(ctx)
+		if err != nil {
+			return err
+		}
+		setDeadline(conn, ctx)
+		werr := writeFrame(conn, data)
+		if werr == nil {
+			return nil
+		}
+		c.reset()
+		if attempt > 0 {
+			return core.NewCoreError(core.ERROR_CODE_MAILBOX_SEND_ERROR, fmt.Sprintf("error while write to %s: %v", c.addr, werr))
+		}
+	}
+}
+
+// Receive is not supported, the server never writes to the connection and
+// mail from the remote side arrives through its own TcpChannel.
+func (c *TcpChannel) Receive(ctx context.Context) ([]byte, *core.CoreError) {
+	if c.client != nil {
+		return c.client.Receive(ctx)
+	}
+	return nil, core.NewCoreError(core.ERROR_CODE_ADDRESS_NOT_SUPPORTED, fmt.Sprintf("tcp channel to %s is send only", c.addr))
+}
+
+func (c *TcpChannel) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset()
+	return nil
+}
+
+func (c *TcpChannel) connect(ctx context.Context) (net.Conn, *core.CoreError) {
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	network, target := channelTarget(c.addr)
+	backoff := tcpMinBackoff
+	var dialer net.Dialer
+	for attempt := 1; ; attempt++ {
+		conn, err := dialer.DialContext(ctx, network, target)
+		if err == nil {
+			c.conn = conn
+			return conn, nil
+		}
+		if attempt >= tcpDialAttempts {
+			return nil, core.NewCoreError(core.ERROR_CODE_CHANNEL_CONNECT_ERROR, fmt.Sprintf("error while dial %s: %v", c.addr, err))
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, core.NewCoreError(core.ERROR_CODE_CHANNEL_CONNECT_ERROR, fmt.Sprintf("error while dial %s: %v", c.addr, ctx.Err()))
+		}
+		backoff *= 2
+		if backoff > tcpMaxBackoff {
+			backoff = tcpMaxBackoff
+		}
+	}
+}
+
+func (c *TcpChannel) reset() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = nil
+}
+
+func setDeadline(conn net.Conn, ctx context.Context) {
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+}
+
+func NewTcpChannel(addr string) RpcChannel {
+	return &TcpChannel{addr: addr}
+}
+
+// channelTarget splits a stream channel address into the network and the
+// address to dial.
+func channelTarget(addr string) (string, string) {
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return "unix", strings.TrimPrefix(addr, "unix://")
+	case strings.HasPrefix(addr, "ipc://"):
+		return "unix", strings.TrimPrefix(addr, "ipc://")
+	default:
+		return "tcp", strings.TrimPrefix(addr, "tcp://")
+	}
+}
+
+// MailReceiver takes mail arriving from a channel, Postman is the usual one.
+type MailReceiver interface {
+	Receive(ctx context.Context, mail Mail[any]) *core.CoreError
+}
+
+type MailReceiverFunc func(ctx context.Context, mail Mail[any]) *core.CoreError
+
+func (f MailReceiverFunc) Receive(ctx context.Context, mail Mail[any]) *core.CoreError {
+	return f(ctx, mail)
+}
+
+// TcpChannelServer accepts frames written by TcpChannel and hands the decoded
+// mail to a local postman.
+type TcpChannelServer struct {
+	postman  MailReceiver
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+func NewTcpChannelServer(postman MailReceiver) *TcpChannelServer {
+	return &TcpChannelServer{postman: postman, conns: make(map[net.Conn]struct{})}
+}
+
+func (s *TcpChannelServer) Listen(addr string) *core.CoreError {
+	network, target := channelTarget(addr)
+	if network == "unix" {
+		// only a socket nobody answers on is left over and safe to unlink
+		if conn, err := net.DialTimeout(network, target, time.Second); err == nil {
+			conn.Close()
+			return core.NewCoreError(core.ERROR_CODE_CHANNEL_LISTEN_ERROR, fmt.Sprintf("error while listen %s: address in use", addr))
+		}
+		os.Remove(target)
+	}
+	listener, err := net.Listen(network, target)
+	if err != nil {
+		return core.NewCoreError(core.ERROR_CODE_CHANNEL_LISTEN_ERROR, fmt.Sprintf("error while listen %s: %v", addr, err))
+	}
+	s.listener = listener
+	s.wg.Add(1)
+	go s.accept()
+	return nil
+}
+
+// Addr returns the address remote postmen use to reach this server.
+func (s *TcpChannelServer) Addr() string {
+	addr := s.listener.Addr()
+	return addr.Network() + "://" + addr.String()
+}
+
+func (s *TcpChannelServer) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *TcpChannelServer) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+func (s *TcpChannelServer) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	for {
+		payload, err := readFrame(reader)
+		if err != nil {
+			return
+		}
+		receiveFrame(context.Background(), s.postman, payload)
+	}
+}
+
+func receiveFrame(ctx context.Context, postman MailReceiver, payload []byte) {
+	mail, err := DecodeMail(payload)
+	if err != nil {
+		log.ErrorF("error while decode mail: %v\n", err)
+		return
+	}
+	if cerr := postman.Receive(ctx, mail); cerr != nil {
+		log.ErrorF("error while receive remote mail: %s\n", cerr.String())
+	}
+}
@@ -0,0 +1,133 @@
+package actor_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/testkit"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeTcpChannel uuid.UUIDType = "tcp_channel_test"
+
+func TestMailEnvelope(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTcpChannel)
+	mail := actor.NewMail[any](idGen.Next(), idGen.Next(), "hello", codec.JSON_CODEC, actor.WithCorrelationID(7))
+	data, err := actor.EncodeMail(mail)
+	assert.NoError(t, err)
+
+	decoded, err := actor.DecodeMail(data)
+	assert.NoError(t, err)
+	assert.Equal(t, mail.Sender(), decoded.Sender())
+	assert.Equal(t, mail.Receiver(), decoded.Receiver())
+	assert.Equal(t, "hello", decoded.Message())
+	assert.Equal(t, int64(7), decoded.CorrelationID())
+
+	_, err = actor.DecodeMail([]byte{255, '{', '}'})
+	assert.Error(t, err, "unknown codec should not panic")
+}
+
+func TestFrame(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, actor.WriteFrame(buffer, []byte("first")))
+	assert.NoError(t, actor.WriteFrame(buffer, []byte("second")))
+
+	first, err := actor.ReadFrame(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), first)
+	second, err := actor.ReadFrame(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), second)
+
+	_, err = actor.ReadFrame(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}))
+	assert.Error(t, err)
+}
+
+// newProbePostman puts a probe on a postman of its own.
+func newProbePostman(t *testing.T, id uuid.UUID) (actor.Postman, *testkit.TestProbe) {
+	probe := testkit.NewTestProbe(t, id)
+	pm := actor.NewPostman()
+	pm.Add(context.Background(), probe)
+	return pm, probe
+}
+
+func TestTcpChannel_RemoteAddress(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTcpChannel)
+	pm, probe := newProbePostman(t, idGen.Next())
+	server := actor.NewTcpChannelServer(pm)
+	assert.Nil(t, server.Listen("tcp://127.0.0.1:0"))
+	defer server.Close()
+	defer actor.CloseChannel(server.Addr())
+
+	remote := actor.NewRemoteAddress(actor.NewRpcAddress(server.Addr()), pm.ID())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		err := remote.Transfer(ctx, actor.NewMail[any](idGen.Next(), probe.ID(), float64(i), codec.JSON_CODEC))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 3; i++ {
+		mail := probe.ExpectMsg(float64(i))
+		assert.Equal(t, probe.ID(), mail.Receiver())
+	}
+	assert.Same(t, actor.GetChannelByAddress(server.Addr()), actor.GetChannelByAddress(server.Addr()), "channel should be pooled")
+}
+
+func TestTcpChannel_DialBackoff(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTcpChannel)
+	pm, probe := newProbePostman(t, idGen.Next())
+	server := actor.NewTcpChannelServer(pm)
+	defer server.Close()
+
+	channel := actor.NewTcpChannel("tcp://" + addr)
+	defer channel.(*actor.TcpChannel).Close()
+	data, _ := actor.EncodeMail(actor.NewMail[any](idGen.Next(), probe.ID(), "late server", codec.JSON_CODEC))
+	// 服务端还没起来，第一次发送拨号失败
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	cerr := channel.Send(ctx, data)
+	cancel()
+	if assert.NotNil(t, cerr) {
+		assert.Equal(t, core.ERROR_CODE_CHANNEL_CONNECT_ERROR, cerr.Code())
+	}
+
+	assert.Nil(t, server.Listen("tcp://"+addr))
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.Nil(t, channel.Send(ctx, data))
+	probe.ExpectMsg("late server")
+}
+
+func TestTcpChannel_ConnectError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	channel := actor.NewTcpChannel("tcp://" + addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	cerr := channel.Send(ctx, []byte("lost"))
+	assert.NotNil(t, cerr)
+	assert.Equal(t, core.ERROR_CODE_CHANNEL_CONNECT_ERROR, cerr.Code())
+}
+
+func TestTcpChannel_ReceiveNotSupported(t *testing.T) {
+	channel := actor.NewTcpChannel("tcp://127.0.0.1:1")
+	data, err := channel.Receive(context.Background())
+	assert.Nil(t, data)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, core.ERROR_CODE_ADDRESS_NOT_SUPPORTED, err.Code())
+	}
+}
@@ -0,0 +1,133 @@
+package actor
+
+import (
+	"context"
+	"time"
+
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/log"
+)
+
+// DefaultTimingWheel drives the timers of every actor, it starts with the
+// first timer. Timers keep the wheel they were started on, replacing it only
+// affects timers started afterwards.
+var DefaultTimingWheel = library.NewTimingWheel(10*time.Millisecond, 512)
+
+// ActorTimerImpl schedules mail to the actor itself. Timer mail goes through
+// the mailbox, so it is processed like any other mail. Starting a timer with
+// the key of an active timer replaces it, mail of a cancelled or replaced
+// timer that is still queued is discarded.
+type ActorTimerImpl interface {
+	StartSingleTimer(key string, message any, delay time.Duration)
+	StartPeriodicTimer(key string, message any, interval time.Duration)
+	CancelTimer(key string) bool
+	IsTimerActive(key string) bool
+}
+
+// TimerMessage wraps the message of a timer on its way through the mailbox.
+type TimerMessage struct {
+	Key        string
+	Generation int64
+	Message    any
+}
+
+type actorTimer struct {
+	generation int64
+	periodic   bool
+	timer      *library.WheelTimer
+}
+
+func (a *actorImpl[T, D]) StartSingleTimer(key string, message any, delay time.Duration) {
+	a.startTimer(key, message, delay, false)
+}
+
+func (a *actorImpl[T, D]) StartPeriodicTimer(key string, message any, interval time.Duration) {
+	a.startTimer(key, message, interval, true)
+}
+
+func (a *actorImpl[T, D]) startTimer(key string, message any, delay time.Duration, periodic bool) {
+	wheel := DefaultTimingWheel
+	wheel.Start()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stopped {
+		return
+	}
+	if current, ok := a.timers[key]; ok {
+		current.timer.Stop()
+	}
+	a.timerGeneration++
+	generation := a.timerGeneration
+	fire := func() {
+		a.fireTimer(wheel, TimerMessage{Key: key, Generation: generation, Message: message})
+	}
+	t := &actorTimer{generation: generation, periodic: periodic}
+	if periodic {
+		t.timer = wheel.EveryFunc(delay, fire)
+	} else {
+		t.timer = wheel.AfterFunc(delay, fire)
+	}
+	a.timers[key] = t
+}
+
+// fireTimer runs on the wheel goroutine, so a full mailbox drops the timer
+// mail after one tick instead of blocking every other timer. A dropped single
+// timer will not fire again, so it is no longer active.
+func (a *actorImpl[T, D]) fireTimer(wheel *library.TimingWheel, message TimerMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), wheel.Tick())
+	defer cancel()
+	mail := any(NewMail[any](a.id, a.id, any(message), codec.JSON_CODEC, WithKind(MailKind_TIMER))).(T)
+	if err := a.mailbox.Send(ctx, mail); err != nil {
+		log.ErrorF("error while fire timer %s of actor %s: %v\n", message.Key, (&a.id).String(), err)
+		a.mu.Lock()
+		if t, ok := a.timers[message.Key]; ok && !t.periodic && t.generation == message.Generation {
+			delete(a.timers, message.Key)
+		}
+		a.mu.Unlock()
+	}
+}
+
+func (a *actorImpl[T, D]) CancelTimer(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t, ok := a.timers[key]
+	if !ok {
+		return false
+	}
+	t.timer.Stop()
+	delete(a.timers, key)
+	return true
+}
+
+func (a *actorImpl[T, D]) IsTimerActive(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.timers[key]
+	return ok
+}
+
+func (a *actorImpl[T, D]) cancelTimers() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, t := range a.timers {
+		t.timer.Stop()
+		delete(a.timers, key)
+	}
+}
+
+// timerMail unwraps the mail of a timer that is still active, ok is false for
+// stale timer mail that has to be discarded.
+func (a *actorImpl[T, D]) timerMail(mail T, message TimerMessage) (T, bool) {
+	a.mu.Lock()
+	t, active := a.timers[message.Key]
+	active = active && t.generation == message.Generation
+	if active && !t.periodic {
+		delete(a.timers, message.Key)
+	}
+	a.mu.Unlock()
+	if !active {
+		return mail, false
+	}
+	return any(NewMail[any](mail.Sender(), mail.Receiver(), message.Message, mail.CodeC())).(T), true
+}
@@ -0,0 +1,114 @@
+package actor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/testkit"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeTimer uuid.UUIDType = "timer"
+
+const timerTick = 10 * time.Millisecond
+
+func TestActorTimer_SingleAndPeriodic(t *testing.T) {
+	testkit.VerifyNoLeaks(t)
+	clock := testkit.UseVirtualTime(t, timerTick)
+	probe := testkit.NewTestProbe(t, uuid.NewSimpleUUIDGenerator(UUIDTypeTimer).Next())
+
+	probe.StartSingleTimer("once", "single", 20*time.Millisecond)
+	assert.True(t, probe.IsTimerActive("once"))
+	clock.Advance(10 * time.Millisecond)
+	probe.ExpectNoMsg(20 * time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+	probe.ExpectMsg("single")
+	assert.False(t, probe.IsTimerActive("once"), "a fired single timer is no longer active")
+
+	probe.StartPeriodicTimer("tick", "tick", 10*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		clock.Advance(10 * time.Millisecond)
+		probe.ExpectMsg("tick")
+	}
+	assert.True(t, probe.CancelTimer("tick"))
+	assert.False(t, probe.CancelTimer("tick"))
+	clock.Advance(time.Second)
+	probe.ExpectNoMsg(20 * time.Millisecond)
+}
+
+func TestActorTimer_ReplaceSameKey(t *testing.T) {
+	testkit.VerifyNoLeaks(t)
+	clock := testkit.UseVirtualTime(t, timerTick)
+	probe := testkit.NewTestProbe(t, uuid.NewSimpleUUIDGenerator(UUIDTypeTimer).Next())
+	probe.StartSingleTimer("key", "first", 30*time.Millisecond)
+	probe.StartSingleTimer("key", "second", 10*time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+	probe.ExpectMsg("second")
+	clock.Advance(time.Second)
+	probe.ExpectNoMsg(20 * time.Millisecond)
+}
+
+func TestActorTimer_DiscardsQueuedAfterCancel(t *testing.T) {
+	testkit.VerifyNoLeaks(t)
+	clock := testkit.UseVirtualTime(t, timerTick)
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTimer)
+	probe := testkit.NewTestProbe(t, idGen.Next())
+	a := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, idGen.Next(), nil)
+	defer a.Stop()
+	gate := make(chan struct{})
+	a.Start(func(mail actor.Mail[any]) {
+		<-gate
+		forward(a, probe, mail.Message())
+	})
+	tell(t, a, "busy")
+	a.StartSingleTimer("late", "stale", 10*time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return actor.MailboxLen(a) == 1
+	}, time.Second, time.Millisecond, "timer mail should be queued behind the busy mail")
+	assert.True(t, a.CancelTimer("late"))
+	tell(t, a, "after")
+	close(gate)
+	probe.ExpectMsg("busy")
+	probe.ExpectMsg("after")
+}
+
+func TestActorTimer_StopCancels(t *testing.T) {
+	testkit.VerifyNoLeaks(t)
+	testkit.UseVirtualTime(t, timerTick)
+	a := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, uuid.NewSimpleUUIDGenerator(UUIDTypeTimer).Next(), nil)
+	a.Start(func(mail actor.Mail[any]) {})
+	a.StartPeriodicTimer("tick", "tick", 10*time.Millisecond)
+	a.Stop()
+	assert.False(t, a.IsTimerActive("tick"))
+	a.StartSingleTimer("after stop", "ignored", 10*time.Millisecond)
+	assert.False(t, a.IsTimerActive("after stop"))
+}
+
+func TestActorTimer_DroppedOnFullMailbox(t *testing.T) {
+	testkit.VerifyNoLeaks(t)
+	clock := testkit.UseVirtualTime(t, timerTick)
+	a := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, uuid.NewSimpleUUIDGenerator(UUIDTypeTimer).Next(), nil, actor.WithMailboxCapacity(1))
+	defer a.Stop()
+	// 没有启动的 actor 不取信，第一封信就把 mailbox 占满了
+	tell(t, a, "full")
+	a.StartSingleTimer("once", "dropped", 10*time.Millisecond)
+	a.StartPeriodicTimer("tick", "dropped", 10*time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return !a.IsTimerActive("once")
+	}, time.Second, time.Millisecond, "a dropped single timer should not stay active")
+	assert.True(t, a.IsTimerActive("tick"), "a periodic timer fires again")
+}
+
+func TestActorTimer_UserMailLookingLikeTimer(t *testing.T) {
+	probe := testkit.NewTestProbe(t, uuid.NewSimpleUUIDGenerator(UUIDTypeTimer).Next())
+	// 没有计时器标记的邮件即使字段相同也是普通消息
+	message := map[string]any{"Key": "key", "Generation": float64(1), "Message": "user"}
+	tell(t, probe, message)
+	probe.ExpectMsg(message)
+	tell(t, probe, actor.TimerMessage{Key: "key", Generation: 1, Message: "user"})
+	probe.ExpectMsg(actor.TimerMessage{Key: "key", Generation: 1, Message: "user"})
+}
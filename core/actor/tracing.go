@@ -0,0 +1,243 @@
+package actor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/log"
+)
+
+// TraceContext follows a mail and the mail sent because of it. SpanID is the
+// span the mail was last passed on by, Baggage travels unchanged.
+type TraceContext struct {
+	TraceID string            `json:",omitempty"`
+	SpanID  string            `json:",omitempty"`
+	Baggage map[string]string `json:",omitempty"`
+}
+
+// NewTraceContext starts a trace, put it on the first mail with WithTrace.
+func NewTraceContext(baggage map[string]string) TraceContext {
+	return TraceContext{TraceID: randomID(16), SpanID: randomID(8), Baggage: baggage}
+}
+
+func (tc TraceContext) Valid() bool {
+	return tc.TraceID != ""
+}
+
+// child is the context of a span started under tc.
+func (tc TraceContext) child() TraceContext {
+	return TraceContext{TraceID: tc.TraceID, SpanID: randomID(8), Baggage: tc.Baggage}
+}
+
+func randomID(size int) string {
+	id := make([]byte, size)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+type SpanKind string
+
+// ENQUEUE is a postman putting mail into a mailbox, DISPATCH a postoffice
+// passing mail to the postman of the receiver and PROCESS an actor running
+// its process function.
+const SpanKind_ENQUEUE SpanKind = "enqueue"
+const SpanKind_DISPATCH SpanKind = "dispatch"
+const SpanKind_PROCESS SpanKind = "process"
+
+type Span struct {
+	TraceID  string   `json:"trace_id"`
+	SpanID   string   `json:"span_id"`
+	ParentID string   `json:"parent_id"`
+	Kind     SpanKind `json:"kind"`
+	// Node is the postman, postoffice or actor that recorded the span
+	Node     string            `json:"node"`
+	Sender   string            `json:"sender"`
+	Receiver string            `json:"receiver"`
+	Start    time.Time         `json:"start"`
+	Duration time.Duration     `json:"duration"`
+	Baggage  map[string]string `json:"baggage,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// SpanExporter receives every finished span, Export must not block.
+type SpanExporter interface {
+	Export(span Span)
+	Close() error
+}
+
+// WithPostmanTracing records the enqueue spans of the postman and the process
+// spans of its actors.
+func WithPostmanTracing(exporter SpanExporter) PostmanOption {
+	return func(m *postmanImpl) {
+		m.exporter = exporter
+	}
+}
+
+// WithPostofficeTracing records the dispatch spans of the postoffice.
+func WithPostofficeTracing(exporter SpanExporter) PostofficeOption {
+	return func(p *postofficeImpl) {
+		p.exporter = exporter
+	}
+}
+
+// startSpan starts a span under the trace of mail and returns the mail
+// passed on under the span, finish exports the span.
+func startSpan(exporter SpanExporter, kind SpanKind, node string, mail Mail[any]) (Mail[any], func(err *core.CoreError)) {
+	trace := mail.Trace()
+	if exporter == nil || !trace.Valid() {
+		return mail, func(*core.CoreError) {}
+	}
+	child := trace.child()
+	sender, receiver := mail.Sender(), mail.Receiver()
+	span := Span{
+		TraceID:  trace.TraceID,
+		SpanID:   child.SpanID,
+		ParentID: trace.SpanID,
+		Kind:     kind,
+		Node:     node,
+		Sender:   (&sender).String(),
+		Receiver: (&receiver).String(),
+		Start:    time.Now(),
+		Baggage:  trace.Baggage,
+	}
+	return withTrace(mail, child), func(err *core.CoreError) {
+		span.Duration = time.Since(span.Start)
+		if err != nil {
+			span.Error = err.String()
+		}
+		exporter.Export(span)
+	}
+}
+
+// withTrace copies mail into another trace context, the rest of the header
+// stays as it is.
+func withTrace(mail Mail[any], trace TraceContext) Mail[any] {
+	if m, ok := mail.(*mailImpl[any]); ok {
+		copied := *m
+		copied.trace = trace
+		return &copied
+	}
+	return NewMail(mail.Sender(), mail.Receiver(), mail.Message(), mail.CodeC(), WithKind(mail.Kind()),
+		WithCorrelationID(mail.CorrelationID()), WithSeq(mail.Seq()), WithAck(mail.Ack()), WithEpoch(mail.Epoch()), WithTrace(trace))
+}
+
+// mailboxTrace is the trace context of the mail an actor is processing,
+// mailboxes put outgoing mail without a trace of its own into it.
+type mailboxTrace struct {
+	current atomic.Pointer[TraceContext]
+}
+
+func (t *mailboxTrace) setTrace(trace *TraceContext) {
+	t.current.Store(trace)
+}
+
+func (t *mailboxTrace) stamp(mail Mail[any]) Mail[any] {
+	current := t.current.Load()
+	if current == nil || mail.Trace().Valid() {
+		return mail
+	}
+	return withTrace(mail, *current)
+}
+
+// traceProcess starts the process span of traced mail. Until finish, the
+// mailbox puts the mail the actor sends into the span.
+func (a *actorImpl[T, D]) traceProcess(msg T) (T, func(cause *core.CoreError)) {
+	a.mu.Lock()
+	m, _ := a.postman.(*postmanImpl)
+	a.mu.Unlock()
+	if m == nil || m.exporter == nil || !msg.Trace().Valid() {
+		return msg, func(*core.CoreError) {}
+	}
+	mail, finish := startSpan(m.exporter, SpanKind_PROCESS, (&a.id).String(), msg)
+	mailbox, _ := any(a.mailbox).(interface{ setTrace(*TraceContext) })
+	if mailbox != nil {
+		trace := mail.Trace()
+		mailbox.setTrace(&trace)
+	}
+	return any(mail).(T), func(cause *core.CoreError) {
+		if mailbox != nil {
+			mailbox.setTrace(nil)
+		}
+		finish(cause)
+	}
+}
+
+// NewMemorySpanExporter keeps the spans for tests.
+func NewMemorySpanExporter() *MemorySpanExporter {
+	return &MemorySpanExporter{}
+}
+
+type MemorySpanExporter struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+func (e *MemorySpanExporter) Export(span Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+func (e *MemorySpanExporter) Close() error {
+	return nil
+}
+
+// Spans returns the spans of the trace ordered by start, every span when
+// traceID is empty.
+func (e *MemorySpanExporter) Spans(traceID string) []Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := make([]Span, 0, len(e.spans))
+	for _, span := range e.spans {
+		if traceID == "" || span.TraceID == traceID {
+			spans = append(spans, span)
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].Start.Before(spans[j].Start)
+	})
+	return spans
+}
+
+type fileSpanExporter struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewFileSpanExporter appends every span to path as a line of json.
+func NewFileSpanExporter(path string) (SpanExporter, *core.CoreError) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, core.NewCoreError(core.ERROR_CODE_PERSISTENCE_ERROR, fmt.Sprintf("create span dir: %v", err))
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, core.NewCoreError(core.ERROR_CODE_PERSISTENCE_ERROR, fmt.Sprintf("open span file: %v", err))
+	}
+	return &fileSpanExporter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (e *fileSpanExporter) Export(span Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.encoder.Encode(span); err != nil {
+		log.ErrorF("error while export span %s: %v\n", span.SpanID, err)
+	}
+}
+
+func (e *fileSpanExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
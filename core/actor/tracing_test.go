@@ -0,0 +1,193 @@
+package actor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeTracing uuid.UUIDType = "tracing"
+
+// newForwardingActor passes every mail on to next, next nil hands it to done.
+func newForwardingActor(id uuid.UUID, next *uuid.UUID, done chan Mail[any]) Actor[Mail[any], any] {
+	a := NewActor[Mail[any], any](MailboxType_MEMORY, id, nil)
+	a.Start(func(mail Mail[any]) {
+		if next == nil {
+			done <- mail
+			return
+		}
+		a.Mailbox().Send(context.Background(), NewMail[any](id, *next, mail.Message(), codec.JSON_CODEC))
+	})
+	return a
+}
+
+func TestTracing_AcrossPostmen(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTracing)
+	ctx := context.Background()
+	exporter := NewMemorySpanExporter()
+	ring := library.NewConsistentHash[Address](150, nil, nil)
+	po := NewPostoffice(ring, idGen.Next(), WithPostofficeTracing(exporter))
+	gatePostman := NewPostman(WithPostmanID(idGen.Next()), WithPostmanTracing(exporter))
+	roomPostman := NewPostman(WithPostmanID(idGen.Next()), WithPostmanTracing(exporter))
+	// the room postman is reached over tcp
+	server := NewTcpChannelServer(roomPostman)
+	assert.Nil(t, server.Listen("tcp://127.0.0.1:0"))
+	defer server.Close()
+	defer CloseChannel(server.Addr())
+	po.Add(ctx, NewLocalPostManAddress(gatePostman))
+	po.Add(ctx, NewRemoteAddress(NewRpcAddress(server.Addr()), roomPostman.ID()))
+	gatePostman.Register(ctx, NewLocalPostOfficeAddress(po))
+	roomPostman.Register(ctx, NewLocalPostOfficeAddress(po))
+
+	gateID := idOn(ring, idGen, gatePostman)
+	roomID := idOn(ring, idGen, roomPostman)
+	storageID := idOn(ring, idGen, roomPostman)
+	done := make(chan Mail[any], 1)
+	gate := newForwardingActor(gateID, &roomID, done)
+	room := newForwardingActor(roomID, &storageID, done)
+	storage := newForwardingActor(storageID, nil, done)
+	for _, a := range []Actor[Mail[any], any]{gate, room, storage} {
+		defer a.Stop()
+	}
+	gatePostman.Add(ctx, gate)
+	roomPostman.Add(ctx, room)
+	roomPostman.Add(ctx, storage)
+
+	root := NewTraceContext(map[string]string{"player": "42"})
+	assert.Nil(t, gatePostman.Deliver(ctx, NewMail[any](idGen.Next(), gateID, "move", codec.JSON_CODEC, WithTrace(root))))
+	var stored Mail[any]
+	select {
+	case stored = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the move should reach the storage")
+	}
+	assert.Equal(t, "move", stored.Message())
+	assert.Equal(t, root.TraceID, stored.Trace().TraceID)
+	assert.Equal(t, map[string]string{"player": "42"}, stored.Trace().Baggage)
+
+	// storage 处理完才导出最后一个 span
+	assert.Eventually(t, func() bool {
+		return len(exporter.Spans(root.TraceID)) == 7
+	}, time.Second, 5*time.Millisecond)
+	spans := exporter.Spans(root.TraceID)
+	expected := []struct {
+		kind     SpanKind
+		receiver uuid.UUID
+	}{
+		{SpanKind_ENQUEUE, gateID},
+		{SpanKind_PROCESS, gateID},
+		{SpanKind_DISPATCH, roomID},
+		{SpanKind_ENQUEUE, roomID},
+		{SpanKind_PROCESS, roomID},
+		{SpanKind_ENQUEUE, storageID},
+		{SpanKind_PROCESS, storageID},
+	}
+	parent := root.SpanID
+	for i, span := range spans {
+		assert.Equal(t, expected[i].kind, span.Kind, "span %d", i)
+		assert.Equal(t, (&expected[i].receiver).String(), span.Receiver, "span %d", i)
+		assert.Equal(t, parent, span.ParentID, "span %d", i)
+		assert.Equal(t, "42", span.Baggage["player"])
+		parent = span.SpanID
+	}
+	assert.Empty(t, exporter.Spans("unknown"))
+}
+
+func TestTracing_KeepsHeader(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTracing)
+	ctx := context.Background()
+	exporter := NewMemorySpanExporter()
+	ring := library.NewConsistentHash[Address](150, nil, nil)
+	po := NewPostoffice(ring, idGen.Next(), WithPostofficeTracing(exporter))
+	sender := NewPostman(WithPostmanID(idGen.Next()), WithPostmanTracing(exporter))
+	receiver := NewPostman(WithPostmanID(idGen.Next()), WithPostmanTracing(exporter))
+	for _, pm := range []Postman{sender, receiver} {
+		po.Add(ctx, NewLocalPostManAddress(pm))
+		pm.Register(ctx, NewLocalPostOfficeAddress(po))
+	}
+	outgoing := NewReliableDelivery(sender, ReliableConfig{RedeliverAfter: time.Minute})
+	defer outgoing.Stop()
+	incoming := NewReliableDelivery(receiver, ReliableConfig{RedeliverAfter: time.Minute})
+	defer incoming.Stop()
+	senderID := idOn(ring, idGen, sender)
+	receiverID := idOn(ring, idGen, receiver)
+	done := make(chan Mail[any], 1)
+	a := newForwardingActor(receiverID, nil, done)
+	defer a.Stop()
+	receiver.Add(ctx, a)
+	expect := func(message any) Mail[any] {
+		select {
+		case mail := <-done:
+			assert.Equal(t, message, mail.Message())
+			return mail
+		case <-time.After(time.Second):
+			t.Fatalf("%v should be delivered", message)
+			return nil
+		}
+	}
+
+	// 带 trace 的可靠邮件经过 postoffice 后 seq 和 epoch 还在，ack 才能确认
+	root := NewTraceContext(nil)
+	assert.Nil(t, outgoing.Send(ctx, NewMail[any](senderID, receiverID, "reliable", codec.JSON_CODEC, WithTrace(root))))
+	mail := expect("reliable")
+	assert.Equal(t, root.TraceID, mail.Trace().TraceID)
+	assert.Eventually(t, func() bool {
+		return outgoing.Unconfirmed() == 0
+	}, time.Second, 5*time.Millisecond, "the ack should confirm the traced mail")
+
+	assert.Nil(t, sender.Deliver(ctx, NewMail[any](senderID, receiverID, "kinded", codec.JSON_CODEC, WithKind("custom"), WithCorrelationID(9), WithTrace(root))))
+	mail = expect("kinded")
+	assert.Equal(t, MailKind("custom"), mail.Kind())
+	assert.Equal(t, int64(9), mail.CorrelationID())
+	assert.Equal(t, root.TraceID, mail.Trace().TraceID)
+}
+
+func TestTracing_Untraced(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTracing)
+	ctx := context.Background()
+	exporter := NewMemorySpanExporter()
+	pm := NewPostman(WithPostmanTracing(exporter))
+	a, received := newReceivingActor(idGen.Next())
+	defer a.Stop()
+	pm.Add(ctx, a)
+	assert.Nil(t, pm.Deliver(ctx, NewMail[any](uuid.UUID{}, a.ID(), "plain", codec.JSON_CODEC)))
+	expectMessage(t, received, "plain")
+	assert.Empty(t, exporter.Spans(""))
+}
+
+func TestFileSpanExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans", "trace.jsonl")
+	exporter, err := NewFileSpanExporter(path)
+	assert.Nil(t, err)
+	trace := NewTraceContext(nil)
+	first := Span{TraceID: trace.TraceID, SpanID: "a", ParentID: trace.SpanID, Kind: SpanKind_ENQUEUE, Start: time.Now().UTC()}
+	second := Span{TraceID: trace.TraceID, SpanID: "b", ParentID: "a", Kind: SpanKind_PROCESS, Start: time.Now().UTC(), Error: "boom"}
+	exporter.Export(first)
+	exporter.Export(second)
+	assert.NoError(t, exporter.Close())
+
+	file, openErr := os.Open(path)
+	assert.NoError(t, openErr)
+	defer file.Close()
+	var spans []Span
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var span Span
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+	if assert.Len(t, spans, 2) {
+		assert.True(t, first.Start.Equal(spans[0].Start))
+		spans[0].Start, spans[1].Start = first.Start, second.Start
+		assert.Equal(t, []Span{first, second}, spans)
+	}
+}
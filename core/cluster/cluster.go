@@ -0,0 +1,568 @@
+package cluster
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/shooyaaa/log"
+)
+
+type Config struct {
+	// Listen is the tcp address of the node, port 0 picks a free port
+	Listen string
+	// Seeds are the addresses of nodes to join through, a seed may list itself
+	Seeds             []string
+	HeartbeatInterval time.Duration
+	GossipInterval    time.Duration
+	// Fanout is the number of members gossiped to per round
+	Fanout int
+	// PhiThreshold marks a member unreachable once its phi reaches it
+	PhiThreshold             float64
+	AcceptableHeartbeatPause time.Duration
+	MinStdDeviation          time.Duration
+	MaxSampleSize            int
+
+	// HeartbeatTimeout bounds sending a heartbeat, so a crashed member does
+	// not hold up the others. Zero means HeartbeatInterval.
+	HeartbeatTimeout time.Duration
+	// DownAfter is how long a member stays unreachable before it is marked
+	// down and dropped from the peers, zero never marks a member down.
+	DownAfter time.Duration
+}
+
+var DefaultConfig = Config{
+	Listen:                   "tcp://127.0.0.1:0",
+	HeartbeatInterval:        time.Second,
+	GossipInterval:           time.Second,
+	Fanout:                   3,
+	PhiThreshold:             8,
+	AcceptableHeartbeatPause: 3 * time.Second,
+	MinStdDeviation:          100 * time.Millisecond,
+	MaxSampleSize:            1000,
+	HeartbeatTimeout:         200 * time.Millisecond,
+	DownAfter:                30 * time.Second,
+}
+
+// Node joins a postman and its postoffice to the cluster. Members gossip the
+// membership and heartbeat each other, the postoffice ring always holds the
+// postmen of the members that are up and reachable from this node.
+type Node interface {
+	actor.MailReceiver
+	Start(ctx context.Context) *core.CoreError
+	// Leave tells the cluster the node is going away and stops it.
+	Leave(ctx context.Context) *core.CoreError
+	Stop()
+	Self() Member
+	Members() []Member
+	Unreachable() []Member
+	Subscribe(fn func(MemberEvent)) (cancel func())
+}
+
+type nodeImpl struct {
+	config     Config
+	postman    actor.Postman
+	postoffice actor.Postoffice
+	server     *actor.TcpChannelServer
+
+	mu          sync.Mutex
+	self        Member
+	members     map[uuid.UUID]Member
+	detectors   map[uuid.UUID]*PhiAccrualDetector
+	unreachable map[uuid.UUID]time.Time
+	backoff     map[uuid.UUID]*heartbeatBackoff
+	sequence    int64
+	subscribers map[int]func(MemberEvent)
+	next        int
+
+	ringMu      sync.Mutex
+	ring        map[uuid.UUID]actor.Address
+	ringChanges []ringChange
+	applying    bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewNode(config Config, postman actor.Postman, postoffice actor.Postoffice) Node {
+	return &nodeImpl{
+		config:      config,
+		postman:     postman,
+		postoffice:  postoffice,
+		members:     make(map[uuid.UUID]Member),
+		detectors:   make(map[uuid.UUID]*PhiAccrualDetector),
+		unreachable: make(map[uuid.UUID]time.Time),
+		backoff:     make(map[uuid.UUID]*heartbeatBackoff),
+		subscribers: make(map[int]func(MemberEvent)),
+		ring:        make(map[uuid.UUID]actor.Address),
+	}
+}
+
+func (n *nodeImpl) Start(ctx context.Context) *core.CoreError {
+	// members are told apart by the postman id, the zero id would clash on every node
+	if n.postman.ID() == (uuid.UUID{}) {
+		return core.NewCoreError(core.ERROR_CODE_POSTMAN_ID_MISSING, "cluster node needs a postman created with WithPostmanID")
+	}
+	server := actor.NewTcpChannelServer(n)
+	if err := server.Listen(n.config.Listen); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.server = server
+	n.self = Member{ID: n.postman.ID(), Address: server.Addr(), Status: MemberStatus_UP, Version: time.Now().UnixNano()}
+	n.members[n.self.ID] = n.self
+	n.mu.Unlock()
+	n.reconcile(ctx)
+	n.stop = make(chan struct{})
+	n.wg.Add(1)
+	go n.run(n.stop)
+	n.join(ctx)
+	return nil
+}
+
+// join gossips to the seeds, the first one answering tells the rest.
+func (n *nodeImpl) join(ctx context.Context) {
+	self := n.Self()
+	for _, seed := range n.config.Seeds {
+		if seed != self.Address {
+			n.gossipTo(ctx, Member{Address: seed}, false)
+		}
+	}
+}
+
+func (n *nodeImpl) Leave(ctx context.Context) *core.CoreError {
+	n.mu.Lock()
+	n.self.Status = MemberStatus_LEFT
+	n.self.Version++
+	n.members[n.self.ID] = n.self
+	n.mu.Unlock()
+	for _, member := range n.peers() {
+		n.gossipTo(ctx, member, true)
+	}
+	n.Stop()
+	return nil
+}
+
+func (n *nodeImpl) Stop() {
+	n.mu.Lock()
+	stop, server := n.stop, n.server
+	n.stop, n.server = nil, nil
+	n.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	n.wg.Wait()
+	server.Close()
+	for _, member := range n.Members() {
+		actor.CloseChannel(member.Address)
+	}
+}
+
+func (n *nodeImpl) Self() Member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.self
+}
+
+// Members lists every member known, including those that left.
+func (n *nodeImpl) Members() []Member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	members := make([]Member, 0, len(n.members))
+	for _, member := range n.members {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Address < members[j].Address
+	})
+	return members
+}
+
+func (n *nodeImpl) Unreachable() []Member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var members []Member
+	for id := range n.unreachable {
+		members = append(members, n.members[id])
+	}
+	return members
+}
+
+func (n *nodeImpl) Subscribe(fn func(MemberEvent)) func() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	key := n.next
+	n.next++
+	n.subscribers[key] = fn
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subscribers, key)
+	}
+}
+
+// publish runs the subscribers, the caller must not hold mu.
+func (n *nodeImpl) publish(events []MemberEvent) {
+	if len(events) == 0 {
+		return
+	}
+	n.mu.Lock()
+	subscribers := make([]func(MemberEvent), 0, len(n.subscribers))
+	for _, fn := range n.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	n.mu.Unlock()
+	for _, event := range events {
+		for _, fn := range subscribers {
+			fn(event)
+		}
+	}
+}
+
+// Receive handles the cluster mail and hands everything else to the postman.
+func (n *nodeImpl) Receive(ctx context.Context, mail actor.Mail[any]) *core.CoreError {
+	switch mail.Kind() {
+	case MailKind_GOSSIP:
+		if gossip, ok := actor.AsMessage[Gossip](mail.Message()); ok {
+			n.receiveGossip(ctx, gossip)
+		}
+		return nil
+	case MailKind_HEARTBEAT:
+		if heartbeat, ok := actor.AsMessage[Heartbeat](mail.Message()); ok {
+			n.heartbeat(heartbeat.Member, time.Now())
+		}
+		return nil
+	}
+	return n.postman.Receive(ctx, mail)
+}
+
+func (n *nodeImpl) receiveGossip(ctx context.Context, gossip Gossip) {
+	now := time.Now()
+	var events []MemberEvent
+	n.mu.Lock()
+	for _, member := range append(gossip.Members, gossip.Sender) {
+		if member.ID == n.self.ID || member.Address == "" {
+			continue
+		}
+		known, ok := n.members[member.ID]
+		if ok && !member.supersedes(known) {
+			continue
+		}
+		n.members[member.ID] = member
+		switch {
+		case member.Status == MemberStatus_LEFT || member.Status == MemberStatus_DOWN:
+			n.forget(member.ID)
+			if ok && known.Status != member.Status {
+				eventType := MemberEvent_LEFT
+				if member.Status == MemberStatus_DOWN {
+					eventType = MemberEvent_DOWN
+				}
+				events = append(events, MemberEvent{Type: eventType, Member: member})
+			}
+		case !ok || known.Status == MemberStatus_LEFT || known.Version != member.Version:
+			// a new member or a new run of one, watched from now on
+			detector := n.newDetector()
+			detector.Heartbeat(now)
+			n.detectors[member.ID] = detector
+			delete(n.unreachable, member.ID)
+			delete(n.backoff, member.ID)
+			events = append(events, MemberEvent{Type: MemberEvent_UP, Member: member})
+		}
+	}
+	self := n.self
+	n.mu.Unlock()
+	n.heartbeat(gossip.Sender.ID, now)
+	n.publish(events)
+	n.reconcile(ctx)
+	if !gossip.Reply && self.Status == MemberStatus_UP {
+		n.gossipTo(ctx, gossip.Sender, true)
+	}
+}
+
+func (n *nodeImpl) newDetector() *PhiAccrualDetector {
+	return NewPhiAccrualDetector(n.config.PhiThreshold, n.config.MaxSampleSize, n.config.MinStdDeviation, n.config.AcceptableHeartbeatPause, n.config.HeartbeatInterval)
+}
+
+func (n *nodeImpl) heartbeat(id uuid.UUID, now time.Time) {
+	n.mu.Lock()
+	detector := n.detectors[id]
+	n.mu.Unlock()
+	if detector != nil {
+		detector.Heartbeat(now)
+	}
+}
+
+func (n *nodeImpl) run(stop chan struct{}) {
+	defer n.wg.Done()
+	heartbeats := time.NewTicker(n.config.HeartbeatInterval)
+	defer heartbeats.Stop()
+	gossips := time.NewTicker(n.config.GossipInterval)
+	defer gossips.Stop()
+	for {
+		select {
+		case <-heartbeats.C:
+			n.sendHeartbeats(time.Now())
+			if n.checkReachability(time.Now()) {
+				n.reconcile(context.Background())
+			}
+		case <-gossips.C:
+			n.gossip()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// peers are the other members that are up.
+func (n *nodeImpl) peers() []Member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var peers []Member
+	for id, member := range n.members {
+		if id != n.self.ID && member.Status == MemberStatus_UP {
+			peers = append(peers, member)
+		}
+	}
+	return peers
+}
+
+// sendHeartbeats heartbeats the peers at once and waits for all of them, a
+// peer that could not be reached is skipped for a growing number of rounds.
+func (n *nodeImpl) sendHeartbeats(now time.Time) {
+	n.mu.Lock()
+	n.sequence++
+	heartbeat := Heartbeat{Member: n.self.ID, Sequence: n.sequence}
+	n.mu.Unlock()
+	timeout := n.config.HeartbeatTimeout
+	if timeout <= 0 || timeout > n.config.HeartbeatInterval {
+		timeout = n.config.HeartbeatInterval
+	}
+	var wg sync.WaitGroup
+	for _, member := range n.peers() {
+		if !n.heartbeatDue(member.ID, now) {
+			continue
+		}
+		wg.Add(1)
+		go func(member Member) {
+			defer wg.Done()
+			err := n.send(member, MailKind_HEARTBEAT, heartbeat, timeout)
+			n.heartbeatSent(member.ID, now, err == nil)
+		}(member)
+	}
+	wg.Wait()
+}
+
+// maxHeartbeatBackoff caps the backoff of a peer, in heartbeat intervals.
+const maxHeartbeatBackoff = 32
+
+// heartbeatBackoff spaces out the heartbeats to a peer that could not be reached.
+type heartbeatBackoff struct {
+	delay time.Duration
+	next  time.Time
+}
+
+func (n *nodeImpl) heartbeatDue(id uuid.UUID, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	backoff := n.backoff[id]
+	return backoff == nil || !now.Before(backoff.next)
+}
+
+// heartbeatSent doubles the backoff of a peer on failure and drops it once
+// a heartbeat gets through.
+func (n *nodeImpl) heartbeatSent(id uuid.UUID, now time.Time, ok bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if ok {
+		delete(n.backoff, id)
+		return
+	}
+	if _, known := n.members[id]; !known {
+		return
+	}
+	backoff := n.backoff[id]
+	if backoff == nil {
+		backoff = &heartbeatBackoff{}
+		n.backoff[id] = backoff
+	}
+	backoff.delay = min(max(2*backoff.delay, n.config.HeartbeatInterval), maxHeartbeatBackoff*n.config.HeartbeatInterval)
+	backoff.next = now.Add(backoff.delay)
+}
+
+// forget stops watching a member that left or is down, the caller holds mu.
+func (n *nodeImpl) forget(id uuid.UUID) {
+	delete(n.detectors, id)
+	delete(n.unreachable, id)
+	delete(n.backoff, id)
+}
+
+// gossip sends the membership to Fanout random peers, it keeps knocking at
+// the seeds while no peer is known.
+func (n *nodeImpl) gossip() {
+	ctx := context.Background()
+	peers := n.peers()
+	if len(peers) == 0 {
+		n.join(ctx)
+		return
+	}
+	rand.Shuffle(len(peers), func(i, j int) {
+		peers[i], peers[j] = peers[j], peers[i]
+	})
+	fanout := n.config.Fanout
+	if fanout <= 0 || fanout > len(peers) {
+		fanout = len(peers)
+	}
+	for _, member := range peers[:fanout] {
+		n.gossipTo(ctx, member, false)
+	}
+}
+
+func (n *nodeImpl) gossipTo(ctx context.Context, member Member, reply bool) {
+	n.mu.Lock()
+	gossip := Gossip{Sender: n.self, Members: make([]Member, 0, len(n.members)), Reply: reply}
+	for _, known := range n.members {
+		gossip.Members = append(gossip.Members, known)
+	}
+	n.mu.Unlock()
+	n.send(member, MailKind_GOSSIP, gossip, n.config.GossipInterval)
+}
+
+func (n *nodeImpl) send(member Member, kind actor.MailKind, message any, timeout time.Duration) *core.CoreError {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	self := n.Self()
+	address := actor.NewRemoteAddress(actor.NewRpcAddress(member.Address), member.ID)
+	err := address.Transfer(ctx, actor.NewMail[any](self.ID, member.ID, message, codec.JSON_CODEC, actor.WithKind(kind)))
+	if err != nil {
+		log.DebugF("error while send cluster mail to %s: %s", member.Address, err.String())
+	}
+	return err
+}
+
+// checkReachability flips the reachability of the members whose phi crossed
+// the threshold and marks those unreachable for DownAfter down, it reports
+// whether anything changed.
+func (n *nodeImpl) checkReachability(now time.Time) bool {
+	var events []MemberEvent
+	n.mu.Lock()
+	for id, detector := range n.detectors {
+		available := detector.IsAvailable(now)
+		since, unreachable := n.unreachable[id]
+		if !available && unreachable && n.config.DownAfter > 0 && now.Sub(since) >= n.config.DownAfter {
+			member := n.members[id]
+			member.Status = MemberStatus_DOWN
+			n.members[id] = member
+			n.forget(id)
+			events = append(events, MemberEvent{Type: MemberEvent_DOWN, Member: member})
+			continue
+		}
+		if available == !unreachable {
+			continue
+		}
+		if available {
+			delete(n.unreachable, id)
+			events = append(events, MemberEvent{Type: MemberEvent_REACHABLE, Member: n.members[id]})
+		} else {
+			n.unreachable[id] = now
+			events = append(events, MemberEvent{Type: MemberEvent_UNREACHABLE, Member: n.members[id]})
+		}
+	}
+	n.mu.Unlock()
+	n.publish(events)
+	return len(events) > 0
+}
+
+// reconcile brings the postoffice ring in line with the reachable members.
+// The changes are queued under ringMu and applied in order after releasing
+// it, by the reconcile that is not already applying changes.
+func (n *nodeImpl) reconcile(ctx context.Context) {
+	n.ringMu.Lock()
+	n.mu.Lock()
+	wanted := make(map[uuid.UUID]Member)
+	for id, member := range n.members {
+		if _, unreachable := n.unreachable[id]; member.Status == MemberStatus_UP && !unreachable {
+			wanted[id] = member
+		}
+	}
+	n.mu.Unlock()
+	for id, address := range n.ring {
+		if member, ok := wanted[id]; ok && member.Address == address.String() {
+			continue
+		}
+		delete(n.ring, id)
+		n.ringChanges = append(n.ringChanges, ringChange{address: address, remove: true})
+	}
+	for id, member := range wanted {
+		if _, ok := n.ring[id]; ok {
+			continue
+		}
+		address := &memberAddress{node: n, id: id, address: member.Address}
+		n.ring[id] = address
+		n.ringChanges = append(n.ringChanges, ringChange{address: address})
+	}
+	if n.applying {
+		// the reconcile applying changes right now picks these up too
+		n.ringMu.Unlock()
+		return
+	}
+	n.applying = true
+	for len(n.ringChanges) > 0 {
+		changes := n.ringChanges
+		n.ringChanges = nil
+		n.ringMu.Unlock()
+		n.applyRingChanges(ctx, changes)
+		n.ringMu.Lock()
+	}
+	n.applying = false
+	n.ringMu.Unlock()
+}
+
+// ringChange is a change of the postoffice ring computed by reconcile.
+type ringChange struct {
+	address actor.Address
+	remove  bool
+}
+
+// applyRingChanges runs without ringMu, removing a postman notifies the
+// remaining ones over the network.
+func (n *nodeImpl) applyRingChanges(ctx context.Context, changes []ringChange) {
+	for _, change := range changes {
+		if change.remove {
+			if err := n.postoffice.Remove(ctx, change.address); err != nil {
+				log.ErrorF("error while remove %s from the ring: %s\n", change.address.String(), err.String())
+			}
+		} else if err := n.postoffice.Add(ctx, change.address); err != nil {
+			log.ErrorF("error while add %s to the ring: %s\n", change.address.String(), err.String())
+		}
+	}
+}
+
+// memberAddress is the ring entry of a member. It is named by the address of
+// the member, so every node places actors on the same ring.
+type memberAddress struct {
+	node    *nodeImpl
+	id      uuid.UUID
+	address string
+}
+
+func (a *memberAddress) String() string {
+	return a.address
+}
+
+func (a *memberAddress) ID() uuid.UUID {
+	return a.id
+}
+
+func (a *memberAddress) Transfer(ctx context.Context, mail actor.Mail[any]) *core.CoreError {
+	if a.id == a.node.postman.ID() {
+		return a.node.postman.Receive(ctx, mail)
+	}
+	return actor.NewRemoteAddress(actor.NewRpcAddress(a.address), a.id).Transfer(ctx, mail)
+}
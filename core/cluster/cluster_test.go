@@ -0,0 +1,329 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core"
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeCluster uuid.UUIDType = "cluster"
+
+var testConfig = Config{
+	Listen:                   "tcp://127.0.0.1:0",
+	HeartbeatInterval:        20 * time.Millisecond,
+	GossipInterval:           30 * time.Millisecond,
+	Fanout:                   2,
+	PhiThreshold:             8,
+	AcceptableHeartbeatPause: 100 * time.Millisecond,
+	MinStdDeviation:          10 * time.Millisecond,
+	MaxSampleSize:            100,
+}
+
+type testNode struct {
+	Node
+	postman    actor.Postman
+	postoffice actor.Postoffice
+	ring       library.ConsistentHash[actor.Address]
+
+	mu     sync.Mutex
+	events []MemberEvent
+}
+
+func (n *testNode) record(event MemberEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+}
+
+func (n *testNode) seen(eventType MemberEventType, id uuid.UUID) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, event := range n.events {
+		if event.Type == eventType && event.Member.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func startNode(t *testing.T, idGen uuid.SimpleUUIDGenerator, seeds ...string) *testNode {
+	return startNodeAs(t, idGen.Next(), seeds...)
+}
+
+func startNodeAs(t *testing.T, id uuid.UUID, seeds ...string) *testNode {
+	return startNodeWith(t, testConfig, id, seeds...)
+}
+
+func startNodeWith(t *testing.T, config Config, id uuid.UUID, seeds ...string) *testNode {
+	ctx := context.Background()
+	postman := actor.NewPostman(actor.WithPostmanID(id))
+	ring := library.NewConsistentHash[actor.Address](150, nil, nil)
+	postoffice := actor.NewPostoffice(ring, uuid.UUID{Type: "postoffice", ID: id.ID})
+	postman.Register(ctx, actor.NewLocalPostOfficeAddress(postoffice))
+	config.Seeds = seeds
+	n := &testNode{Node: NewNode(config, postman, postoffice), postman: postman, postoffice: postoffice, ring: ring}
+	n.Subscribe(n.record)
+	assert.Nil(t, n.Start(ctx))
+	return n
+}
+
+func ringIDs(ring library.ConsistentHash[actor.Address]) map[uuid.UUID]bool {
+	ids := make(map[uuid.UUID]bool)
+	for _, a := range ring.GetNodes() {
+		ids[a.ID()] = true
+	}
+	return ids
+}
+
+func expectRing(t *testing.T, n *testNode, nodes ...*testNode) {
+	want := make(map[uuid.UUID]bool)
+	for _, node := range nodes {
+		want[node.postman.ID()] = true
+	}
+	assert.Eventually(t, func() bool {
+		got := ringIDs(n.ring)
+		if len(got) != len(want) {
+			return false
+		}
+		for id := range want {
+			if !got[id] {
+				return false
+			}
+		}
+		return true
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func idOn(ring library.ConsistentHash[actor.Address], idGen uuid.SimpleUUIDGenerator, postman actor.Postman) uuid.UUID {
+	for {
+		id := idGen.Next()
+		if a, ok := ring.Get((&id).String()); ok && a.ID() == postman.ID() {
+			return id
+		}
+	}
+}
+
+func TestCluster_JoinThroughSeeds(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeCluster)
+	a := startNode(t, idGen)
+	defer a.Stop()
+	seed := a.Self().Address
+	b := startNode(t, idGen, seed)
+	defer b.Stop()
+	c := startNode(t, idGen, seed)
+	defer c.Stop()
+
+	for _, n := range []*testNode{a, b, c} {
+		expectRing(t, n, a, b, c)
+	}
+	// c only knows the seed, it learns b through gossip
+	assert.True(t, c.seen(MemberEvent_UP, b.postman.ID()))
+
+	// every node places an actor on the same postman
+	actorGen := uuid.NewSimpleUUIDGenerator(UUIDTypeCluster)
+	id := idOn(a.ring, actorGen, c.postman)
+	for _, n := range []*testNode{b, c} {
+		owner, ok := n.ring.Get((&id).String())
+		assert.True(t, ok)
+		assert.Equal(t, c.postman.ID(), owner.ID())
+	}
+
+	received := make(chan any, 1)
+	worker := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, id, nil)
+	worker.Start(func(mail actor.Mail[any]) {
+		received <- mail.Message()
+	})
+	defer worker.Stop()
+	c.postman.Add(context.Background(), worker)
+	assert.Nil(t, a.postman.Deliver(context.Background(), actor.NewMail[any](uuid.UUID{}, id, "render", codec.JSON_CODEC)))
+	select {
+	case message := <-received:
+		assert.Equal(t, "render", message)
+	case <-time.After(time.Second):
+		t.Fatal("mail should cross the cluster")
+	}
+	// 形如心跳的用户消息不能被节点当作心跳吞掉
+	heartbeat := map[string]any{"Member": "m", "Sequence": float64(1)}
+	assert.Nil(t, a.postman.Deliver(context.Background(), actor.NewMail[any](uuid.UUID{}, id, heartbeat, codec.JSON_CODEC)))
+	select {
+	case message := <-received:
+		assert.Equal(t, heartbeat, message)
+	case <-time.After(time.Second):
+		t.Fatal("user mail shaped like a heartbeat should be delivered")
+	}
+}
+
+func TestCluster_Unreachable(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeCluster)
+	a := startNode(t, idGen)
+	defer a.Stop()
+	b := startNode(t, idGen, a.Self().Address)
+	defer b.Stop()
+	c := startNode(t, idGen, a.Self().Address)
+	expectRing(t, a, a, b, c)
+	expectRing(t, b, a, b, c)
+
+	// a watches an actor held by c
+	ctx := context.Background()
+	actorGen := uuid.NewSimpleUUIDGenerator(UUIDTypeCluster)
+	target := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, idOn(a.ring, actorGen, c.postman), nil)
+	target.Start(func(mail actor.Mail[any]) {})
+	defer target.Stop()
+	c.postman.Add(ctx, target)
+	watcherReceived := make(chan any, 10)
+	watcher := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, idOn(a.ring, actorGen, a.postman), nil)
+	watcher.Start(func(mail actor.Mail[any]) {
+		watcherReceived <- mail.Message()
+	})
+	defer watcher.Stop()
+	a.postman.Add(ctx, watcher)
+	assert.Nil(t, watcher.Watch(actor.NewActorAddress(target.ID(), actor.NewLocalPostOfficeAddress(a.postoffice))))
+	time.Sleep(100 * time.Millisecond)
+
+	// c crashes without leaving
+	c.Stop()
+	expectRing(t, a, a, b)
+	expectRing(t, b, a, b)
+	assert.True(t, a.seen(MemberEvent_UNREACHABLE, c.postman.ID()))
+	select {
+	case message := <-watcherReceived:
+		terminated, ok := actor.AsTerminated(message)
+		assert.True(t, ok)
+		assert.Equal(t, target.ID(), terminated.Actor)
+		assert.Equal(t, actor.TerminatedReason_UNREACHABLE, terminated.Reason)
+	case <-time.After(3 * time.Second):
+		t.Fatal("watcher should learn the target is unreachable")
+	}
+}
+
+func TestCluster_Leave(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeCluster)
+	a := startNode(t, idGen)
+	defer a.Stop()
+	b := startNode(t, idGen, a.Self().Address)
+	expectRing(t, a, a, b)
+
+	assert.Nil(t, b.Leave(context.Background()))
+	expectRing(t, a, a)
+	assert.True(t, a.seen(MemberEvent_LEFT, b.postman.ID()))
+	assert.False(t, a.seen(MemberEvent_UNREACHABLE, b.postman.ID()), "a node that left is not unreachable")
+
+	// b comes back with a new run and is up again
+	rejoined := startNodeAs(t, b.postman.ID(), a.Self().Address)
+	defer rejoined.Stop()
+	expectRing(t, a, a, rejoined)
+	assert.Greater(t, rejoined.Self().Version, b.Self().Version)
+}
+
+func TestCluster_Down(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeCluster)
+	config := testConfig
+	config.HeartbeatTimeout = 10 * time.Millisecond
+	config.DownAfter = 200 * time.Millisecond
+	a := startNodeWith(t, config, idGen.Next())
+	defer a.Stop()
+	b := startNodeWith(t, config, idGen.Next(), a.Self().Address)
+	defer b.Stop()
+	c := startNodeWith(t, config, idGen.Next(), a.Self().Address)
+	expectRing(t, a, a, b, c)
+	expectRing(t, b, a, b, c)
+
+	// c crashes, after DownAfter it is down and no longer a peer
+	c.Stop()
+	for _, n := range []*testNode{a, b} {
+		assert.Eventually(t, func() bool {
+			return n.seen(MemberEvent_DOWN, c.postman.ID())
+		}, 3*time.Second, 10*time.Millisecond)
+		for _, peer := range n.Node.(*nodeImpl).peers() {
+			assert.NotEqual(t, c.postman.ID(), peer.ID, "a member that is down is not a peer")
+		}
+		assert.Empty(t, n.Unreachable())
+	}
+	expectRing(t, a, a, b)
+
+	// 同一次运行的 up 状态不能覆盖 down，重启之后才能重新加入
+	rejoined := startNodeWith(t, config, c.postman.ID(), a.Self().Address)
+	defer rejoined.Stop()
+	expectRing(t, a, a, b, rejoined)
+}
+
+func TestCluster_HeartbeatBackoff(t *testing.T) {
+	ring := library.NewConsistentHash[actor.Address](150, nil, nil)
+	id := uuid.NewSimpleUUIDGenerator(UUIDTypeCluster).Next()
+	n := NewNode(testConfig, actor.NewPostman(), actor.NewPostoffice(ring, uuid.UUID{Type: "postoffice", ID: 1})).(*nodeImpl)
+	n.members[id] = Member{ID: id, Address: "tcp://127.0.0.1:1", Status: MemberStatus_UP}
+	interval := testConfig.HeartbeatInterval
+	now := time.Now()
+
+	assert.True(t, n.heartbeatDue(id, now))
+	n.heartbeatSent(id, now, false)
+	assert.False(t, n.heartbeatDue(id, now.Add(interval/2)))
+	assert.True(t, n.heartbeatDue(id, now.Add(interval)))
+	n.heartbeatSent(id, now, false)
+	assert.False(t, n.heartbeatDue(id, now.Add(interval)), "the backoff doubles")
+	assert.True(t, n.heartbeatDue(id, now.Add(2*interval)))
+	for i := 0; i < 10; i++ {
+		n.heartbeatSent(id, now, false)
+	}
+	assert.True(t, n.heartbeatDue(id, now.Add(maxHeartbeatBackoff*interval)), "the backoff is capped")
+	n.heartbeatSent(id, now, true)
+	assert.True(t, n.heartbeatDue(id, now))
+}
+
+func TestCluster_PubSub(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeCluster)
+	ctx := context.Background()
+	a := startNode(t, idGen)
+	defer a.Stop()
+	b := startNode(t, idGen, a.Self().Address)
+	defer b.Stop()
+	expectRing(t, a, a, b)
+	expectRing(t, b, a, b)
+	for _, n := range []*testNode{a, b} {
+		pubsub := actor.NewPubSub(n.postman)
+		defer pubsub.Stop()
+		defer ReplicatePubSub(n, pubsub)()
+	}
+
+	actorGen := uuid.NewSimpleUUIDGenerator(UUIDTypeCluster)
+	received := make(chan any, 10)
+	subscriber := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, actorGen.Next(), nil)
+	subscriber.Start(func(mail actor.Mail[any]) {
+		received <- mail.Message()
+	})
+	defer subscriber.Stop()
+	b.postman.Add(ctx, subscriber)
+	assert.Nil(t, subscriber.Subscribe("rooms"))
+
+	publisher := actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, actorGen.Next(), nil)
+	publisher.Start(func(mail actor.Mail[any]) {})
+	defer publisher.Stop()
+	a.postman.Add(ctx, publisher)
+	// the subscription reaches a asynchronously
+	assert.Eventually(t, func() bool {
+		assert.Nil(t, publisher.Publish("rooms", "opened"))
+		select {
+		case message := <-received:
+			return message == "opened"
+		case <-time.After(20 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestCluster_RequiresPostmanID(t *testing.T) {
+	ring := library.NewConsistentHash[actor.Address](150, nil, nil)
+	postoffice := actor.NewPostoffice(ring, uuid.UUID{Type: "postoffice", ID: 1})
+	n := NewNode(testConfig, actor.NewPostman(), postoffice)
+	err := n.Start(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, core.ERROR_CODE_POSTMAN_ID_MISSING, err.Code())
+}
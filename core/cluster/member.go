@@ -0,0 +1,68 @@
+package cluster
+
+import (
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/uuid"
+)
+
+type MemberStatus string
+
+const MemberStatus_UP MemberStatus = "up"
+const MemberStatus_LEFT MemberStatus = "left"
+
+// MemberStatus_DOWN marks a member that stayed unreachable for too long, it
+// has to restart to join again.
+const MemberStatus_DOWN MemberStatus = "down"
+
+// memberStatusOrder is the order a member moves through within one version.
+var memberStatusOrder = map[MemberStatus]int{MemberStatus_UP: 0, MemberStatus_DOWN: 1, MemberStatus_LEFT: 2}
+
+// Member is a node of the cluster, identified by the id of its postman.
+type Member struct {
+	ID      uuid.UUID
+	Address string
+	Status  MemberStatus
+	// Version orders the states of a member, only the member itself raises
+	// it. A node starts from the current time, so a restarted node supersedes
+	// what the cluster remembers of its earlier run.
+	Version int64
+}
+
+// supersedes reports whether m is a later state of the member than other.
+func (m Member) supersedes(other Member) bool {
+	if m.Version != other.Version {
+		return m.Version > other.Version
+	}
+	return memberStatusOrder[m.Status] > memberStatusOrder[other.Status]
+}
+
+type MemberEventType string
+
+const MemberEvent_UP MemberEventType = "up"
+const MemberEvent_LEFT MemberEventType = "left"
+const MemberEvent_UNREACHABLE MemberEventType = "unreachable"
+const MemberEvent_REACHABLE MemberEventType = "reachable"
+const MemberEvent_DOWN MemberEventType = "down"
+
+// MemberEvent reports a change of the membership as seen by the local node.
+type MemberEvent struct {
+	Type   MemberEventType
+	Member Member
+}
+
+// MailKind_GOSSIP and MailKind_HEARTBEAT mark the mail nodes exchange.
+const MailKind_GOSSIP actor.MailKind = "gossip"
+const MailKind_HEARTBEAT actor.MailKind = "heartbeat"
+
+// Gossip carries the membership known to Sender, a node answers gossip that
+// is not a Reply with its own.
+type Gossip struct {
+	Sender  Member
+	Members []Member
+	Reply   bool
+}
+
+type Heartbeat struct {
+	Member   uuid.UUID
+	Sequence int64
+}
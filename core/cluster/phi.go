@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// PhiAccrualDetector tells how suspicious the silence of a node is. It keeps
+// a window of heartbeat intervals and reports phi, the -log10 of the chance
+// that a heartbeat still arrives after the current silence, assuming the
+// intervals are normally distributed.
+type PhiAccrualDetector struct {
+	threshold     float64
+	maxSamples    int
+	minStdDev     float64
+	pause         float64
+	mu            sync.Mutex
+	intervals     []float64
+	sum           float64
+	squaredSum    float64
+	lastBeat      time.Time
+	firstEstimate float64
+}
+
+// NewPhiAccrualDetector creates a detector, firstHeartbeat is the interval
+// expected until real intervals are known and pause the silence tolerated on
+// top of the mean interval.
+func NewPhiAccrualDetector(threshold float64, maxSamples int, minStdDev, pause, firstHeartbeat time.Duration) *PhiAccrualDetector {
+	if maxSamples <= 0 {
+		maxSamples = 1000
+	}
+	return &PhiAccrualDetector{
+		threshold:     threshold,
+		maxSamples:    maxSamples,
+		minStdDev:     float64(minStdDev.Milliseconds()),
+		pause:         float64(pause.Milliseconds()),
+		firstEstimate: float64(firstHeartbeat.Milliseconds()),
+	}
+}
+
+// Heartbeat records a heartbeat arriving at now.
+func (d *PhiAccrualDetector) Heartbeat(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastBeat.IsZero() {
+		// two samples around the estimate give a mean of the estimate and a
+		// deviation of a quarter of it
+		deviation := d.firstEstimate / 4
+		d.add(d.firstEstimate - deviation)
+		d.add(d.firstEstimate + deviation)
+	} else {
+		d.add(float64(now.Sub(d.lastBeat).Milliseconds()))
+	}
+	d.lastBeat = now
+}
+
+func (d *PhiAccrualDetector) add(interval float64) {
+	if len(d.intervals) >= d.maxSamples {
+		dropped := d.intervals[0]
+		d.intervals = d.intervals[1:]
+		d.sum -= dropped
+		d.squaredSum -= dropped * dropped
+	}
+	d.intervals = append(d.intervals, interval)
+	d.sum += interval
+	d.squaredSum += interval * interval
+}
+
+// Phi is zero until the first heartbeat.
+func (d *PhiAccrualDetector) Phi(now time.Time) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastBeat.IsZero() {
+		return 0
+	}
+	n := float64(len(d.intervals))
+	mean := d.sum / n
+	stdDev := math.Sqrt(math.Max(d.squaredSum/n-mean*mean, 0))
+	if stdDev < d.minStdDev {
+		stdDev = d.minStdDev
+	}
+	if stdDev <= 0 {
+		stdDev = 1
+	}
+	return phi(float64(now.Sub(d.lastBeat).Milliseconds()), mean+d.pause, stdDev)
+}
+
+func (d *PhiAccrualDetector) IsAvailable(now time.Time) bool {
+	return d.Phi(now) < d.threshold
+}
+
+// phi uses the logistic approximation of the cumulative normal distribution.
+func phi(elapsed, mean, stdDev float64) float64 {
+	y := (elapsed - mean) / stdDev
+	e := math.Exp(-y * (1.5976 + 0.070566*y*y))
+	if elapsed > mean {
+		return -math.Log10(e / (1 + e))
+	}
+	return -math.Log10(1 - 1/(1+e))
+}
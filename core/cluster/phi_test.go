@@ -0,0 +1,41 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhiAccrualDetector(t *testing.T) {
+	d := NewPhiAccrualDetector(8, 100, 10*time.Millisecond, 0, 100*time.Millisecond)
+	start := time.Now()
+	assert.Equal(t, float64(0), d.Phi(start), "没有心跳之前 phi 为 0")
+
+	now := start
+	for i := 0; i < 10; i++ {
+		d.Heartbeat(now)
+		now = now.Add(100 * time.Millisecond)
+	}
+	last := now.Add(-100 * time.Millisecond)
+	assert.True(t, d.IsAvailable(last.Add(100*time.Millisecond)))
+	assert.Less(t, d.Phi(last.Add(50*time.Millisecond)), d.Phi(last.Add(150*time.Millisecond)), "phi should grow with the silence")
+	assert.False(t, d.IsAvailable(last.Add(time.Second)))
+
+	d.Heartbeat(last.Add(time.Second))
+	assert.True(t, d.IsAvailable(last.Add(time.Second+50*time.Millisecond)), "a heartbeat makes the node available again")
+}
+
+func TestPhiAccrualDetector_AcceptablePause(t *testing.T) {
+	strict := NewPhiAccrualDetector(8, 100, 10*time.Millisecond, 0, 100*time.Millisecond)
+	tolerant := NewPhiAccrualDetector(8, 100, 10*time.Millisecond, time.Second, 100*time.Millisecond)
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		strict.Heartbeat(now)
+		tolerant.Heartbeat(now)
+		now = now.Add(100 * time.Millisecond)
+	}
+	silence := now.Add(500 * time.Millisecond)
+	assert.False(t, strict.IsAvailable(silence))
+	assert.True(t, tolerant.IsAvailable(silence))
+}
@@ -0,0 +1,32 @@
+package cluster
+
+import (
+	"context"
+
+	"github.com/shooyaaa/core/actor"
+)
+
+// ReplicatePubSub keeps the peers of the pub/sub mediator in line with the
+// reachable members of the cluster, every node has to call it.
+func ReplicatePubSub(node Node, pubsub actor.PubSub) (cancel func()) {
+	ctx := context.Background()
+	cancel = node.Subscribe(func(event MemberEvent) {
+		switch event.Type {
+		case MemberEvent_UP, MemberEvent_REACHABLE:
+			pubsub.AddPeer(ctx, peerAddress(event.Member))
+		case MemberEvent_LEFT, MemberEvent_UNREACHABLE:
+			pubsub.RemovePeer(event.Member.ID)
+		}
+	})
+	self := node.Self()
+	for _, member := range node.Members() {
+		if member.ID != self.ID && member.Status == MemberStatus_UP {
+			pubsub.AddPeer(ctx, peerAddress(member))
+		}
+	}
+	return cancel
+}
+
+func peerAddress(member Member) actor.Address {
+	return actor.NewRemoteAddress(actor.NewRpcAddress(member.Address), member.ID)
+}
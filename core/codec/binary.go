@@ -0,0 +1,239 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// binaryOpCodec writes Op as varints: Type, Ts, Version, then Data as a count, 0 for
+// nil, and every key followed by a tagged value. Keys are sorted so equal
+// ops encode to equal bytes. Integers decode as int64 or uint64 and float32
+// as float64, nested values as map[string]interface{} and []interface{}.
+type binaryOpCodec struct {
+}
+
+const (
+	binaryNil byte = iota
+	binaryFalse
+	binaryTrue
+	binaryInt
+	binaryUint
+	binaryFloat
+	binaryString
+	binaryBytes
+	binaryList
+	binaryMap
+)
+
+var errBinaryShort = errors.New("binary codec: unexpected end of data")
+
+func (binaryOpCodec) Encode(op Op) ([]byte, error) {
+	buf := make([]byte, 0, 32)
+	buf = binary.AppendVarint(buf, int64(op.Type))
+	buf = binary.AppendVarint(buf, op.Ts)
+	buf = binary.AppendVarint(buf, int64(op.Version))
+	return appendBinaryMap(buf, op.Data)
+}
+
+func (binaryOpCodec) Decode(data []byte) (Op, error) {
+	r := &binaryReader{data: data}
+	op := Op{Type: OpType(r.varint()), Ts: r.varint(), Version: int(r.varint())}
+	op.Data = r.dict()
+	if r.err == nil && r.pos != len(r.data) {
+		r.err = fmt.Errorf("binary codec: %d trailing bytes", len(r.data)-r.pos)
+	}
+	return op, r.err
+}
+
+func appendBinaryMap(buf []byte, m map[string]interface{}) ([]byte, error) {
+	if m == nil {
+		return binary.AppendUvarint(buf, 0), nil
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(m))+1)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var err error
+	for _, k := range keys {
+		buf = appendBinaryString(buf, k)
+		if buf, err = appendBinaryValue(buf, m[k]); err != nil {
+			return nil, fmt.Errorf("binary codec: key %s: %w", k, err)
+		}
+	}
+	return buf, nil
+}
+
+func appendBinaryString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendBinaryValue(buf []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, binaryNil), nil
+	case bool:
+		if v {
+			return append(buf, binaryTrue), nil
+		}
+		return append(buf, binaryFalse), nil
+	case int:
+		return binary.AppendVarint(append(buf, binaryInt), int64(v)), nil
+	case int8:
+		return binary.AppendVarint(append(buf, binaryInt), int64(v)), nil
+	case int16:
+		return binary.AppendVarint(append(buf, binaryInt), int64(v)), nil
+	case int32:
+		return binary.AppendVarint(append(buf, binaryInt), int64(v)), nil
+	case int64:
+		return binary.AppendVarint(append(buf, binaryInt), v), nil
+	case uint:
+		return binary.AppendUvarint(append(buf, binaryUint), uint64(v)), nil
+	case uint8:
+		return binary.AppendUvarint(append(buf, binaryUint), uint64(v)), nil
+	case uint16:
+		return binary.AppendUvarint(append(buf, binaryUint), uint64(v)), nil
+	case uint32:
+		return binary.AppendUvarint(append(buf, binaryUint), uint64(v)), nil
+	case uint64:
+		return binary.AppendUvarint(append(buf, binaryUint), v), nil
+	case float32:
+		return binary.LittleEndian.AppendUint64(append(buf, binaryFloat), math.Float64bits(float64(v))), nil
+	case float64:
+		return binary.LittleEndian.AppendUint64(append(buf, binaryFloat), math.Float64bits(v)), nil
+	case string:
+		return appendBinaryString(append(buf, binaryString), v), nil
+	case []byte:
+		buf = binary.AppendUvarint(append(buf, binaryBytes), uint64(len(v)))
+		return append(buf, v...), nil
+	case []interface{}:
+		buf = binary.AppendUvarint(append(buf, binaryList), uint64(len(v)))
+		var err error
+		for _, item := range v {
+			if buf, err = appendBinaryValue(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]interface{}:
+		return appendBinaryMap(append(buf, binaryMap), v)
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+// binaryReader keeps the first error, reads after it return zero values.
+type binaryReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		r.err = errBinaryShort
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.err = errBinaryShort
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *binaryReader) bytes(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)-r.pos) {
+		r.err = errBinaryShort
+		return nil
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+func (r *binaryReader) dict() map[string]interface{} {
+	count := r.uvarint()
+	if r.err != nil || count == 0 {
+		return nil
+	}
+	// every entry takes at least two bytes, a corrupt count fails here
+	if count-1 > uint64(len(r.data)-r.pos)/2 {
+		r.err = errBinaryShort
+		return nil
+	}
+	m := make(map[string]interface{}, count-1)
+	for i := uint64(1); i < count && r.err == nil; i++ {
+		k := string(r.bytes(r.uvarint()))
+		m[k] = r.value()
+	}
+	return m
+}
+
+func (r *binaryReader) value() interface{} {
+	tag := r.bytes(1)
+	if r.err != nil {
+		return nil
+	}
+	switch tag[0] {
+	case binaryNil:
+		return nil
+	case binaryFalse:
+		return false
+	case binaryTrue:
+		return true
+	case binaryInt:
+		return r.varint()
+	case binaryUint:
+		return r.uvarint()
+	case binaryFloat:
+		b := r.bytes(8)
+		if r.err != nil {
+			return nil
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case binaryString:
+		return string(r.bytes(r.uvarint()))
+	case binaryBytes:
+		return append([]byte(nil), r.bytes(r.uvarint())...)
+	case binaryList:
+		count := r.uvarint()
+		if r.err == nil && count > uint64(len(r.data)-r.pos) {
+			r.err = errBinaryShort
+		}
+		if r.err != nil {
+			return nil
+		}
+		list := make([]interface{}, 0, count)
+		for i := uint64(0); i < count && r.err == nil; i++ {
+			list = append(list, r.value())
+		}
+		return list
+	case binaryMap:
+		return r.dict()
+	default:
+		r.err = fmt.Errorf("binary codec: unknown value tag %d", tag[0])
+		return nil
+	}
+}
@@ -3,12 +3,18 @@ package codec
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sync"
 )
 
 type CODEC_TYPE uint8
 
 const (
 	JSON_CODEC CODEC_TYPE = iota
+	MSGPACK_CODEC
+	GOB_CODEC
+	// BINARY_CODEC only encodes Op, see binaryOpCodec
+	BINARY_CODEC
 )
 
 type Codec[T any] interface {
@@ -16,23 +22,109 @@ type Codec[T any] interface {
 	Decode([]byte) (T, error)
 }
 
-type jsonCodec[T any] struct {
+// Marshaler encodes values of any type, registered with RegisterMarshaler it
+// backs the Codec of every T for its CODEC_TYPE.
+type Marshaler interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
 }
 
-func (j *jsonCodec[T]) Encode(t T) ([]byte, error) {
-	return json.Marshal(t)
+type codecKey struct {
+	codec CODEC_TYPE
+	t     reflect.Type
 }
-func (j *jsonCodec[T]) Decode(data []byte) (T, error) {
+
+var registry = struct {
+	sync.RWMutex
+	marshalers map[CODEC_TYPE]Marshaler
+	codecs     map[codecKey]any
+}{
+	marshalers: map[CODEC_TYPE]Marshaler{
+		JSON_CODEC:    jsonMarshaler{},
+		MSGPACK_CODEC: msgpackMarshaler{},
+		GOB_CODEC:     gobMarshaler{},
+	},
+	codecs: map[codecKey]any{
+		{BINARY_CODEC, reflect.TypeOf(Op{})}: binaryOpCodec{},
+	},
+}
+
+// RegisterMarshaler makes codecType encode any type with m, it replaces what
+// was registered for codecType before.
+func RegisterMarshaler(codecType CODEC_TYPE, m Marshaler) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.marshalers[codecType] = m
+}
+
+// Register makes codecType encode T with c. A codec registered for T wins
+// over the marshaler of codecType.
+func Register[T any](codecType CODEC_TYPE, c Codec[T]) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.codecs[codecKey{codecType, typeOf[T]()}] = c
+}
+
+// unregister drops the marshaler and the codecs of codecType.
+func unregister(codecType CODEC_TYPE) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.marshalers, codecType)
+	for key := range registry.codecs {
+		if key.codec == codecType {
+			delete(registry.codecs, key)
+		}
+	}
+}
+
+// Lookup returns the codec of T registered for codecType.
+func Lookup[T any](codecType CODEC_TYPE) (Codec[T], error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	if c, ok := registry.codecs[codecKey{codecType, typeOf[T]()}]; ok {
+		return c.(Codec[T]), nil
+	}
+	if m, ok := registry.marshalers[codecType]; ok {
+		return &marshalerCodec[T]{m}, nil
+	}
+	return nil, fmt.Errorf("unknown codec type: %v for %v", codecType, typeOf[T]())
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+type marshalerCodec[T any] struct {
+	m Marshaler
+}
+
+func (c *marshalerCodec[T]) Encode(t T) ([]byte, error) {
+	return c.m.Marshal(t)
+}
+
+func (c *marshalerCodec[T]) Decode(data []byte) (T, error) {
 	var t T
-	err := json.Unmarshal(data, &t)
+	err := c.m.Unmarshal(data, &t)
 	return t, err
 }
 
+type jsonMarshaler struct {
+}
+
+func (jsonMarshaler) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonMarshaler) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// NewCodec panics when nothing encodes T for codec, use Lookup to get the
+// error instead.
 func NewCodec[T any](codec CODEC_TYPE) Codec[T] {
-	switch codec {
-	case JSON_CODEC:
-		return &jsonCodec[T]{}
-	default:
-		panic(fmt.Sprintf("unknown codec type: %v", codec))
+	c, err := Lookup[T](codec)
+	if err != nil {
+		panic(err.Error())
 	}
+	return c
 }
@@ -0,0 +1,142 @@
+package codec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type position struct {
+	X, Y int
+	Name string
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	for _, codecType := range []CODEC_TYPE{JSON_CODEC, MSGPACK_CODEC, GOB_CODEC} {
+		c := NewCodec[position](codecType)
+		data, err := c.Encode(position{X: 3, Y: -4, Name: "cursor"})
+		assert.NoError(t, err)
+		decoded, err := c.Decode(data)
+		assert.NoError(t, err, "codec %d", codecType)
+		assert.Equal(t, position{X: 3, Y: -4, Name: "cursor"}, decoded, "codec %d", codecType)
+	}
+}
+
+func TestCodec_Op(t *testing.T) {
+	op := Op{Type: Op_MouseEvent, Ts: 1700000000, Version: 2, Data: map[string]interface{}{"X": "left", "Pressed": true}}
+	for _, codecType := range []CODEC_TYPE{JSON_CODEC, MSGPACK_CODEC, GOB_CODEC, BINARY_CODEC} {
+		c := NewCodec[Op](codecType)
+		data, err := c.Encode(op)
+		assert.NoError(t, err)
+		decoded, err := c.Decode(data)
+		assert.NoError(t, err, "codec %d", codecType)
+		assert.Equal(t, op, decoded, "codec %d", codecType)
+	}
+}
+
+func TestBinaryCodec(t *testing.T) {
+	c := NewCodec[Op](BINARY_CODEC)
+	op := Op{Type: Op_Sync_Data, Ts: -1, Data: map[string]interface{}{
+		"int":   int16(-300),
+		"uint":  uint16(65535),
+		"float": float32(1.5),
+		"bytes": []byte{1, 2},
+		"nil":   nil,
+		"list":  []interface{}{"a", int64(1), false},
+		"map":   map[string]interface{}{"inner": 2.25},
+		"":      "empty key",
+	}}
+	data, err := c.Encode(op)
+	assert.NoError(t, err)
+	decoded, err := c.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, Op{Type: Op_Sync_Data, Ts: -1, Data: map[string]interface{}{
+		"int":   int64(-300),
+		"uint":  uint64(65535),
+		"float": 1.5,
+		"bytes": []byte{1, 2},
+		"nil":   nil,
+		"list":  []interface{}{"a", int64(1), false},
+		"map":   map[string]interface{}{"inner": 2.25},
+		"":      "empty key",
+	}}, decoded)
+
+	again, _ := c.Encode(op)
+	assert.Equal(t, data, again, "keys are sorted")
+
+	empty, err := c.Decode([]byte{0x02, 0x00, 0x00, 0x00})
+	assert.NoError(t, err)
+	assert.Equal(t, Op{Type: 1}, empty)
+
+	_, err = c.Encode(Op{Data: map[string]interface{}{"bad": struct{}{}}})
+	assert.Error(t, err)
+	// 每个截断位置都应返回错误而不是 panic
+	for i := 0; i < len(data); i++ {
+		_, err := c.Decode(data[:i])
+		assert.Error(t, err, "truncated at %d", i)
+	}
+	_, err = c.Decode(append(data, 0))
+	assert.Error(t, err)
+}
+
+func TestBinaryCodec_Smaller(t *testing.T) {
+	op := MakeOp(Op_MouseEvent, map[string]interface{}{"X": int16(1280), "Y": int16(720)})
+	binaryData, err := NewCodec[Op](BINARY_CODEC).Encode(op)
+	assert.NoError(t, err)
+	jsonData, err := NewCodec[Op](JSON_CODEC).Encode(op)
+	assert.NoError(t, err)
+	assert.Less(t, len(binaryData)*2, len(jsonData))
+}
+
+type upperCodec struct {
+}
+
+func (upperCodec) Encode(s string) ([]byte, error) {
+	return []byte("<" + s + ">"), nil
+}
+
+func (upperCodec) Decode(data []byte) (string, error) {
+	if len(data) < 2 {
+		return "", errors.New("short")
+	}
+	return string(data[1 : len(data)-1]), nil
+}
+
+type prefixMarshaler struct {
+}
+
+func (prefixMarshaler) Marshal(v any) ([]byte, error) {
+	data, err := jsonMarshaler{}.Marshal(v)
+	return append([]byte{'#'}, data...), err
+}
+
+func (prefixMarshaler) Unmarshal(data []byte, v any) error {
+	return jsonMarshaler{}.Unmarshal(data[1:], v)
+}
+
+func TestCodec_Register(t *testing.T) {
+	const custom CODEC_TYPE = 200
+	_, err := Lookup[string](custom)
+	assert.Error(t, err)
+	assert.Panics(t, func() { NewCodec[string](custom) })
+	_, err = Lookup[position](BINARY_CODEC)
+	assert.Error(t, err, "the binary codec only knows Op")
+
+	t.Cleanup(func() { unregister(custom) })
+	Register[string](custom, upperCodec{})
+	c, err := Lookup[string](custom)
+	assert.NoError(t, err)
+	data, _ := c.Encode("hi")
+	assert.Equal(t, "<hi>", string(data))
+	_, err = Lookup[int](custom)
+	assert.Error(t, err)
+
+	RegisterMarshaler(custom, prefixMarshaler{})
+	data, err = NewCodec[position](custom).Encode(position{X: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, byte('#'), data[0])
+	// 为类型注册的 codec 优先于 marshaler
+	data, _ = NewCodec[string](custom).Encode("hi")
+	assert.Equal(t, "<hi>", string(data))
+}
@@ -0,0 +1,24 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// gobMarshaler writes the type description with every value, so each
+// message decodes on its own. Concrete types held in interfaces, like the
+// values of Op.Data, need gob.Register unless they are builtin.
+type gobMarshaler struct {
+}
+
+func (gobMarshaler) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobMarshaler) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
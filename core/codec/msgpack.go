@@ -0,0 +1,16 @@
+package codec
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type msgpackMarshaler struct {
+}
+
+func (msgpackMarshaler) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackMarshaler) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
@@ -19,7 +19,9 @@ const (
 type Op struct {
 	Type OpType
 	Ts   int64
-	Data map[string]interface{}
+	// Version is the version of the payload in Data, 0 for ops made without one
+	Version int `json:",omitempty" msgpack:",omitempty"`
+	Data    map[string]interface{}
 }
 
 func MakeOp(op OpType, data map[string]interface{}) Op {
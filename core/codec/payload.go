@@ -0,0 +1,351 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JsonSchemaDialect is the $schema of the documents PayloadSchema returns.
+const JsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// payloadField is an exported field of a payload struct. It is named by its
+// json tag and described by its op tag:
+//
+//	X float64 `json:"x" op:"required,since=2,deprecated=3"`
+//
+// required fails ops without the field, since is the payload version that
+// added it and deprecated the version that stopped using it. From deprecated
+// on the field is no longer required but still decoded for old senders.
+type payloadField struct {
+	index      int
+	name       string
+	required   bool
+	since      int
+	deprecated int
+}
+
+type payloadSpec struct {
+	opType  OpType
+	version int
+	t       reflect.Type
+	fields  []payloadField
+}
+
+var payloads = struct {
+	sync.RWMutex
+	specs map[OpType]*payloadSpec
+}{specs: make(map[OpType]*payloadSpec)}
+
+// PayloadError is an op whose Data does not fit the payload of its type.
+type PayloadError struct {
+	Type   OpType
+	Field  string
+	Reason string
+}
+
+func (e *PayloadError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("payload of op %d: %s", e.Type, e.Reason)
+	}
+	return fmt.Sprintf("payload of op %d: field %s: %s", e.Type, e.Field, e.Reason)
+}
+
+// RegisterPayload makes P the Data of ops of opType, version is the current
+// version of P. Like gob.Register it panics on a payload it can not use, so
+// call it from init.
+func RegisterPayload[P any](opType OpType, version int) {
+	t := typeOf[P]()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("payload of op %d is not a struct: %v", opType, t))
+	}
+	if version < 1 {
+		panic(fmt.Sprintf("payload of op %d has version %d, versions start at 1", opType, version))
+	}
+	spec := &payloadSpec{opType: opType, version: version, t: t}
+	for i := 0; i < t.NumField(); i++ {
+		field, ok, err := parsePayloadField(t.Field(i), i)
+		if err != nil {
+			panic(fmt.Sprintf("payload of op %d: %v", opType, err))
+		}
+		if !ok {
+			continue
+		}
+		if field.since > version || field.deprecated > version {
+			panic(fmt.Sprintf("payload of op %d: field %s is from a version after %d", opType, field.name, version))
+		}
+		spec.fields = append(spec.fields, field)
+	}
+	payloads.Lock()
+	defer payloads.Unlock()
+	payloads.specs[opType] = spec
+}
+
+func parsePayloadField(f reflect.StructField, index int) (payloadField, bool, error) {
+	field := payloadField{index: index, name: f.Name, since: 1}
+	if !f.IsExported() {
+		return field, false, nil
+	}
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name == "-" {
+		return field, false, nil
+	} else if name != "" {
+		field.name = name
+	}
+	tag := f.Tag.Get("op")
+	if tag == "" {
+		return field, true, nil
+	}
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "required":
+			field.required = true
+		case "since", "deprecated":
+			v, err := strconv.Atoi(value)
+			if err != nil || v < 1 {
+				return field, false, fmt.Errorf("field %s: bad %s version %q", field.name, key, value)
+			}
+			if key == "since" {
+				field.since = v
+			} else {
+				field.deprecated = v
+			}
+		default:
+			return field, false, fmt.Errorf("field %s: unknown op tag option %q", field.name, option)
+		}
+	}
+	if field.deprecated != 0 && field.deprecated <= field.since {
+		return field, false, fmt.Errorf("field %s: deprecated before it was added", field.name)
+	}
+	return field, true, nil
+}
+
+// requiredAt reports whether ops of version must carry the field.
+func (f payloadField) requiredAt(version int) bool {
+	return f.required && f.since <= version && (f.deprecated == 0 || version < f.deprecated)
+}
+
+func lookupPayload(opType OpType) (*payloadSpec, error) {
+	payloads.RLock()
+	defer payloads.RUnlock()
+	spec, ok := payloads.specs[opType]
+	if !ok {
+		return nil, &PayloadError{Type: opType, Reason: "no payload registered"}
+	}
+	return spec, nil
+}
+
+// Payload decodes the Data of op into P, the payload registered for its type.
+func Payload[P any](op Op) (P, error) {
+	var p P
+	spec, err := lookupPayload(op.Type)
+	if err != nil {
+		return p, err
+	}
+	if spec.t != typeOf[P]() {
+		return p, &PayloadError{Type: op.Type, Reason: fmt.Sprintf("registered as %v, not %v", spec.t, typeOf[P]())}
+	}
+	err = spec.decode(op, &p)
+	return p, err
+}
+
+// DecodePayload decodes the Data of op into the payload registered for its
+// type, handlers switch on the type of the result.
+func DecodePayload(op Op) (any, error) {
+	spec, err := lookupPayload(op.Type)
+	if err != nil {
+		return nil, err
+	}
+	p := reflect.New(spec.t)
+	if err := spec.decode(op, p.Interface()); err != nil {
+		return nil, err
+	}
+	return p.Elem().Interface(), nil
+}
+
+// decode checks the required fields of the version of op and fills p.
+// Ops of version 0 predate versioning and are read as version 1, fields
+// unknown to the payload are ignored so newer senders can add them.
+func (s *payloadSpec) decode(op Op, p any) error {
+	version := op.Version
+	if version == 0 {
+		version = 1
+	}
+	for _, field := range s.fields {
+		if !field.requiredAt(version) {
+			continue
+		}
+		if v, ok := op.Data[field.name]; !ok || v == nil {
+			return &PayloadError{Type: op.Type, Field: field.name, Reason: "required"}
+		}
+	}
+	data, err := json.Marshal(op.Data)
+	if err != nil {
+		return &PayloadError{Type: op.Type, Reason: err.Error()}
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return &PayloadError{Type: op.Type, Field: typeErr.Field, Reason: fmt.Sprintf("%s does not fit %v", typeErr.Value, typeErr.Type)}
+		}
+		return &PayloadError{Type: op.Type, Reason: err.Error()}
+	}
+	return nil
+}
+
+// MakePayloadOp is MakeOp for a registered payload, the op carries the
+// current version of the payload.
+func MakePayloadOp[P any](opType OpType, payload P) (Op, error) {
+	spec, err := lookupPayload(opType)
+	if err != nil {
+		return Op{}, err
+	}
+	if spec.t != typeOf[P]() {
+		return Op{}, &PayloadError{Type: opType, Reason: fmt.Sprintf("registered as %v, not %v", spec.t, typeOf[P]())}
+	}
+	v := reflect.ValueOf(payload)
+	data := make(map[string]interface{}, len(spec.fields))
+	for _, field := range spec.fields {
+		value, ok, err := payloadValue(v.Field(field.index))
+		if err != nil {
+			return Op{}, &PayloadError{Type: opType, Field: field.name, Reason: err.Error()}
+		}
+		if ok {
+			data[field.name] = value
+		}
+	}
+	return Op{Type: opType, Ts: time.Now().Unix(), Version: spec.version, Data: data}, nil
+}
+
+// payloadValue keeps scalars as they are so compact codecs keep their size,
+// anything else goes through json. Nil pointers are left out.
+func payloadValue(v reflect.Value) (interface{}, bool, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, false, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return v.Interface(), true, nil
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, false, err
+	}
+	var value interface{}
+	err = json.Unmarshal(data, &value)
+	return value, err == nil, err
+}
+
+// PayloadSchema describes the current version of the payload of opType as a
+// JSON Schema. Fields carry x-since and deprecated, the op type and version
+// are in x-op-type and x-version.
+func PayloadSchema(opType OpType) (map[string]interface{}, error) {
+	spec, err := lookupPayload(opType)
+	if err != nil {
+		return nil, err
+	}
+	schema := map[string]interface{}{
+		"$schema":   JsonSchemaDialect,
+		"$id":       fmt.Sprintf("op/%d", opType),
+		"title":     spec.t.Name(),
+		"x-op-type": int(opType),
+		"x-version": spec.version,
+	}
+	for k, v := range structSchema(spec.t, spec.version) {
+		schema[k] = v
+	}
+	return schema, nil
+}
+
+// PayloadSchemas returns the schema of every registered payload keyed by its
+// op type.
+func PayloadSchemas() map[OpType]map[string]interface{} {
+	payloads.RLock()
+	types := make([]OpType, 0, len(payloads.specs))
+	for opType := range payloads.specs {
+		types = append(types, opType)
+	}
+	payloads.RUnlock()
+	schemas := make(map[OpType]map[string]interface{}, len(types))
+	for _, opType := range types {
+		if schema, err := PayloadSchema(opType); err == nil {
+			schemas[opType] = schema
+		}
+	}
+	return schemas
+}
+
+// structSchema describes t at version, nested structs use their own op tags
+// at the same version.
+func structSchema(t reflect.Type, version int) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field, ok, err := parsePayloadField(t.Field(i), i)
+		if err != nil || !ok {
+			continue
+		}
+		property := typeSchema(t.Field(i).Type, version)
+		if field.since > 1 {
+			property["x-since"] = field.since
+		}
+		if field.deprecated != 0 && field.deprecated <= version {
+			property["deprecated"] = true
+		}
+		properties[field.name] = property
+		if field.requiredAt(version) {
+			required = append(required, field.name)
+		}
+	}
+	sort.Strings(required)
+	return map[string]interface{}{"type": "object", "properties": properties, "required": required}
+}
+
+func typeSchema(t reflect.Type, version int) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), version)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		bits := t.Bits()
+		return map[string]interface{}{"type": "integer", "minimum": -int64(1) << (bits - 1), "maximum": int64(1)<<(bits-1) - 1}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": uint64(math.MaxUint64) >> (64 - t.Bits())}
+	case reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), version)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), version)}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		return structSchema(t, version)
+	default:
+		return map[string]interface{}{}
+	}
+}
@@ -0,0 +1,165 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const opTestMove OpType = 900
+
+// testMove 的第 2 版新增 Speed，第 3 版弃用 Dir
+type testMove struct {
+	X     int     `json:"x" op:"required"`
+	Dir   string  `json:"dir" op:"required,deprecated=3"`
+	Speed float64 `json:"speed" op:"required,since=2"`
+	Tags  []string
+	Note  *string `json:"note,omitempty"`
+	skip  int
+}
+
+const opTestPointer OpType = 904
+
+// testPointer 用来检查整数的取值范围
+type testPointer struct {
+	X       int16 `op:"required"`
+	Y       int16 `op:"required"`
+	RawCode uint16
+}
+
+func init() {
+	RegisterPayload[testMove](opTestMove, 3)
+	RegisterPayload[testPointer](opTestPointer, 1)
+}
+
+func TestPayload_Decode(t *testing.T) {
+	op := Op{Type: opTestMove, Version: 3, Data: map[string]interface{}{"x": 3.0, "speed": 1.5, "Tags": []interface{}{"a"}, "extra": true}}
+	move, err := Payload[testMove](op)
+	assert.NoError(t, err)
+	assert.Equal(t, testMove{X: 3, Speed: 1.5, Tags: []string{"a"}}, move)
+
+	decoded, err := DecodePayload(op)
+	assert.NoError(t, err)
+	assert.Equal(t, move, decoded)
+
+	_, err = Payload[testPointer](op)
+	assert.Error(t, err)
+	_, err = DecodePayload(Op{Type: 901})
+	assert.Error(t, err)
+}
+
+func TestPayload_Validate(t *testing.T) {
+	var payloadErr *PayloadError
+	_, err := Payload[testMove](Op{Type: opTestMove, Version: 3, Data: map[string]interface{}{"x": 1}})
+	if assert.True(t, errors.As(err, &payloadErr)) {
+		assert.Equal(t, "speed", payloadErr.Field)
+		assert.Equal(t, "required", payloadErr.Reason)
+	}
+	_, err = Payload[testMove](Op{Type: opTestMove, Version: 3, Data: map[string]interface{}{"x": nil, "speed": 1}})
+	assert.True(t, errors.As(err, &payloadErr))
+	assert.Equal(t, "x", payloadErr.Field)
+
+	_, err = Payload[testMove](Op{Type: opTestMove, Version: 3, Data: map[string]interface{}{"x": "left", "speed": 1}})
+	if assert.True(t, errors.As(err, &payloadErr)) {
+		assert.Equal(t, "x", payloadErr.Field)
+	}
+	_, err = Payload[testMove](Op{Type: opTestMove, Version: 3, Data: map[string]interface{}{"x": 1.5, "speed": 1}})
+	assert.Error(t, err, "a fraction does not fit an int")
+	_, err = Payload[testPointer](Op{Type: opTestPointer, Data: map[string]interface{}{"X": 40000, "Y": 1}})
+	assert.Error(t, err, "40000 does not fit an int16")
+}
+
+func TestPayload_Versions(t *testing.T) {
+	// 版本 0 和 1 的 op 没有 speed，但必须带 dir
+	for _, version := range []int{0, 1} {
+		move, err := Payload[testMove](Op{Type: opTestMove, Version: version, Data: map[string]interface{}{"x": 1, "dir": "up"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "up", move.Dir)
+		_, err = Payload[testMove](Op{Type: opTestMove, Version: version, Data: map[string]interface{}{"x": 1}})
+		assert.Error(t, err)
+	}
+	_, err := Payload[testMove](Op{Type: opTestMove, Version: 2, Data: map[string]interface{}{"x": 1, "dir": "up"}})
+	assert.Error(t, err, "speed is required from version 2")
+	move, err := Payload[testMove](Op{Type: opTestMove, Version: 4, Data: map[string]interface{}{"x": 1, "speed": 2, "dir": "up"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "up", move.Dir, "deprecated fields are still decoded")
+}
+
+func TestPayload_MakeOp(t *testing.T) {
+	note := "fast"
+	op, err := MakePayloadOp(opTestMove, testMove{X: 2, Speed: 0.5, Tags: []string{"a"}, Note: &note})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, op.Version)
+	assert.Equal(t, map[string]interface{}{"x": 2, "dir": "", "speed": 0.5, "Tags": []interface{}{"a"}, "note": "fast"}, op.Data)
+
+	for _, codecType := range []CODEC_TYPE{JSON_CODEC, MSGPACK_CODEC, BINARY_CODEC} {
+		c := NewCodec[Op](codecType)
+		data, err := c.Encode(op)
+		assert.NoError(t, err)
+		decoded, err := c.Decode(data)
+		assert.NoError(t, err)
+		move, err := Payload[testMove](decoded)
+		assert.NoError(t, err, "codec %d", codecType)
+		assert.Equal(t, testMove{X: 2, Speed: 0.5, Tags: []string{"a"}, Note: &note}, move, "codec %d", codecType)
+	}
+
+	_, err = MakePayloadOp(opTestMove, testPointer{})
+	assert.Error(t, err)
+	pointer, err := MakePayloadOp(opTestPointer, testPointer{X: 1280, Y: 720})
+	assert.NoError(t, err)
+	assert.Equal(t, int16(1280), pointer.Data["X"])
+}
+
+func TestPayload_Register(t *testing.T) {
+	assert.Panics(t, func() { RegisterPayload[int](902, 1) })
+	assert.Panics(t, func() { RegisterPayload[testMove](902, 2) }, "speed is from version 2 but dir is deprecated in 3")
+	assert.Panics(t, func() {
+		RegisterPayload[struct {
+			X int `op:"optional"`
+		}](902, 1)
+	})
+	assert.Panics(t, func() {
+		RegisterPayload[struct {
+			X int `op:"since=2,deprecated=2"`
+		}](902, 2)
+	})
+	_, err := DecodePayload(Op{Type: 902})
+	assert.Error(t, err)
+}
+
+func TestPayloadSchema(t *testing.T) {
+	schema, err := PayloadSchema(opTestMove)
+	assert.NoError(t, err)
+	data, err := json.Marshal(schema)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id": "op/900",
+		"title": "testMove",
+		"x-op-type": 900,
+		"x-version": 3,
+		"type": "object",
+		"properties": {
+			"x": {"type": "integer"},
+			"dir": {"type": "string", "deprecated": true},
+			"speed": {"type": "number", "x-since": 2},
+			"Tags": {"type": "array", "items": {"type": "string"}},
+			"note": {"type": "string"}
+		},
+		"required": ["speed", "x"]
+	}`, string(data))
+
+	pointer, err := PayloadSchema(opTestPointer)
+	assert.NoError(t, err)
+	properties := pointer["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "integer", "minimum": int64(-32768), "maximum": int64(32767)}, properties["X"])
+	assert.Equal(t, map[string]interface{}{"type": "integer", "minimum": 0, "maximum": uint64(65535)}, properties["RawCode"])
+
+	schemas := PayloadSchemas()
+	assert.Contains(t, schemas, opTestPointer)
+	assert.Contains(t, schemas, opTestMove)
+	_, err = PayloadSchema(903)
+	assert.Error(t, err)
+}
@@ -19,6 +19,19 @@ const (
 	ERROR_CODE_MAILBOX_RECEIVE_ERROR
 	ERROR_TYPE_CORE
 	ERROR_TYPE_BUSINESS
+	// appended after ERROR_TYPE_* so their values stay the same
+	ERROR_CODE_ACTOR_PANIC
+	ERROR_CODE_SUPERVISOR_RESTART_LIMIT
+	ERROR_CODE_SUPERVISOR_ESCALATED
+	ERROR_CODE_ASK_TIMEOUT
+	ERROR_CODE_CHANNEL_CONNECT_ERROR
+	ERROR_CODE_CHANNEL_LISTEN_ERROR
+	ERROR_CODE_NAME_INVALID
+	ERROR_CODE_NAME_NOT_FOUND
+	ERROR_CODE_PERSISTENCE_ERROR
+	ERROR_CODE_STASH_ERROR
+	ERROR_CODE_ASK_CORRELATION_MISMATCH
+	ERROR_CODE_POSTMAN_ID_MISSING
 )
 
 type CoreError struct {
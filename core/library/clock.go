@@ -0,0 +1,125 @@
+package library
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time a TimingWheel runs on.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) ClockTicker
+}
+
+type ClockTicker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is the wall clock.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) ClockTicker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// VirtualClock only moves on Advance, so tests control when timers fire.
+// Its tickers do not drop ticks, every tick waits until it is taken or the
+// ticker is stopped.
+type VirtualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers map[*virtualTicker]struct{}
+}
+
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start, tickers: make(map[*virtualTicker]struct{})}
+}
+
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *VirtualClock) NewTicker(d time.Duration) ClockTicker {
+	if d <= 0 {
+		panic("non-positive interval for VirtualClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &virtualTicker{clock: c, c: make(chan time.Time), interval: d, next: c.now.Add(d), stopped: make(chan struct{})}
+	c.tickers[t] = struct{}{}
+	return t
+}
+
+// Advance moves the clock by d and delivers the ticks due on the way, in
+// order. It returns once every tick was taken, a TimingWheel has then run
+// the callbacks of all but the last tick.
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		var due *virtualTicker
+		for t := range c.tickers {
+			if !t.next.After(target) && (due == nil || t.next.Before(due.next)) {
+				due = t
+			}
+		}
+		if due == nil {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		c.now = due.next
+		due.next = due.next.Add(due.interval)
+		now := c.now
+		c.mu.Unlock()
+		select {
+		case due.c <- now:
+		case <-due.stopped:
+		}
+	}
+}
+
+type virtualTicker struct {
+	clock    *VirtualClock
+	c        chan time.Time
+	interval time.Duration
+	next     time.Time
+	stopped  chan struct{}
+	once     sync.Once
+}
+
+func (t *virtualTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *virtualTicker) Stop() {
+	t.once.Do(func() {
+		t.clock.mu.Lock()
+		delete(t.clock.tickers, t)
+		t.clock.mu.Unlock()
+		close(t.stopped)
+	})
+}
@@ -0,0 +1,59 @@
+package library
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualClock_Advance(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewVirtualClock(start)
+	fast := clock.NewTicker(10 * time.Millisecond)
+	slow := clock.NewTicker(25 * time.Millisecond)
+	defer fast.Stop()
+	var ticks []time.Duration
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for len(ticks) < 5 {
+			select {
+			case now := <-fast.C():
+				ticks = append(ticks, now.Sub(start))
+			case now := <-slow.C():
+				ticks = append(ticks, now.Sub(start))
+			}
+		}
+	}()
+	clock.Advance(40 * time.Millisecond)
+	<-done
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond, 30 * time.Millisecond, 40 * time.Millisecond}, ticks)
+	assert.Equal(t, start.Add(40*time.Millisecond), clock.Now())
+
+	// a stopped ticker does not hold Advance up
+	slow.Stop()
+	fast.Stop()
+	clock.Advance(time.Second)
+}
+
+func TestTimingWheel_VirtualClock(t *testing.T) {
+	clock := NewVirtualClock(time.Now())
+	w := NewTimingWheelWithClock(10*time.Millisecond, 8, clock)
+	w.Start()
+	defer w.Stop()
+	var fired int32
+	w.EveryFunc(20*time.Millisecond, func() { atomic.AddInt32(&fired, 1) })
+
+	clock.Advance(50 * time.Millisecond)
+	// 最后一个 tick 的回调可能还在执行
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&fired) == 2
+	}, time.Second, time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&fired) == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fired))
+}
@@ -0,0 +1,162 @@
+package library
+
+import (
+	"sync"
+	"time"
+)
+
+// TimingWheel is a hashed timing wheel. Timers are kept in slots of one tick
+// each, so any number of timers is driven by a single time.Ticker. A timer
+// fires on the first tick at or after its delay, callbacks run on the wheel
+// goroutine and must not block.
+type TimingWheel struct {
+	tick  time.Duration
+	slots []map[*WheelTimer]struct{}
+	clock Clock
+
+	mu      sync.Mutex
+	cursor  int
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type WheelTimer struct {
+	wheel    *TimingWheel
+	slot     int
+	rounds   int
+	interval time.Duration
+	fn       func()
+}
+
+func NewTimingWheel(tick time.Duration, size int) *TimingWheel {
+	return NewTimingWheelWithClock(tick, size, SystemClock)
+}
+
+// NewTimingWheelWithClock ticks on clock, a VirtualClock makes the wheel
+// deterministic.
+func NewTimingWheelWithClock(tick time.Duration, size int, clock Clock) *TimingWheel {
+	if tick <= 0 {
+		tick = time.Millisecond
+	}
+	if size <= 0 {
+		size = 512
+	}
+	slots := make([]map[*WheelTimer]struct{}, size)
+	for i := range slots {
+		slots[i] = make(map[*WheelTimer]struct{})
+	}
+	return &TimingWheel{tick: tick, slots: slots, clock: clock}
+}
+
+func (w *TimingWheel) Tick() time.Duration {
+	return w.tick
+}
+
+// Start runs the wheel until Stop, starting a running wheel does nothing.
+func (w *TimingWheel) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running {
+		return
+	}
+	w.running = true
+	w.stop, w.done = make(chan struct{}), make(chan struct{})
+	// created here, a VirtualClock advanced right after Start sees the ticker
+	go w.run(w.clock.NewTicker(w.tick), w.stop, w.done)
+}
+
+// Stop halts the wheel, pending timers stay and fire once it is started again.
+func (w *TimingWheel) Stop() {
+	w.mu.Lock()
+	if !w.running {
+		w.mu.Unlock()
+		return
+	}
+	w.running = false
+	stop, done := w.stop, w.done
+	w.mu.Unlock()
+	close(stop)
+	<-done
+}
+
+// AfterFunc calls fn once after delay.
+func (w *TimingWheel) AfterFunc(delay time.Duration, fn func()) *WheelTimer {
+	return w.schedule(&WheelTimer{wheel: w, fn: fn}, delay)
+}
+
+// EveryFunc calls fn every interval until the timer is stopped.
+func (w *TimingWheel) EveryFunc(interval time.Duration, fn func()) *WheelTimer {
+	return w.schedule(&WheelTimer{wheel: w, fn: fn, interval: interval}, interval)
+}
+
+// Stop cancels the timer, it returns false when there was nothing to cancel.
+func (t *WheelTimer) Stop() bool {
+	w := t.wheel
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.slots[t.slot][t]; !ok {
+		return false
+	}
+	delete(w.slots[t.slot], t)
+	return true
+}
+
+func (w *TimingWheel) schedule(t *WheelTimer, delay time.Duration) *WheelTimer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.place(t, delay)
+	return t
+}
+
+// place puts the timer ticks slots ahead of the cursor, the caller holds mu.
+func (w *TimingWheel) place(t *WheelTimer, delay time.Duration) {
+	ticks := int((delay + w.tick - 1) / w.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+	size := len(w.slots)
+	t.slot = (w.cursor + ticks) % size
+	t.rounds = (ticks - 1) / size
+	w.slots[t.slot][t] = struct{}{}
+}
+
+func (w *TimingWheel) run(ticker ClockTicker, stop, done chan struct{}) {
+	defer close(done)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			for _, fn := range w.advance() {
+				fn()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// advance moves the cursor one slot and returns the callbacks that are due.
+func (w *TimingWheel) advance() []func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.cursor = (w.cursor + 1) % len(w.slots)
+	var due []func()
+	var periodic []*WheelTimer
+	for t := range w.slots[w.cursor] {
+		if t.rounds > 0 {
+			t.rounds--
+			continue
+		}
+		delete(w.slots[w.cursor], t)
+		due = append(due, t.fn)
+		if t.interval > 0 {
+			periodic = append(periodic, t)
+		}
+	}
+	// placed after the loop, an interval of a whole turn lands in this slot again
+	for _, t := range periodic {
+		w.place(t, t.interval)
+	}
+	return due
+}
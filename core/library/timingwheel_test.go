@@ -0,0 +1,63 @@
+package library
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// turn advances the wheel by hand and runs the due callbacks.
+func turn(w *TimingWheel, ticks int) {
+	for i := 0; i < ticks; i++ {
+		for _, fn := range w.advance() {
+			fn()
+		}
+	}
+}
+
+func TestTimingWheel_AfterFunc(t *testing.T) {
+	w := NewTimingWheel(10*time.Millisecond, 4)
+	fired := map[string]int{}
+	w.AfterFunc(10*time.Millisecond, func() { fired["next"]++ })
+	w.AfterFunc(40*time.Millisecond, func() { fired["turn"]++ })
+	w.AfterFunc(95*time.Millisecond, func() { fired["rounds"]++ })
+
+	turn(w, 1)
+	assert.Equal(t, map[string]int{"next": 1}, fired)
+	turn(w, 3)
+	assert.Equal(t, map[string]int{"next": 1, "turn": 1}, fired)
+	turn(w, 5)
+	assert.Equal(t, 0, fired["rounds"], "95ms rounds up to 10 ticks")
+	turn(w, 1)
+	assert.Equal(t, 1, fired["rounds"])
+	turn(w, 20)
+	assert.Equal(t, map[string]int{"next": 1, "turn": 1, "rounds": 1}, fired)
+}
+
+func TestTimingWheel_EveryFuncAndStop(t *testing.T) {
+	w := NewTimingWheel(10*time.Millisecond, 4)
+	count := 0
+	timer := w.EveryFunc(40*time.Millisecond, func() { count++ })
+	turn(w, 12)
+	assert.Equal(t, 3, count, "an interval of a whole turn fires once per turn")
+	assert.True(t, timer.Stop())
+	assert.False(t, timer.Stop())
+	turn(w, 12)
+	assert.Equal(t, 3, count)
+}
+
+func TestTimingWheel_Start(t *testing.T) {
+	w := NewTimingWheel(time.Millisecond, 64)
+	w.Start()
+	defer w.Stop()
+	fired := make(chan time.Time, 1)
+	start := time.Now()
+	w.AfterFunc(20*time.Millisecond, func() { fired <- time.Now() })
+	select {
+	case at := <-fired:
+		assert.GreaterOrEqual(t, at.Sub(start), 20*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("timer should fire")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+
+	"github.com/shooyaaa/core/actor"
 )
 
 type HttpHandler func(http.ResponseWriter, *http.Request)
@@ -18,6 +20,13 @@ func (hs *HttpServer) Register(key string, handler HttpHandler) {
 	hs.Handler[key] = handler
 }
 
+// RegisterMetrics serves the metrics at actor.MetricsPath and the live actors
+// at actor.ActorsPath.
+func (hs *HttpServer) RegisterMetrics(metrics actor.Metrics) {
+	hs.Register(actor.MetricsPath, metrics.ServeHTTP)
+	hs.Register(actor.ActorsPath, metrics.ServeActors)
+}
+
 func (hs *HttpServer) Info(w http.ResponseWriter, r *http.Request) {
 	data, err := json.Marshal(map[string]string{"Addr": hs.Addr})
 	if err != nil {
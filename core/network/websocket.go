@@ -1,8 +1,12 @@
 package network
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"github.com/shooyaaa/core/session"
 	types2 "github.com/shooyaaa/core/types"
+	"io"
 	"log"
 	"net/http"
 	"time"
@@ -10,21 +14,51 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// wsReadSize is what a message buffer starts with, it grows with the message.
+const wsReadSize = 512
+
 type Ws struct {
 	Id        types2.UUID
 	HeartBeat time.Duration
 	Root      string
 	waitChan  chan *session.Session
 	server    HttpServer
+	// MaxFrame bounds a message, session.DefaultMaxFrame when <= 0
+	MaxFrame int
 }
 
 type WsConn struct {
 	conn *websocket.Conn
 }
 
+// ReadMessage returns the next websocket message. It is read through a
+// buffer growing with the message, a message past the read limit fails with
+// session.ErrFrameTooLarge before the rest of it is read.
+func (wc WsConn) ReadMessage() ([]byte, error) {
+	_, reader, err := wc.conn.NextReader()
+	if err == nil {
+		buffer := bytes.NewBuffer(make([]byte, 0, wsReadSize))
+		if _, err = buffer.ReadFrom(reader); err == nil {
+			return buffer.Bytes(), nil
+		}
+	}
+	// a frame header past the limit already fails NextReader
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return nil, fmt.Errorf("%w: %v", session.ErrFrameTooLarge, err)
+	}
+	return nil, err
+}
+
+// Read returns one websocket message, io.ErrShortBuffer when it does not fit.
 func (wc WsConn) Read(buffer []byte) (int, error) {
-	count, buffer, err := wc.conn.ReadMessage()
-	return count, err
+	message, err := wc.ReadMessage()
+	if err != nil {
+		return 0, err
+	}
+	if len(message) > len(buffer) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(buffer, message), nil
 }
 
 func (wc WsConn) Write(bytes []byte) (int, error) {
@@ -52,14 +86,20 @@ func (ws *Ws) Listen(addr string) error {
 func (ws *Ws) Connect(w http.ResponseWriter, r *http.Request) {
 	upgrader := websocket.Upgrader{}
 	conn, err := upgrader.Upgrade(w, r, nil)
-	session := session.Session{
-		Id:   ws.Id.NewUUID(),
-		Conn: WsConn{conn: conn},
-	}
 	if err != nil {
 		log.Print("Upgrade websocket fail :", err)
 		return
 	}
+	maxFrame := ws.MaxFrame
+	if maxFrame <= 0 {
+		maxFrame = session.DefaultMaxFrame
+	}
+	conn.SetReadLimit(int64(maxFrame))
+	session := session.Session{
+		Id:     ws.Id.NewUUID(),
+		Conn:   WsConn{conn: conn},
+		Framer: session.NewMessageFramer(maxFrame),
+	}
 	log.Printf("Incoming Session %d", session.Id)
 	ws.waitChan <- &session
 	//go ws.Commuicate(&session)
@@ -1,6 +1,11 @@
 package router
 
 import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/shooyaaa/core/actor"
 	"github.com/shooyaaa/core/codec"
 )
 
@@ -9,17 +14,95 @@ type PackageHandler interface {
 }
 
 type Header struct {
+	epoch int64
 	seq   int64
 	ack   int64
 	len   int
 	codec codec.CODEC_TYPE
 }
 
+// headerSize is the encoded size of a Header: epoch, seq, ack, codec and len.
+const headerSize = 8 + 8 + 8 + 1 + 4
+
+// NewHeader numbers a package the way actor.ReliableDelivery numbers mail,
+// seq counts the packages of a sender within epoch and ack the one being
+// acknowledged.
+func NewHeader(epoch int64, seq int64, ack int64, codec codec.CODEC_TYPE) Header {
+	return Header{epoch: epoch, seq: seq, ack: ack, codec: codec}
+}
+
+// MailHeader takes the numbering of reliable mail.
+func MailHeader(mail actor.Mail[any]) Header {
+	return NewHeader(mail.Epoch(), mail.Seq(), mail.Ack(), mail.CodeC())
+}
+
+func (h Header) Epoch() int64 {
+	return h.epoch
+}
+
+func (h Header) Seq() int64 {
+	return h.seq
+}
+
+func (h Header) Ack() int64 {
+	return h.ack
+}
+
 type Package struct {
 	Header
 	body []byte
 }
 
+func NewPackage(header Header, body []byte) *Package {
+	header.len = len(body)
+	return &Package{Header: header, body: body}
+}
+
+// NewMailPackage wraps encoded mail, the header carries its numbering so the
+// package can be acknowledged and deduplicated without decoding the body.
+func NewMailPackage(mail actor.Mail[any]) (*Package, error) {
+	body, err := actor.EncodeMail(mail)
+	if err != nil {
+		return nil, err
+	}
+	return NewPackage(MailHeader(mail), body), nil
+}
+
+// Mail decodes the body of a package made by NewMailPackage.
+func (p *Package) Mail() (actor.Mail[any], error) {
+	return actor.DecodeMail(p.body)
+}
+
+// WritePackage writes the header in front of the body.
+func WritePackage(w io.Writer, p *Package) error {
+	buff := make([]byte, headerSize, headerSize+len(p.body))
+	binary.BigEndian.PutUint64(buff[0:], uint64(p.epoch))
+	binary.BigEndian.PutUint64(buff[8:], uint64(p.seq))
+	binary.BigEndian.PutUint64(buff[16:], uint64(p.ack))
+	buff[24] = byte(p.codec)
+	binary.BigEndian.PutUint32(buff[25:], uint32(len(p.body)))
+	_, err := w.Write(append(buff, p.body...))
+	return err
+}
+
+// ReadPackage reads a package written by WritePackage.
+func ReadPackage(r io.Reader) (*Package, error) {
+	buff := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, buff); err != nil {
+		return nil, err
+	}
+	header := NewHeader(int64(binary.BigEndian.Uint64(buff[0:])), int64(binary.BigEndian.Uint64(buff[8:])), int64(binary.BigEndian.Uint64(buff[16:])), codec.CODEC_TYPE(buff[24]))
+	size := binary.BigEndian.Uint32(buff[25:])
+	if size > actor.MaxFrameSize {
+		return nil, fmt.Errorf("package size %d exceeds limit %d", size, actor.MaxFrameSize)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return NewPackage(header, body), nil
+}
+
 func (p *Package) Encode() error {
 	// Encode is a no-op since body is already []byte
 	// If encoding is needed, it should be done before setting the body
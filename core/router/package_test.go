@@ -0,0 +1,80 @@
+package router
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/uuid"
+)
+
+func reliableMail() actor.Mail[any] {
+	idGen := uuid.NewSimpleUUIDGenerator("router_test")
+	return actor.NewMail[any](idGen.Next(), idGen.Next(), "reliable", codec.JSON_CODEC, actor.WithEpoch(3), actor.WithSeq(7), actor.WithAck(5))
+}
+
+func expectReliablePackage(t *testing.T, p *Package) {
+	if p.Epoch() != 3 || p.Seq() != 7 || p.Ack() != 5 {
+		t.Fatalf("header should carry the numbering of the mail, got epoch %v seq %v ack %v", p.Epoch(), p.Seq(), p.Ack())
+	}
+	mail, err := p.Mail()
+	if err != nil {
+		t.Fatalf("error while decode mail: %v", err)
+	}
+	if mail.Message() != "reliable" || mail.Seq() != 7 || mail.Epoch() != 3 {
+		t.Fatalf("mail not correct: %v", mail)
+	}
+}
+
+func TestPackage_ReliableMail(t *testing.T) {
+	p, err := NewMailPackage(reliableMail())
+	if err != nil {
+		t.Fatalf("error while create package: %v", err)
+	}
+	expectReliablePackage(t, p)
+
+	buffer := &bytes.Buffer{}
+	if err := WritePackage(buffer, p); err != nil {
+		t.Fatalf("error while write package: %v", err)
+	}
+	read, err := ReadPackage(buffer)
+	if err != nil {
+		t.Fatalf("error while read package: %v", err)
+	}
+	expectReliablePackage(t, read)
+}
+
+func TestTcpRouter_ForwardMail(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error while listen: %v", err)
+	}
+	defer listener.Close()
+	addr := listener.Addr().(*net.TCPAddr)
+	received := make(chan *Package, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		p, _ := ReadPackage(conn)
+		received <- p
+	}()
+
+	if err := NewTcpRouter(addr.IP.String(), addr.Port, nil).ForwardMail(reliableMail()); err != nil {
+		t.Fatalf("error while forward mail: %v", err)
+	}
+	select {
+	case p := <-received:
+		if p == nil {
+			t.Fatalf("package should be readable")
+		}
+		expectReliablePackage(t, p)
+	case <-time.After(time.Second):
+		t.Fatalf("package should be forwarded")
+	}
+}
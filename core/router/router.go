@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/shooyaaa/config"
+	"github.com/shooyaaa/core/actor"
 	"github.com/shooyaaa/core/network"
 	"github.com/shooyaaa/core/storage"
 	"github.com/shooyaaa/log"
@@ -37,15 +38,25 @@ type TcpRouter struct {
 	TcpRegistry
 }
 
+// Forward writes the package with its header, so the numbering of reliable
+// mail reaches the other side.
 func (tr *TcpRouter) Forward(p *Package) error {
-	log.DebugF("tcp router forward package seq: %v, ack: %v", p.seq, p.ack)
+	log.DebugF("tcp router forward package epoch: %v, seq: %v, ack: %v", p.epoch, p.seq, p.ack)
 	conn := network.TcpConn{}
 	_, err := conn.Dial(tr.host, tr.port)
 	if err != nil {
 		return err
 	}
-	conn.Write(p.body)
-	return nil
+	return WritePackage(conn, p)
+}
+
+// ForwardMail forwards mail numbered by actor.ReliableDelivery.
+func (tr *TcpRouter) ForwardMail(mail actor.Mail[any]) error {
+	p, err := NewMailPackage(mail)
+	if err != nil {
+		return err
+	}
+	return tr.Forward(p)
 }
 
 func (tr *TcpRouter) LookUp(entity string) (Router, error) {
@@ -0,0 +1,213 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/shooyaaa/core/codec"
+)
+
+// DefaultMaxFrame bounds a frame when a framer is made with maxFrame <= 0.
+const DefaultMaxFrame = 1 << 20
+
+// lengthPrefixSize is the big endian uint32 in front of every length
+// prefixed frame.
+const lengthPrefixSize = 4
+
+// ErrFrameTooLarge is returned once a frame exceeds the limit of its framer,
+// the stream can not be read further.
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// Framer cuts the bytes of a connection into the frames an op is encoded in.
+type Framer interface {
+	// Frame wraps an encoded op for writing.
+	Frame(payload []byte) ([]byte, error)
+	// Split returns the complete frames at the head of buf and the number of
+	// bytes they took, an incomplete frame stays for the next read.
+	Split(buf []byte) (frames [][]byte, n int, err error)
+	// ReadSize is the buffer a single read of the connection needs.
+	ReadSize() int
+}
+
+func maxFrameOrDefault(maxFrame int) int {
+	if maxFrame <= 0 {
+		return DefaultMaxFrame
+	}
+	return maxFrame
+}
+
+type lengthPrefixFramer struct {
+	maxFrame int
+}
+
+// NewLengthPrefixFramer puts the length of every frame in front of it as a
+// big endian uint32, the framing of tcp sessions.
+func NewLengthPrefixFramer(maxFrame int) Framer {
+	return &lengthPrefixFramer{maxFrame: maxFrameOrDefault(maxFrame)}
+}
+
+func (f *lengthPrefixFramer) Frame(payload []byte) ([]byte, error) {
+	if len(payload) > f.maxFrame {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrFrameTooLarge, len(payload), f.maxFrame)
+	}
+	frame := make([]byte, lengthPrefixSize+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[lengthPrefixSize:], payload)
+	return frame, nil
+}
+
+func (f *lengthPrefixFramer) Split(buf []byte) ([][]byte, int, error) {
+	var frames [][]byte
+	n := 0
+	for len(buf)-n >= lengthPrefixSize {
+		size := binary.BigEndian.Uint32(buf[n:])
+		if uint64(size) > uint64(f.maxFrame) {
+			return frames, n, fmt.Errorf("%w: %d bytes, limit %d", ErrFrameTooLarge, size, f.maxFrame)
+		}
+		end := n + lengthPrefixSize + int(size)
+		if end > len(buf) {
+			break
+		}
+		frames = append(frames, buf[n+lengthPrefixSize:end])
+		n = end
+	}
+	return frames, n, nil
+}
+
+func (f *lengthPrefixFramer) ReadSize() int {
+	return 4096
+}
+
+type delimiterFramer struct {
+	delimiter []byte
+	maxFrame  int
+}
+
+// NewDelimiterFramer ends every frame with delimiter, like newline delimited
+// json. The codec must never put delimiter into an encoded op.
+func NewDelimiterFramer(delimiter []byte, maxFrame int) Framer {
+	if len(delimiter) == 0 {
+		panic("empty frame delimiter")
+	}
+	return &delimiterFramer{delimiter: append([]byte(nil), delimiter...), maxFrame: maxFrameOrDefault(maxFrame)}
+}
+
+func (f *delimiterFramer) Frame(payload []byte) ([]byte, error) {
+	if len(payload) > f.maxFrame {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrFrameTooLarge, len(payload), f.maxFrame)
+	}
+	if bytes.Contains(payload, f.delimiter) {
+		return nil, fmt.Errorf("frame contains its delimiter %q", f.delimiter)
+	}
+	frame := make([]byte, 0, len(payload)+len(f.delimiter))
+	return append(append(frame, payload...), f.delimiter...), nil
+}
+
+func (f *delimiterFramer) Split(buf []byte) ([][]byte, int, error) {
+	var frames [][]byte
+	n := 0
+	for {
+		i := bytes.Index(buf[n:], f.delimiter)
+		if i < 0 {
+			break
+		}
+		if i > f.maxFrame {
+			return frames, n, fmt.Errorf("%w: %d bytes, limit %d", ErrFrameTooLarge, i, f.maxFrame)
+		}
+		frames = append(frames, buf[n:n+i])
+		n += i + len(f.delimiter)
+	}
+	// the delimiter may still be on its way, but not after maxFrame bytes
+	if len(buf)-n > f.maxFrame+len(f.delimiter)-1 {
+		return frames, n, fmt.Errorf("%w: no delimiter in %d bytes, limit %d", ErrFrameTooLarge, len(buf)-n, f.maxFrame)
+	}
+	return frames, n, nil
+}
+
+func (f *delimiterFramer) ReadSize() int {
+	return 4096
+}
+
+type messageFramer struct {
+	maxFrame int
+}
+
+// NewMessageFramer is for connections that keep message boundaries, like
+// websocket, where every read returns exactly one frame.
+func NewMessageFramer(maxFrame int) Framer {
+	return &messageFramer{maxFrame: maxFrameOrDefault(maxFrame)}
+}
+
+func (f *messageFramer) Frame(payload []byte) ([]byte, error) {
+	if len(payload) > f.maxFrame {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrFrameTooLarge, len(payload), f.maxFrame)
+	}
+	return payload, nil
+}
+
+func (f *messageFramer) Split(buf []byte) ([][]byte, int, error) {
+	if len(buf) > f.maxFrame {
+		return nil, 0, fmt.Errorf("%w: %d bytes, limit %d", ErrFrameTooLarge, len(buf), f.maxFrame)
+	}
+	if len(buf) == 0 {
+		return nil, 0, nil
+	}
+	return [][]byte{buf}, len(buf), nil
+}
+
+// ReadSize leaves room for one byte past the limit, so an oversized message
+// is noticed instead of cut. A MessageConn is read without this buffer.
+func (f *messageFramer) ReadSize() int {
+	return f.maxFrame + 1
+}
+
+// Decoder turns the bytes read from a connection into ops. A frame the codec
+// fails on is dropped and the stream stays usable, a framing error stops it.
+type Decoder struct {
+	framer Framer
+	codec  codec.Codec[codec.Op]
+	buf    []byte
+	err    error
+}
+
+func NewDecoder(framer Framer, c codec.Codec[codec.Op]) *Decoder {
+	return &Decoder{framer: framer, codec: c}
+}
+
+// Feed adds data and returns every op it completed, zero or more. The error
+// is the first frame that failed to decode or a framing error, which every
+// later Feed returns again.
+func (d *Decoder) Feed(data []byte) ([]codec.Op, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	d.buf = append(d.buf, data...)
+	frames, n, splitErr := d.framer.Split(d.buf)
+	var ops []codec.Op
+	var err error
+	for _, frame := range frames {
+		op, decodeErr := d.codec.Decode(frame)
+		if decodeErr != nil {
+			if err == nil {
+				err = fmt.Errorf("drop frame of %d bytes: %w", len(frame), decodeErr)
+			}
+			continue
+		}
+		ops = append(ops, op)
+	}
+	// shifted down so a long lived session does not keep growing the buffer
+	d.buf = d.buf[:copy(d.buf, d.buf[n:])]
+	if splitErr != nil {
+		d.err = splitErr
+		d.buf = nil
+		return ops, splitErr
+	}
+	return ops, err
+}
+
+// Buffered is the number of bytes of an incomplete frame.
+func (d *Decoder) Buffered() int {
+	return len(d.buf)
+}
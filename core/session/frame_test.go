@@ -0,0 +1,239 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/codec"
+	"github.com/stretchr/testify/assert"
+)
+
+func testOps(n int) []codec.Op {
+	ops := make([]codec.Op, 0, n)
+	for i := 0; i < n; i++ {
+		ops = append(ops, codec.Op{Type: codec.Op_Sync_Data, Ts: int64(i), Data: map[string]interface{}{"x": float64(i), "name": "player"}})
+	}
+	return ops
+}
+
+// stream frames every op and concatenates the frames.
+func stream(t *testing.T, framer Framer, c codec.Codec[codec.Op], ops []codec.Op) []byte {
+	var data []byte
+	for _, op := range ops {
+		payload, err := c.Encode(op)
+		assert.NoError(t, err)
+		frame, err := framer.Frame(payload)
+		assert.NoError(t, err)
+		data = append(data, frame...)
+	}
+	return data
+}
+
+func TestDecoder_SplitAndCoalesced(t *testing.T) {
+	c := codec.NewCodec[codec.Op](codec.JSON_CODEC)
+	for name, framer := range map[string]Framer{
+		"length":    NewLengthPrefixFramer(0),
+		"delimiter": NewDelimiterFramer([]byte("\n"), 0),
+		"crlf":      NewDelimiterFramer([]byte("\r\n"), 0),
+	} {
+		ops := testOps(5)
+		data := stream(t, framer, c, ops)
+		// 每种切分长度都要还原出同样的 op
+		for _, chunk := range []int{1, 3, 7, len(data)} {
+			decoder := NewDecoder(framer, c)
+			var decoded []codec.Op
+			for i := 0; i < len(data); i += chunk {
+				end := min(i+chunk, len(data))
+				got, err := decoder.Feed(data[i:end])
+				assert.NoError(t, err, name)
+				decoded = append(decoded, got...)
+			}
+			assert.Equal(t, ops, decoded, "%s in chunks of %d", name, chunk)
+			assert.Equal(t, 0, decoder.Buffered(), name)
+		}
+	}
+}
+
+func TestDecoder_Partial(t *testing.T) {
+	c := codec.NewCodec[codec.Op](codec.BINARY_CODEC)
+	framer := NewLengthPrefixFramer(0)
+	data := stream(t, framer, c, testOps(2))
+	decoder := NewDecoder(framer, c)
+	ops, err := decoder.Feed(data[:len(data)-1])
+	assert.NoError(t, err)
+	assert.Len(t, ops, 1)
+	assert.Greater(t, decoder.Buffered(), 0)
+	ops, err = decoder.Feed(data[len(data)-1:])
+	assert.NoError(t, err)
+	assert.Equal(t, testOps(2)[1:], ops)
+}
+
+func TestDecoder_BadFrame(t *testing.T) {
+	c := codec.NewCodec[codec.Op](codec.JSON_CODEC)
+	framer := NewDelimiterFramer([]byte("\n"), 0)
+	ops := testOps(2)
+	data := stream(t, framer, c, ops[:1])
+	data = append(data, []byte("not json\n")...)
+	data = append(data, stream(t, framer, c, ops[1:])...)
+	decoder := NewDecoder(framer, c)
+	decoded, err := decoder.Feed(data)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrFrameTooLarge))
+	assert.Equal(t, ops, decoded, "the bad frame is dropped")
+	more, err := decoder.Feed(stream(t, framer, c, ops[:1]))
+	assert.NoError(t, err)
+	assert.Equal(t, ops[:1], more)
+}
+
+func TestFramer_MaxFrame(t *testing.T) {
+	c := codec.NewCodec[codec.Op](codec.JSON_CODEC)
+	payload, _ := c.Encode(testOps(1)[0])
+	for name, framer := range map[string]Framer{
+		"length":    NewLengthPrefixFramer(len(payload) - 1),
+		"delimiter": NewDelimiterFramer([]byte("\n"), len(payload)-1),
+		"message":   NewMessageFramer(len(payload) - 1),
+	} {
+		_, err := framer.Frame(payload)
+		assert.ErrorIs(t, err, ErrFrameTooLarge, name)
+	}
+
+	// 长度前缀一到就能发现超限，不必等整帧
+	decoder := NewDecoder(NewLengthPrefixFramer(16), c)
+	_, err := decoder.Feed([]byte{0, 0, 1, 0})
+	assert.ErrorIs(t, err, ErrFrameTooLarge)
+	_, err = decoder.Feed(stream(t, NewLengthPrefixFramer(0), c, testOps(1)))
+	assert.ErrorIs(t, err, ErrFrameTooLarge, "the stream stays broken")
+
+	decoder = NewDecoder(NewDelimiterFramer([]byte("\n"), 8), c)
+	_, err = decoder.Feed([]byte("12345678"))
+	assert.NoError(t, err, "the delimiter may follow")
+	_, err = decoder.Feed([]byte("9"))
+	assert.ErrorIs(t, err, ErrFrameTooLarge)
+
+	decoder = NewDecoder(NewMessageFramer(8), c)
+	_, err = decoder.Feed([]byte("123456789"))
+	assert.ErrorIs(t, err, ErrFrameTooLarge)
+
+	_, err = NewDelimiterFramer([]byte("\n"), 0).Frame([]byte("a\nb"))
+	assert.Error(t, err)
+	assert.Panics(t, func() { NewDelimiterFramer(nil, 0) })
+}
+
+func TestDecoder_Message(t *testing.T) {
+	c := codec.NewCodec[codec.Op](codec.MSGPACK_CODEC)
+	framer := NewMessageFramer(0)
+	decoder := NewDecoder(framer, c)
+	for _, op := range testOps(3) {
+		data := stream(t, framer, c, []codec.Op{op})
+		ops, err := decoder.Feed(data)
+		assert.NoError(t, err)
+		assert.Equal(t, []codec.Op{op}, ops)
+	}
+	ops, err := decoder.Feed(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, ops)
+}
+
+type recordingOwner struct {
+	ops    chan codec.Op
+	closed chan int64
+}
+
+func (o *recordingOwner) OpHandler(op codec.Op, s *Session) {
+	o.ops <- op
+}
+
+func (o *recordingOwner) SessionClose(id int64) {
+	o.closed <- id
+}
+
+func TestSession_Stream(t *testing.T) {
+	c := codec.NewCodec[codec.Op](codec.BINARY_CODEC)
+	client, server := net.Pipe()
+	defer client.Close()
+	writer := &Session{Id: 1, Conn: client}
+	reader := &Session{Id: 2, Conn: server}
+
+	ops := testOps(3)
+	go func() {
+		// 三个 op 合成一次写入
+		data := stream(t, NewLengthPrefixFramer(0), c, ops)
+		client.Write(data)
+		writer.WriteWithCodec(ops[0], c)
+	}()
+	for _, op := range ops {
+		read, err := reader.ReadWithCodec(c)
+		assert.NoError(t, err)
+		assert.Equal(t, op, *read)
+	}
+	read, err := reader.ReadWithCodec(c)
+	assert.NoError(t, err)
+	assert.Equal(t, ops[0], *read)
+}
+
+func TestSession_OwnerClosedOnOversize(t *testing.T) {
+	SetCodec(codec.NewCodec[codec.Op](codec.BINARY_CODEC))
+	defer SetCodec(nil)
+	client, server := net.Pipe()
+	defer client.Close()
+	owner := &recordingOwner{ops: make(chan codec.Op, 4), closed: make(chan int64, 1)}
+	s := &Session{Id: 7, Conn: server, Framer: NewLengthPrefixFramer(64)}
+	s.SetOwner(owner)
+
+	sender := &Session{Id: 8, Conn: client, Framer: NewLengthPrefixFramer(64)}
+	_, err := sender.Write(testOps(1)[0])
+	assert.NoError(t, err)
+	select {
+	case op := <-owner.ops:
+		assert.Equal(t, testOps(1)[0], op)
+	case <-time.After(time.Second):
+		t.Fatal("the op should reach the owner")
+	}
+	client.Write([]byte{0, 0, 1, 0})
+	select {
+	case id := <-owner.closed:
+		assert.Equal(t, int64(7), id)
+	case <-time.After(time.Second):
+		t.Fatal("an oversized frame should close the session")
+	}
+	_, err = client.Write([]byte{0})
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+// messageConn hands out one queued message per read.
+type messageConn struct {
+	io.ReadWriter
+	messages [][]byte
+}
+
+func (m *messageConn) ReadMessage() ([]byte, error) {
+	if len(m.messages) == 0 {
+		return nil, io.EOF
+	}
+	message := m.messages[0]
+	m.messages = m.messages[1:]
+	return message, nil
+}
+
+func TestSession_MessageConn(t *testing.T) {
+	c := codec.NewCodec[codec.Op](codec.JSON_CODEC)
+	framer := NewMessageFramer(0)
+	ops := testOps(2)
+	conn := &messageConn{}
+	for _, op := range ops {
+		conn.messages = append(conn.messages, stream(t, framer, c, []codec.Op{op}))
+	}
+	s := &Session{Id: 1, Conn: conn, Framer: framer}
+	for _, op := range ops {
+		read, err := s.ReadWithCodec(c)
+		assert.NoError(t, err)
+		assert.Equal(t, op, *read)
+	}
+	// 按消息读取，不需要 maxFrame 大小的读缓冲
+	assert.Empty(t, s.readBuf)
+	_, err := s.ReadWithCodec(c)
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -38,6 +38,11 @@ func (m *Manager) MultiCastID(ids []int64, msg codec.Op) {
 	}
 }
 
+// Broadcast writes msg to every session of the manager. It stays a loop over
+// the sessions instead of an actor.PubSub topic: a session is a connection of
+// this process without a postman address, and every session takes every
+// broadcast, so there is no subscription to keep or replicate. Actors that
+// have to be reached on other nodes publish through actor.PubSub.
 func (m *Manager) Broadcast(msg codec.Op) {
 	ids := []int64{}
 	for id, _ := range m.s {
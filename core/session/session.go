@@ -1,8 +1,8 @@
 package session
 
 import (
-	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
 
@@ -21,34 +21,89 @@ func SetCodec(c codec.Codec[codec.Op]) {
 	codecInstance = c
 }
 
+// MessageConn is a Conn that keeps message boundaries, the session reads it a
+// message at a time instead of through a read buffer of the framer.
+type MessageConn interface {
+	ReadMessage() ([]byte, error)
+}
+
 type Session struct {
-	Id     int64
-	owner  Owner
-	Conn   io.ReadWriter
-	buffer *bytes.Buffer
+	Id    int64
+	owner Owner
+	Conn  io.ReadWriter
+	// Framer cuts the stream of Conn into ops, length prefixed when nil
+	Framer  Framer
+	decoder *Decoder
+	readBuf []byte
+	pending []codec.Op
 }
 
-func (s *Session) WriteWithCodec(msg codec.Op, c codec.Codec[codec.Op]) (int, error) {
-	buffer, _ := c.Encode(msg)
-	s.Conn.Write(buffer)
-	log.DebugF("down write msg")
-	return 0, nil
+func (s *Session) framer() Framer {
+	if s.Framer == nil {
+		s.Framer = NewLengthPrefixFramer(0)
+	}
+	return s.Framer
 }
 
-func (s *Session) ReadWithCodec(c codec.Codec[codec.Op]) (*codec.Op, error) {
-	buffer := make([]byte, 1024)
-	count, err := s.Conn.Read(buffer)
+func (s *Session) WriteWithCodec(msg codec.Op, c codec.Codec[codec.Op]) (int, error) {
+	buffer, err := c.Encode(msg)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	s.buffer.Write(buffer[0:count])
-	op, err := c.Decode(s.buffer.Bytes())
+	frame, err := s.framer().Frame(buffer)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	log.DebugF("down write msg")
+	return s.Conn.Write(frame)
+}
+
+// ReadOpsWithCodec reads Conn once and returns the ops completed by the read,
+// zero or more. See Decoder.Feed for the errors.
+func (s *Session) ReadOpsWithCodec(c codec.Codec[codec.Op]) ([]codec.Op, error) {
+	framer := s.framer()
+	if s.decoder == nil {
+		s.decoder = NewDecoder(framer, c)
+	}
+	s.decoder.codec = c
+	data, err := s.read(framer)
+	if len(data) > 0 {
+		ops, decodeErr := s.decoder.Feed(data)
+		if decodeErr != nil && err == nil {
+			err = decodeErr
+		}
+		return ops, err
+	}
+	return nil, err
+}
+
+func (s *Session) read(framer Framer) ([]byte, error) {
+	if conn, ok := s.Conn.(MessageConn); ok {
+		return conn.ReadMessage()
+	}
+	// the decoder copies what it keeps, so one buffer serves every read
+	if len(s.readBuf) != framer.ReadSize() {
+		s.readBuf = make([]byte, framer.ReadSize())
+	}
+	count, err := s.Conn.Read(s.readBuf)
+	if errors.Is(err, io.ErrShortBuffer) {
+		err = fmt.Errorf("%w: read more than %d bytes", ErrFrameTooLarge, len(s.readBuf))
+	}
+	return s.readBuf[:count], err
+}
+
+// ReadWithCodec returns the next op, reading Conn until one is complete.
+func (s *Session) ReadWithCodec(c codec.Codec[codec.Op]) (*codec.Op, error) {
+	for len(s.pending) == 0 {
+		ops, err := s.ReadOpsWithCodec(c)
+		s.pending = append(s.pending, ops...)
+		if err != nil && len(s.pending) == 0 {
+			return nil, err
+		}
 	}
-	// 清空已读取的数据
-	s.buffer.Reset()
-	return &op, err
+	op := s.pending[0]
+	s.pending = s.pending[1:]
+	return &op, nil
 }
 
 func (s *Session) Write(msg codec.Op) (int, error) {
@@ -58,6 +113,13 @@ func (s *Session) Write(msg codec.Op) (int, error) {
 	return s.WriteWithCodec(msg, codecInstance)
 }
 
+func (s *Session) ReadOps() ([]codec.Op, error) {
+	if codecInstance == nil {
+		return nil, errors.New("Default codec should setted")
+	}
+	return s.ReadOpsWithCodec(codecInstance)
+}
+
 func (s *Session) Read() (*codec.Op, error) {
 	if codecInstance == nil {
 		return nil, errors.New("Default codec should setted")
@@ -70,20 +132,22 @@ func (s *Session) SetOwner(o Owner) {
 	var once sync.Once
 	go once.Do(func() {
 		for {
-			if s.buffer == nil {
-				s.buffer = &bytes.Buffer{}
-			}
 			log.DebugF("read from session %d", s.Id)
-			op, err := s.Read()
+			ops, err := s.ReadOps()
+			for _, op := range ops {
+				s.owner.OpHandler(op, s)
+			}
 			if err != nil {
 				log.InfoF("error while read from session: %v", err)
-				if err == io.EOF {
+				// a broken frame leaves the rest of the stream unreadable
+				if err == io.EOF || errors.Is(err, ErrFrameTooLarge) {
 					log.ErrorF("session end reason %v", err)
+					if closer, ok := s.Conn.(io.Closer); ok {
+						closer.Close()
+					}
 					s.owner.SessionClose(s.Id)
 					break
 				}
-			} else {
-				s.owner.OpHandler(*op, s)
 			}
 		}
 	})
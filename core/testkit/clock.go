@@ -0,0 +1,26 @@
+package testkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/library"
+)
+
+// UseVirtualTime runs the actor timers started during the test on a
+// VirtualClock ticking every tick, the timers only fire when the test
+// advances the clock. Tests using it must not run in parallel, the previous
+// wheel is back once the test ends.
+func UseVirtualTime(t testing.TB, tick time.Duration) *library.VirtualClock {
+	clock := library.NewVirtualClock(time.Now())
+	wheel := library.NewTimingWheelWithClock(tick, 512, clock)
+	wheel.Start()
+	previous := actor.DefaultTimingWheel
+	actor.DefaultTimingWheel = wheel
+	t.Cleanup(func() {
+		actor.DefaultTimingWheel = previous
+		wheel.Stop()
+	})
+	return clock
+}
@@ -0,0 +1,106 @@
+package testkit
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/actor"
+)
+
+// LeakTimeout is how long stopped actors get to let their goroutines end.
+var LeakTimeout = 2 * time.Second
+
+// ignoredGoroutines live for the whole process by design, so does the wheel
+// of actor.DefaultTimingWheel, see defaultWheel.
+var ignoredGoroutines = []string{
+	"testing.(*T).Run",
+	"testing.runTests",
+	"os/signal.signal_recv",
+}
+
+// VerifyNoLeaks fails the test when goroutines started after the call are
+// still running once the test and its cleanups are done. Call it first, so
+// it runs after the cleanups that stop actors, postmen and servers. Stacks
+// containing any of ignore are not reported.
+func VerifyNoLeaks(t testing.TB, ignore ...string) {
+	t.Helper()
+	baseline := make(map[string]bool)
+	for _, g := range goroutines() {
+		baseline[g.id] = true
+	}
+	t.Cleanup(func() {
+		if leaked := waitForLeaks(baseline, LeakTimeout, ignore); len(leaked) > 0 {
+			t.Errorf("%d goroutines leaked:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+		}
+	})
+}
+
+// waitForLeaks returns the stacks of the goroutines that are not in baseline
+// and have not ended within timeout.
+func waitForLeaks(baseline map[string]bool, timeout time.Duration, ignore []string) []string {
+	deadline := time.Now().Add(timeout)
+	for {
+		var leaked []string
+		for _, g := range goroutines() {
+			if !baseline[g.id] && !ignored(g.stack, ignore) {
+				leaked = append(leaked, g.stack)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// defaultWheel matches the goroutine of actor.DefaultTimingWheel only, other
+// wheels are stopped with their owner and leak like anything else.
+func defaultWheel() string {
+	return fmt.Sprintf("library.(*TimingWheel).run(%p", actor.DefaultTimingWheel)
+}
+
+func ignored(stack string, ignore []string) bool {
+	for _, list := range [][]string{ignoredGoroutines, {defaultWheel()}, ignore} {
+		for _, fn := range list {
+			if strings.Contains(stack, fn) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type goroutine struct {
+	id    string
+	stack string
+}
+
+// goroutines lists every goroutine but the calling one.
+func goroutines() []goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var result []goroutine
+	for i, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if i == 0 {
+			continue
+		}
+		header, _, _ := strings.Cut(string(stack), "\n")
+		fields := strings.Fields(header)
+		if len(fields) < 2 || fields[0] != "goroutine" {
+			continue
+		}
+		result = append(result, goroutine{id: fields[1], stack: string(stack)})
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package testkit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/uuid"
+)
+
+// DefaultExpectTimeout bounds the wait of ExpectMsg and FishForMessage.
+var DefaultExpectTimeout = 3 * time.Second
+
+// TestProbe is an actor that queues every mail it receives, so a test can
+// add it to a postman in place of a real actor and expect mail instead of
+// sleeping. It is stopped when the test ends.
+type TestProbe struct {
+	actor.Actor[actor.Mail[any], any]
+	t        testing.TB
+	received chan actor.Mail[any]
+	last     actor.Mail[any]
+}
+
+func NewTestProbe(t testing.TB, id uuid.UUID) *TestProbe {
+	p := &TestProbe{
+		Actor:    actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, id, nil),
+		t:        t,
+		received: make(chan actor.Mail[any], 1024),
+	}
+	p.Start(func(mail actor.Mail[any]) {
+		p.received <- mail
+	})
+	t.Cleanup(p.Stop)
+	return p
+}
+
+// ReceiveMsg waits up to d for the next mail, the test fails without one.
+func (p *TestProbe) ReceiveMsg(d time.Duration) actor.Mail[any] {
+	p.t.Helper()
+	select {
+	case mail := <-p.received:
+		p.last = mail
+		return mail
+	case <-time.After(d):
+		p.t.Fatalf("probe %s received no message within %s", p.idString(), d)
+		return nil
+	}
+}
+
+func (p *TestProbe) ExpectMsg(expected any) actor.Mail[any] {
+	p.t.Helper()
+	return p.ExpectMsgWithin(DefaultExpectTimeout, expected)
+}
+
+// ExpectMsgWithin fails the test unless the next mail arrives within d and
+// carries expected.
+func (p *TestProbe) ExpectMsgWithin(d time.Duration, expected any) actor.Mail[any] {
+	p.t.Helper()
+	mail := p.ReceiveMsg(d)
+	if mail == nil {
+		return nil
+	}
+	if !reflect.DeepEqual(expected, mail.Message()) {
+		p.t.Fatalf("probe %s expected message %#v, got %#v", p.idString(), expected, mail.Message())
+		return nil
+	}
+	return mail
+}
+
+// ExpectNoMsg fails the test if any mail arrives within d.
+func (p *TestProbe) ExpectNoMsg(d time.Duration) {
+	p.t.Helper()
+	select {
+	case mail := <-p.received:
+		p.last = mail
+		p.t.Fatalf("probe %s expected no message, got %#v", p.idString(), mail.Message())
+	case <-time.After(d):
+	}
+}
+
+// FishForMessage drops mail until fn accepts a message and returns that
+// mail, the test fails when none is accepted within DefaultExpectTimeout.
+func (p *TestProbe) FishForMessage(fn func(message any) bool) actor.Mail[any] {
+	p.t.Helper()
+	deadline := time.After(DefaultExpectTimeout)
+	for {
+		select {
+		case mail := <-p.received:
+			p.last = mail
+			if fn(mail.Message()) {
+				return mail
+			}
+		case <-deadline:
+			p.t.Fatalf("probe %s caught no matching message within %s", p.idString(), DefaultExpectTimeout)
+			return nil
+		}
+	}
+}
+
+// LastSender is the sender of the mail taken last.
+func (p *TestProbe) LastSender() uuid.UUID {
+	if p.last == nil {
+		return uuid.UUID{}
+	}
+	return p.last.Sender()
+}
+
+func (p *TestProbe) idString() string {
+	id := p.ID()
+	return (&id).String()
+}
+
+// ExpectTerminated fails the test unless a terminates within
+// DefaultExpectTimeout.
+func ExpectTerminated(t testing.TB, a actor.Actor[actor.Mail[any], any]) {
+	t.Helper()
+	select {
+	case <-a.Terminated():
+	case <-time.After(DefaultExpectTimeout):
+		id := a.ID()
+		t.Fatalf("actor %s did not terminate within %s", (&id).String(), DefaultExpectTimeout)
+	}
+}
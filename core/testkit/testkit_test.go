@@ -0,0 +1,149 @@
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shooyaaa/core/actor"
+	"github.com/shooyaaa/core/codec"
+	"github.com/shooyaaa/core/library"
+	"github.com/shooyaaa/core/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const UUIDTypeTestkit uuid.UUIDType = "testkit"
+
+// fakeT records the failure instead of ending the test.
+type fakeT struct {
+	testing.TB
+	failure string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Fatalf(format string, args ...any) {
+	f.failure = fmt.Sprintf(format, args...)
+}
+
+func TestProbe_Expect(t *testing.T) {
+	VerifyNoLeaks(t)
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTestkit)
+	ctx := context.Background()
+	pm := actor.NewPostman()
+	probe := NewTestProbe(t, idGen.Next())
+	pm.Add(ctx, probe)
+	sender := idGen.Next()
+	for _, message := range []any{"a", "b", "c"} {
+		assert.Nil(t, pm.Deliver(ctx, actor.NewMail[any](sender, probe.ID(), message, codec.JSON_CODEC)))
+	}
+	probe.ExpectMsg("a")
+	assert.Equal(t, sender, probe.LastSender())
+	mail := probe.FishForMessage(func(message any) bool {
+		return message == "c"
+	})
+	assert.Equal(t, "c", mail.Message())
+	probe.ExpectNoMsg(20 * time.Millisecond)
+}
+
+func TestProbe_Failures(t *testing.T) {
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTestkit)
+	ft := &fakeT{TB: t}
+	probe := NewTestProbe(ft, idGen.Next())
+	send := func(message any) {
+		probe.Mailbox().Send(context.Background(), actor.NewMail[any](uuid.UUID{}, probe.ID(), message, codec.JSON_CODEC))
+	}
+
+	assert.Nil(t, probe.ExpectMsgWithin(10*time.Millisecond, "nothing"))
+	assert.Contains(t, ft.failure, "received no message")
+	send("other")
+	assert.Nil(t, probe.ExpectMsg("expected"))
+	assert.Contains(t, ft.failure, `expected message "expected", got "other"`)
+	send("unexpected")
+	probe.ExpectNoMsg(time.Second)
+	assert.Contains(t, ft.failure, `expected no message, got "unexpected"`)
+}
+
+func TestVirtualTime_ActorTimers(t *testing.T) {
+	VerifyNoLeaks(t)
+	clock := UseVirtualTime(t, 10*time.Millisecond)
+	probe := NewTestProbe(t, uuid.NewSimpleUUIDGenerator(UUIDTypeTestkit).Next())
+
+	probe.StartSingleTimer("once", "fired", time.Minute)
+	probe.ExpectNoMsg(20 * time.Millisecond)
+	clock.Advance(59 * time.Second)
+	probe.ExpectNoMsg(20 * time.Millisecond)
+	clock.Advance(time.Second)
+	probe.ExpectMsg("fired")
+
+	probe.StartPeriodicTimer("tick", "tick", 10*time.Second)
+	clock.Advance(30 * time.Second)
+	for i := 0; i < 3; i++ {
+		probe.ExpectMsg("tick")
+	}
+	assert.True(t, probe.CancelTimer("tick"))
+	clock.Advance(time.Minute)
+	probe.ExpectNoMsg(20 * time.Millisecond)
+}
+
+func TestVerifyNoLeaks_Postoffice(t *testing.T) {
+	VerifyNoLeaks(t)
+	idGen := uuid.NewSimpleUUIDGenerator(UUIDTypeTestkit)
+	ctx := context.Background()
+	ring := library.NewConsistentHash[actor.Address](150, nil, nil)
+	po := actor.NewPostoffice(ring, idGen.Next())
+	pm1 := actor.NewPostman(actor.WithPostmanID(idGen.Next()))
+	pm2 := actor.NewPostman(actor.WithPostmanID(idGen.Next()))
+	for _, pm := range []actor.Postman{pm1, pm2} {
+		po.Add(ctx, actor.NewLocalPostManAddress(pm))
+		pm.Register(ctx, actor.NewLocalPostOfficeAddress(po))
+	}
+	// the mail crosses the postoffice whichever postman the probe is on
+	id := idGen.Next()
+	probe := NewTestProbe(t, id)
+	if a, _ := ring.Get((&id).String()); a.ID() == pm1.ID() {
+		pm1.Add(ctx, probe)
+	} else {
+		pm2.Add(ctx, probe)
+	}
+	sender := idGen.Next()
+	assert.Nil(t, pm1.Deliver(ctx, actor.NewMail[any](sender, probe.ID(), "via pm1", codec.JSON_CODEC)))
+	assert.Nil(t, pm2.Deliver(ctx, actor.NewMail[any](sender, probe.ID(), "via pm2", codec.JSON_CODEC)))
+	probe.ExpectMsg("via pm1")
+	probe.ExpectMsg("via pm2")
+}
+
+func TestVerifyNoLeaks_Detects(t *testing.T) {
+	baseline := make(map[string]bool)
+	for _, g := range goroutines() {
+		baseline[g.id] = true
+	}
+	release := make(chan struct{})
+	go func() {
+		<-release
+	}()
+	leaked := waitForLeaks(baseline, 50*time.Millisecond, nil)
+	if assert.Len(t, leaked, 1) {
+		assert.Contains(t, leaked[0], "TestVerifyNoLeaks_Detects")
+	}
+	close(release)
+	assert.Empty(t, waitForLeaks(baseline, time.Second, nil))
+}
+
+func TestVerifyNoLeaks_OtherWheel(t *testing.T) {
+	baseline := make(map[string]bool)
+	for _, g := range goroutines() {
+		baseline[g.id] = true
+	}
+	actor.DefaultTimingWheel.Start()
+	wheel := library.NewTimingWheel(10*time.Millisecond, 8)
+	wheel.Start()
+	// 只放过默认的时间轮，其它没停掉的时间轮算泄漏
+	leaked := waitForLeaks(baseline, 50*time.Millisecond, nil)
+	if assert.Len(t, leaked, 1) {
+		assert.Contains(t, leaked[0], "library.(*TimingWheel).run")
+	}
+	wheel.Stop()
+	assert.Empty(t, waitForLeaks(baseline, time.Second, nil))
+}
@@ -1,36 +1,66 @@
 package types
 
 import (
+	"context"
 	"errors"
+	"sync/atomic"
 	"time"
 
+	"github.com/shooyaaa/core/actor"
 	"github.com/shooyaaa/core/codec"
 	"github.com/shooyaaa/core/session"
+	"github.com/shooyaaa/core/uuid"
 	"github.com/shooyaaa/log"
 )
 
+const UUIDType_ROOM uuid.UUIDType = "room"
+
+const roomTickTimer = "tick"
+
+var roomSequence atomic.Int64
+
+// roomTick is the message of the frame timer.
+type roomTick struct{}
+
+// SyncData moves the player of the session.
+type SyncData struct {
+	X float64 `json:"x" op:"required"`
+	Y float64 `json:"y" op:"required"`
+}
+
+func init() {
+	codec.RegisterPayload[SyncData](codec.Op_Sync_Data, 1)
+}
+
 type Room struct {
 	members   map[*session.Session]*Player
 	MaxMember int16
-	ticker    *time.Ticker
-	MsgChan   chan codec.Op
+	actor     actor.Actor[actor.Mail[any], any]
 	GameType  Game
 	Interval  uint16
 	FrameTime int64
 	msgBuffer []codec.Op
 }
 
+// Init starts the room actor, ops and frame ticks are both handled as mail so
+// the frame buffer is only touched by the actor.
 func (r *Room) Init() {
 	if r.Interval == 0 {
 		r.Interval = 50
 	}
-	r.ticker = time.NewTicker(time.Duration(r.Interval) * time.Millisecond)
 	if r.MaxMember == 0 {
 		r.MaxMember = 2000
 	}
-	r.MsgChan = make(chan codec.Op, 100)
 	r.members = make(map[*session.Session]*Player)
-	go r.Tick()
+	id := uuid.UUID{ID: roomSequence.Add(1), Type: UUIDType_ROOM}
+	r.actor = actor.NewActor[actor.Mail[any], any](actor.MailboxType_MEMORY, id, r)
+	r.actor.Start(r.Tick)
+	r.actor.StartPeriodicTimer(roomTickTimer, roomTick{}, time.Duration(r.Interval)*time.Millisecond)
+}
+
+// Close stops the room actor and its frame timer.
+func (r *Room) Close() {
+	r.actor.Stop()
 }
 
 func (r *Room) resetMsgChan() {
@@ -47,7 +77,11 @@ func (r *Room) Add(s *session.Session) error {
 	return nil
 }
 func (r *Room) OpHandler(op codec.Op, session *session.Session) {
-	r.MsgChan <- op
+	id := r.actor.ID()
+	err := r.actor.Mailbox().Send(context.Background(), actor.NewMail[any](id, id, op, codec.JSON_CODEC))
+	if err != nil {
+		log.ErrorF("error while queue op in room: %v\n", err)
+	}
 }
 
 func (r *Room) OpHandler1(op1 codec.Op, s *session.Session) {
@@ -55,15 +89,17 @@ func (r *Room) OpHandler1(op1 codec.Op, s *session.Session) {
 	case codec.Op_Logout:
 		delete(r.members, s)
 	case codec.Op_Sync_Data:
-		gameData := r.members[s]
-		x, ok := op1.Data["x"]
-		if ok {
-			gameData.X = x.(float64)
+		data, err := codec.Payload[SyncData](op1)
+		if err != nil {
+			log.WarnF("bad sync data from session %v: %v", s.Id, err)
+			return
 		}
-		y, ok := op1.Data["y"]
-		if ok {
-			gameData.Y = y.(float64)
+		gameData := r.members[s]
+		if gameData == nil {
+			log.WarnF("sync data from session %v without a player in the room", s.Id)
+			return
 		}
+		gameData.X, gameData.Y = data.X, data.Y
 		log.DebugF("Player %v moved to x: %v, y : %v", s.Id, gameData.X, gameData.Y)
 	default:
 		log.WarnF("unhandled op in room %v", op1.Type)
@@ -99,19 +135,18 @@ func (r *Room) MemberCount() int {
 	return len(r.members)
 }
 
-func (r *Room) Tick() {
-	for {
-		select {
-		case <-r.ticker.C:
-			now := time.Now().UnixNano() / 1000000
-			r.FrameTime = now - int64(r.Interval)
+func (r *Room) Tick(mail actor.Mail[any]) {
+	switch message := mail.Message().(type) {
+	case roomTick:
+		now := time.Now().UnixNano() / 1000000
+		r.FrameTime = now - int64(r.Interval)
+		if r.GameType != nil {
 			r.GameType.Play(r.msgBuffer)
-			r.resetMsgChan()
-		case op := <-r.MsgChan:
-			if op.Ts >= r.FrameTime {
-				r.msgBuffer = append(r.msgBuffer, op)
-			}
 		}
-
+		r.resetMsgChan()
+	case codec.Op:
+		if message.Ts >= r.FrameTime {
+			r.msgBuffer = append(r.msgBuffer, message)
+		}
 	}
 }
@@ -8,6 +8,7 @@ require (
 	github.com/gorilla/websocket v1.5.0
 	github.com/robotn/gohook v0.40.0
 	github.com/stretchr/testify v1.7.1
+	github.com/vmihailenco/msgpack/v5 v5.4.1
 	github.com/wcharczuk/go-chart v2.0.1+incompatible
 	golang.org/x/net v0.25.0
 )
@@ -21,6 +22,7 @@ require (
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/vcaesar/keycode v0.10.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	golang.org/x/image v0.0.0-20220617043117-41969df76e82 // indirect
 	golang.org/x/sys v0.20.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -19,7 +19,7 @@ func Connect() {
 		log.Fatal("error occurs while connect to server %v", err)
 		return
 	}
-	session.SetCodec(codec.NewCodec[codec.Op](codec.JSON_CODEC))
+	session.SetCodec(codec.NewCodec[codec.Op](Codec))
 	handler := ClientHandler{}
 	s.SetOwner(handler)
 	ch := make(chan os.Signal, 1)
@@ -33,11 +33,16 @@ type ClientHandler struct {
 
 func (c ClientHandler) OpHandler(op codec.Op, s *session.Session) {
 	fmt.Println("op comes ", op.Type, " session ", s.Id)
-	switch op.Type {
-	case codec.Op_KeyEvent:
-		log.DebugF("print key %v ", op.Data["KeyChar"])
-	case codec.Op_MouseEvent:
-		log.DebugF("mouse move to %v, %v", op.Data["X"], op.Data["Y"])
+	payload, err := codec.DecodePayload(op)
+	if err != nil {
+		log.WarnF("bad op from server: %v", err)
+		return
+	}
+	switch event := payload.(type) {
+	case KeyEvent:
+		log.DebugF("print key %v ", event.Keychar)
+	case MouseEvent:
+		log.DebugF("mouse move to %v, %v", event.X, event.Y)
 	}
 }
 func (c ClientHandler) SessionClose(id int64) {
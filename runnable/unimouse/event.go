@@ -0,0 +1,18 @@
+package unimouse
+
+import "github.com/shooyaaa/core/codec"
+
+type MouseEvent struct {
+	X int16 `op:"required"`
+	Y int16 `op:"required"`
+}
+
+type KeyEvent struct {
+	RawCode uint16
+	Keychar rune `op:"required"`
+}
+
+func init() {
+	codec.RegisterPayload[MouseEvent](codec.Op_MouseEvent, 1)
+	codec.RegisterPayload[KeyEvent](codec.Op_KeyEvent, 1)
+}
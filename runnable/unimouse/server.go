@@ -13,10 +13,15 @@ import (
 	. "github.com/shooyaaa/core/types"
 )
 
+// Codec encodes the ops between server and clients. Sessions do not
+// negotiate it, JSON keeps older clients working and BINARY_CODEC is opt in
+// for deployments that set it on both ends.
+var Codec = codec.JSON_CODEC
+
 func Run() {
 	tcp := network.Tcp{Id: &Simple{}}
 	tcp.Listen(":9994")
-	session.SetCodec(codec.NewCodec[codec.Op](codec.JSON_CODEC))
+	session.SetCodec(codec.NewCodec[codec.Op](Codec))
 	handler := Handler{}
 	handler.Manager.Init()
 	go startHook(handler)
@@ -30,9 +35,11 @@ func Run() {
 func startHook(h Handler) {
 	var lastTime time.Time = time.Now()
 	hook.Register(hook.MouseMove, []string{}, func(e hook.Event) {
-		o := codec.MakeOp(codec.Op_MouseEvent, map[string]interface{}{
-			"X": e.X, "Y": e.Y,
-		})
+		o, err := codec.MakePayloadOp(codec.Op_MouseEvent, MouseEvent{X: e.X, Y: e.Y})
+		if err != nil {
+			log.ErrorF("error while make mouse op: %v", err)
+			return
+		}
 		diff := e.When.Sub(lastTime)
 		if diff > 5*time.Microsecond {
 			log.DebugF("mouse pos x: %v, y: %v, diff: %v", e.X, e.Y, diff)
@@ -42,9 +49,11 @@ func startHook(h Handler) {
 		}
 	})
 	hook.Register(hook.KeyUp, []string{}, func(e hook.Event) {
-		o := codec.MakeOp(codec.Op_KeyEvent, map[string]interface{}{
-			"RawCode": e.Rawcode, "Keychar": e.Keychar,
-		})
+		o, err := codec.MakePayloadOp(codec.Op_KeyEvent, KeyEvent{RawCode: e.Rawcode, Keychar: e.Keychar})
+		if err != nil {
+			log.ErrorF("error while make key op: %v", err)
+			return
+		}
 		h.Manager.Broadcast(o)
 		hook.StopEvent()
 	})